@@ -10,7 +10,7 @@ import (
 	"sync"
 	"time"
 
-	"go.bug.st/serial"
+	"github.com/physicist2018/goserialcomm/pkg/serialport"
 )
 
 var (
@@ -113,13 +113,8 @@ func main() {
 }
 
 func readCOMPort(clientManager *ClientManager) {
-	// Настройка COM-порта
-	mode := &serial.Mode{
-		BaudRate: *baudRate,
-	}
-
 	for {
-		port, err := serial.Open(*comPort, mode)
+		port, err := serialport.Open(*comPort, *baudRate)
 		if err != nil {
 			log.Printf("Не удалось открыть COM-порт %s: %v. Повторная попытка через 5 секунд...", *comPort, err)
 			time.Sleep(5 * time.Second)
@@ -127,7 +122,6 @@ func readCOMPort(clientManager *ClientManager) {
 		}
 
 		log.Printf("COM-порт %s открыт успешно", *comPort)
-		defer port.Close()
 
 		// Создаем буферизированный читатель для COM-порта
 		reader := bufio.NewReader(port)
@@ -154,6 +148,7 @@ func readCOMPort(clientManager *ClientManager) {
 				log.Printf("Данные отправлены %d клиентам: %s", clientManager.GetClientCount(), line)
 			}
 		}
+		port.Close()
 
 		// Если произошла ошибка, ждем перед повторным подключением
 		log.Printf("Переподключение к COM-порту через 2 секунды...")
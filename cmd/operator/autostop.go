@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// stopCondition — условие автоматической остановки по значениям полей:
+//
+//	<сравнение> [for <длительность>] [after <сравнение>]
+//
+// Например, "Depth < 0.5 for 30s after Depth > 2": остановиться, когда
+// глубина 30 секунд подряд меньше 0.5 м, но только после того, как
+// погружение началось (глубина превысила 2 м).
+type stopCondition struct {
+	When  sensor.Comparison
+	For   time.Duration
+	After *sensor.Comparison
+
+	armed bool      // условие After уже выполнялось
+	since time.Time // с какого момента выполняется When
+}
+
+func parseStopCondition(s string) (*stopCondition, error) {
+	c := &stopCondition{}
+	rest := s
+	if i := strings.Index(rest, " after "); i >= 0 {
+		after, err := sensor.ParseComparison(rest[i+len(" after "):])
+		if err != nil {
+			return nil, err
+		}
+		c.After = &after
+		rest = rest[:i]
+	}
+	if i := strings.Index(rest, " for "); i >= 0 {
+		d, err := time.ParseDuration(strings.TrimSpace(rest[i+len(" for "):]))
+		if err != nil {
+			return nil, fmt.Errorf("условие %q: %w", s, err)
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("условие %q: отрицательная длительность", s)
+		}
+		c.For = d
+		rest = rest[:i]
+	}
+	when, err := sensor.ParseComparison(rest)
+	if err != nil {
+		return nil, err
+	}
+	c.When = when
+	c.armed = c.After == nil
+	return c, nil
+}
+
+// Check учитывает строку, принятую в момент t, и сообщает, выполнено ли
+// условие. Строки без нужных полей не влияют на результат.
+func (c *stopCondition) Check(t time.Time, s sensor.Sample) bool {
+	if !c.armed {
+		if match, _ := c.After.Eval(s); !match {
+			return false
+		}
+		c.armed = true
+	}
+
+	match, ok := c.When.Eval(s)
+	if !ok {
+		return false
+	}
+	if !match {
+		c.since = time.Time{}
+		return false
+	}
+	if c.since.IsZero() {
+		c.since = t
+	}
+	return t.Sub(c.since) >= c.For
+}
+
+func (c *stopCondition) String() string {
+	s := c.When.String()
+	if c.For > 0 {
+		s += " for " + c.For.String()
+	}
+	if c.After != nil {
+		s += " after " + c.After.String()
+	}
+	return s
+}
+
+// parseUntil разбирает время остановки: "15:04", "15:04:05" (ближайшее
+// такое время, сегодня или завтра), "2006-01-02 15:04[:05]" или RFC 3339.
+func parseUntil(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		clock, err := time.ParseInLocation(layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		t := time.Date(now.Year(), now.Month(), now.Day(),
+			clock.Hour(), clock.Minute(), clock.Second(), 0, now.Location())
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("время остановки %q: ожидается 15:04, 2006-01-02 15:04 или RFC 3339", s)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+func TestParseStopCondition(t *testing.T) {
+	tests := []struct {
+		in, want string
+		err      string
+	}{
+		{"Depth < 0.5", "Depth < 0.5", ""},
+		{"Depth<0.5 for 30s", "Depth < 0.5 for 30s", ""},
+		{"Depth < 0.5 after Depth > 2", "Depth < 0.5 after Depth > 2", ""},
+		{"Depth < 0.5 for 1m after Depth >= 2", "Depth < 0.5 for 1m0s after Depth >= 2", ""},
+		{"Depth < 0.5 for 30", "", "missing unit"},
+		{"Depth < 0.5 for -1s", "", "отрицательная"},
+		{"Depth < 0.5 after Depth", "", "Depth"},
+		{"< 0.5", "", "не указано поле"},
+	}
+	for _, tt := range tests {
+		c, err := parseStopCondition(tt.in)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: ошибка %v, ожидается %q", tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+		} else if c.String() != tt.want {
+			t.Errorf("%q: %s, ожидается %s", tt.in, c, tt.want)
+		}
+	}
+}
+
+func TestStopConditionCheck(t *testing.T) {
+	t0 := time.Date(2025, 8, 29, 3, 6, 48, 0, time.UTC)
+	type row struct {
+		at     time.Duration
+		values map[string]float64
+		want   bool
+	}
+	depth := func(v float64) map[string]float64 { return map[string]float64{"Depth": v} }
+	tests := []struct {
+		cond string
+		rows []row
+	}{
+		{"Depth < 0.5", []row{
+			{0, depth(1), false},
+			{time.Second, map[string]float64{"P": 1000}, false}, // нет поля
+			{2 * time.Second, depth(0.4), true},
+		}},
+		{"Depth < 0.5 for 10s", []row{
+			{0, depth(0.4), false},
+			{5 * time.Second, depth(0.3), false},
+			{8 * time.Second, depth(0.6), false}, // отсчёт заново
+			{9 * time.Second, depth(0.4), false},
+			{15 * time.Second, map[string]float64{"P": 1000}, false}, // строка без поля не сбрасывает отсчёт
+			{19 * time.Second, depth(0.4), true},
+		}},
+		{"Depth < 0.5 after Depth > 2", []row{
+			{0, depth(0.1), false}, // ещё не погружались
+			{time.Second, depth(1), false},
+			{2 * time.Second, depth(2.5), false},
+			{3 * time.Second, depth(1), false},
+			{4 * time.Second, depth(0.4), true}, // After больше не проверяется
+		}},
+		{"Depth < 0.5 for 2s after Depth > 2", []row{
+			{0, depth(3), false},
+			{time.Second, depth(0.4), false},
+			{2 * time.Second, depth(0.2), false},
+			{3 * time.Second, depth(0.3), true},
+		}},
+	}
+	for _, tt := range tests {
+		c, err := parseStopCondition(tt.cond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, r := range tt.rows {
+			if got := c.Check(t0.Add(r.at), sensor.Sample{Values: r.values}); got != r.want {
+				t.Errorf("%q, строка %d: %v, ожидается %v", tt.cond, i+1, got, r.want)
+			}
+		}
+	}
+}
+
+func TestParseUntil(t *testing.T) {
+	loc := time.FixedZone("MSK", 3*3600)
+	now := time.Date(2025, 8, 29, 14, 30, 0, 0, loc)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"15:04", time.Date(2025, 8, 29, 15, 4, 0, 0, loc)},
+		{"14:30", time.Date(2025, 8, 30, 14, 30, 0, 0, loc)}, // уже наступило — завтра
+		{"09:15:30", time.Date(2025, 8, 30, 9, 15, 30, 0, loc)},
+		{"2025-08-31 06:00", time.Date(2025, 8, 31, 6, 0, 0, 0, loc)},
+		{"2025-08-31 06:00:05", time.Date(2025, 8, 31, 6, 0, 5, 0, loc)},
+		{"2025-08-31T06:00:00Z", time.Date(2025, 8, 31, 6, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseUntil(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseUntil(%q) = %v, %v, ожидается %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "завтра", "25:00", "2025-08-31"} {
+		if _, err := parseUntil(bad, now); err == nil {
+			t.Errorf("parseUntil(%q): ожидается ошибка", bad)
+		}
+	}
+}
@@ -0,0 +1,555 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/derive"
+	"github.com/physicist2018/goserialcomm/pkg/export"
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+	"golang.org/x/term"
+)
+
+// newFlagSet создаёт набор флагов команды с общим флагом -dir.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	dir := fs.String("dir", "experiments", "Каталог с файлами экспериментов")
+	return fs, dir
+}
+
+// parseID разбирает флаги и единственный аргумент <id>. Флаги можно
+// указывать и до, и после id.
+func parseID(fs *flag.FlagSet, args []string) (string, error) {
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return "", fmt.Errorf("%s: не указан id эксперимента", fs.Name())
+	}
+	id := fs.Arg(0)
+	fs.Parse(fs.Args()[1:])
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("%s: лишние аргументы: %s", fs.Name(), strings.Join(fs.Args(), " "))
+	}
+	return id, nil
+}
+
+func listCmd(args []string) error {
+	fs, dir := newFlagSet("list")
+	search := fs.String("search", "", "Показывать эксперименты, в названии, описании или заметках которых есть текст")
+	since := fs.String("since", "", "Показывать эксперименты, начатые не раньше даты (2006-01-02)")
+	archived := fs.Bool("archived", false, "Показать архив вместо текущих экспериментов")
+	fs.Parse(args)
+
+	var from time.Time
+	if *since != "" {
+		var err error
+		if from, err = time.ParseInLocation("2006-01-02", *since, time.Local); err != nil {
+			return fmt.Errorf("list: -since: %w", err)
+		}
+	}
+
+	catalog := recording.Catalog{Dir: *dir}
+	if *archived {
+		catalog.Dir = filepath.Join(*dir, recording.ArchiveDir)
+	}
+	entries, err := catalog.List()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tНачало\tДлительность\tСтрок\tСостояние\tНазвание")
+	for _, e := range entries {
+		if e.Start().Before(from) || !matches(e, *search) {
+			continue
+		}
+		m := e.Manifest
+		if m == nil {
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\tнет манифеста\t-\n", e.ID, e.Start().Format("2006-01-02 15:04"))
+			continue
+		}
+		duration := "-"
+		if m.End != nil {
+			duration = m.End.Sub(m.Start).Round(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			e.ID, m.Start.Local().Format("2006-01-02 15:04"), duration, m.Lines, statusText(e), m.Name)
+	}
+	return tw.Flush()
+}
+
+// matches ищет текст без учёта регистра в названии, описании и заметках.
+func matches(e recording.Entry, text string) bool {
+	if text == "" {
+		return true
+	}
+	text = strings.ToLower(text)
+	haystack := []string{e.ID}
+	if m := e.Manifest; m != nil {
+		haystack = append(haystack, m.Name, m.Description, m.Operator)
+		for _, n := range m.Notes {
+			haystack = append(haystack, n.Text)
+		}
+	}
+	for _, s := range haystack {
+		if strings.Contains(strings.ToLower(s), text) {
+			return true
+		}
+	}
+	return false
+}
+
+func statusText(e recording.Entry) string {
+	switch {
+	case e.Manifest == nil:
+		return "нет манифеста"
+	case e.Abandoned(time.Now(), staleAfter):
+		return "прерван (см. recover)"
+	case e.Running():
+		return "идёт"
+	case e.Manifest.Status == recording.StatusRecovered:
+		return "восстановлен после сбоя"
+	case e.Manifest.StopReason != "":
+		return "завершён (" + e.Manifest.StopReason + ")"
+	}
+	return "завершён"
+}
+
+func showCmd(args []string) error {
+	fs, dir := newFlagSet("show")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	e, err := recording.Catalog{Dir: *dir}.Find(id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ID:            %s\n", e.ID)
+	m := e.Manifest
+	if m == nil {
+		fmt.Printf("Файл данных:   %s\n", e.DataPath)
+		fmt.Println("Манифест:      нет")
+		sum, err := recording.SummarizeFile(e.DataPath, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		return printSummary(os.Stdout, sum, nil)
+	}
+
+	fmt.Printf("Манифест:      %s\n", e.ManifestPath)
+	fmt.Printf("Название:      %s\n", m.Name)
+	fmt.Printf("Описание:      %s\n", m.Description)
+	fmt.Printf("Оператор:      %s\n", strings.TrimPrefix(m.Operator+"@"+m.Host, "@"))
+	fmt.Printf("Состояние:     %s\n", statusText(e))
+	fmt.Printf("Начало:        %s\n", m.Start.Local().Format("2006-01-02 15:04:05"))
+	if m.End != nil {
+		fmt.Printf("Окончание:     %s (%v)\n", m.End.Local().Format("2006-01-02 15:04:05"),
+			m.End.Sub(m.Start).Round(time.Millisecond))
+	}
+	fmt.Printf("Строк:         %d, ошибок разбора: %d\n", m.Lines, m.ParseErrors)
+	if m.QC != nil {
+		fmt.Printf("Качество:      %s\n", m.QC)
+	}
+	if m.Derived != nil {
+		fmt.Printf("Производные:   %s\n", m.Derived)
+	}
+	for _, p := range m.Pauses {
+		fmt.Printf("Пауза:         %s\n", formatInterval(p, ""))
+	}
+	for _, n := range m.Notes {
+		fmt.Printf("Заметка:       %s %s\n", n.Time.Local().Format("15:04:05"), n.Text)
+	}
+	if m.MergedFile != "" {
+		fmt.Printf("Объединённая:  %s\n", filepath.Join(filepath.Dir(e.ManifestPath), m.MergedFile))
+	}
+	if m.ReportFile != "" {
+		fmt.Printf("Отчёт:         %s\n", filepath.Join(e.Dir(), m.ReportFile))
+	}
+
+	for _, src := range m.AllSources() {
+		fmt.Println()
+		if src.Name != "" {
+			fmt.Printf("Источник %s\n", src.Name)
+		}
+		if len(src.Segments) == 0 {
+			fmt.Printf("Файл данных:   %s\n", filepath.Join(e.Dir(), src.DataFile))
+		}
+		for i, seg := range src.Segments {
+			fmt.Printf("Сегмент %-5d  %s, строк: %d\n", i+1, filepath.Join(e.Dir(), seg.File), seg.Lines)
+		}
+		fmt.Printf("Адрес:         %s\n", src.Address)
+		if len(m.Sources) > 0 {
+			fmt.Printf("Строк:         %d, ошибок разбора: %d\n", src.Lines, src.ParseErrors)
+		}
+		if src.SHA256 != "" {
+			fmt.Printf("SHA-256:       %s\n", src.SHA256)
+		}
+		for _, g := range src.Gaps {
+			fmt.Printf("Перерыв:       %s\n", formatInterval(recording.Interval{Start: g.Start, End: g.End}, g.Reason))
+		}
+
+		// Сводка из манифеста; для прерванных записей — по файлу
+		sum := src.Stats
+		if sum == nil {
+			if sum, err = recording.SummarizeSource(e.Dir(), src, m.Excluded); err != nil {
+				return err
+			}
+		}
+		fmt.Println()
+		if err := printSummary(os.Stdout, sum, src.Fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printSummary печатает сводку по данным эксперимента.
+func printSummary(w io.Writer, sum *recording.Summary, fields []recording.Column) error {
+	fmt.Fprintf(w, "Строк данных: %d, без данных: %d", sum.Rows, sum.Unparsed)
+	if sum.Excluded > 0 {
+		fmt.Fprintf(w, ", исключено паузами: %d", sum.Excluded)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Частота: %.3f Гц, наибольший промежуток: %v", sum.SampleRate, sum.MaxGap().Round(time.Millisecond))
+	if sum.MaxGapStart != nil {
+		fmt.Fprintf(w, " (с %s)", sum.MaxGapStart.Local().Format("15:04:05"))
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := "Поле\tЕд.\tЧисло\tМин\tМакс\tСреднее\tСКО\tПервое\tПоследнее\t"
+	if sum.QC {
+		header += "Сомнит.\tБрак\t"
+	}
+	fmt.Fprintln(tw, header)
+	for _, st := range sum.Fields {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t", st.Name, fieldUnit(fields, st.Name),
+			st.Count, st.Min, st.Max, st.Mean, st.Std, st.First, st.Last)
+		if sum.QC {
+			fmt.Fprintf(tw, "%d\t%d\t", st.Suspect, st.Fail)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func formatInterval(i recording.Interval, reason string) string {
+	s := i.Start.Local().Format("15:04:05") + " — "
+	if i.End != nil {
+		s += i.End.Local().Format("15:04:05") + " (" + i.End.Sub(i.Start).Round(time.Second).String() + ")"
+	} else {
+		s += "не закрыт"
+	}
+	if reason != "" {
+		s += ": " + reason
+	}
+	return s
+}
+
+func fieldUnit(fields []recording.Column, name string) string {
+	for _, c := range fields {
+		if c.Name == name {
+			return c.Unit
+		}
+	}
+	return ""
+}
+
+func exportCmd(args []string) error {
+	fs, dir := newFlagSet("export")
+	format := fs.String("format", "csv", "Формат выгрузки: "+strings.Join(export.FormatNames(), ", "))
+	output := fs.String("o", "", "Файл для выгрузки (по умолчанию — стандартный вывод)")
+	all := fs.Bool("all", false, "Выгружать и строки, принятые во время паузы")
+	source := fs.String("source", "", "Источник эксперимента с несколькими источниками (по умолчанию — первый)")
+	merge := fs.Bool("merge", false, "Выгрузить все источники, выровненные по времени первого")
+	tolerance := fs.Duration("tolerance", time.Second, "Допуск выравнивания для -merge")
+	derived := fs.String("derive", "", "Рассчитать (пересчитать) глубину, скорость звука и солёность: YAML-файл параметров, fresh или sea")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	write, ok := export.Formats[*format]
+	if !ok {
+		return fmt.Errorf("export: неизвестный формат %q (%s)", *format, strings.Join(export.FormatNames(), ", "))
+	}
+	opts := export.Options{All: *all}
+	if *derived != "" {
+		if opts.Derive, err = derive.Load(*derived); err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+	}
+	e, err := recording.Catalog{Dir: *dir}.Find(id)
+	if err != nil {
+		return err
+	}
+
+	var t *export.Table
+	if *merge {
+		if e.Manifest == nil {
+			return fmt.Errorf("export: у эксперимента %s нет манифеста и источников", e.ID)
+		}
+		t, err = loadMerged(e.Dir(), e.Manifest, opts, *tolerance)
+	} else {
+		var src *recording.Source
+		if src, err = e.Source(*source); err != nil {
+			return err
+		}
+		t, err = export.LoadSource(e.Dir(), e.Manifest, src, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		if binaryFormat(*format) && term.IsTerminal(int(os.Stdout.Fd())) {
+			return fmt.Errorf("export: формат %s двоичный, укажите файл через -o", *format)
+		}
+		w := bufio.NewWriter(os.Stdout)
+		if err := write(w, t); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+	if err := writeFile(*output, write, t); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Выгружено строк: %d в %s\n", t.Len(), *output)
+	return nil
+}
+
+func reportCmd(args []string) error {
+	fs, dir := newFlagSet("report")
+	output := fs.String("o", "", "Файл отчёта (по умолчанию — рядом с файлами эксперимента)")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	e, err := recording.Catalog{Dir: *dir}.Find(id)
+	if err != nil {
+		return err
+	}
+	m := e.Manifest
+	if m == nil {
+		return fmt.Errorf("report: у эксперимента %s нет манифеста", e.ID)
+	}
+
+	path, name := *output, ""
+	if path == "" {
+		name = m.ReportFile
+		if name == "" {
+			name = e.ID + ".report.html"
+		}
+		path = filepath.Join(e.Dir(), name)
+	}
+	if err := createReport(path, e.Dir(), m); err != nil {
+		return err
+	}
+	// Отчёт рядом с файлами записывается в манифест, чтобы archive и
+	// delete переносили его вместе с экспериментом
+	if name != "" && m.ReportFile == "" && !e.Running() {
+		m.ReportFile = name
+		if err := recording.WriteManifest(e.ManifestPath, m); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Отчёт: %s\n", path)
+	return nil
+}
+
+// loadMerged читает файлы всех источников эксперимента из каталога dir
+// и выравнивает их по времени первого источника.
+func loadMerged(dir string, m *recording.Manifest, opts export.Options, tolerance time.Duration) (*export.Table, error) {
+	var names []string
+	var tables []*export.Table
+	for _, src := range m.AllSources() {
+		t, err := export.LoadSource(dir, m, src, opts)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, src.Name)
+		tables = append(tables, t)
+	}
+	return export.Merge(names, tables, tolerance), nil
+}
+
+// writeFile выгружает таблицу в файл.
+func writeFile(path string, write export.Writer, t *export.Table) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := write(w, t); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func binaryFormat(format string) bool {
+	return format == "parquet" || format == "netcdf"
+}
+
+// staleAfter — сколько журнал незавершённого эксперимента может молчать,
+// прежде чем эксперимент считается прерванным. Работающий оператор
+// пишет в журнал раз в journalEvery.
+const staleAfter = 6 * journalEvery
+
+// abandoned возвращает прерванные эксперименты каталога.
+func abandoned(dir string) ([]recording.Entry, error) {
+	entries, err := recording.Catalog{Dir: dir}.List()
+	if err != nil {
+		return nil, err
+	}
+	var found []recording.Entry
+	for _, e := range entries {
+		if e.Abandoned(time.Now(), staleAfter) {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+// recoverEntries завершает прерванные эксперименты и печатает итог.
+func recoverEntries(entries []recording.Entry) error {
+	for _, e := range entries {
+		if err := recording.Recover(e); err != nil {
+			return fmt.Errorf("восстановление %s: %w", e.ID, err)
+		}
+		m := e.Manifest
+		fmt.Printf("Эксперимент %s восстановлен: окончание %s, строк %d\n",
+			e.ID, m.End.Local().Format("2006-01-02 15:04:05"), m.Lines)
+	}
+	return nil
+}
+
+// offerRecovery при запуске записи находит прерванные эксперименты и
+// предлагает их завершить. Без ask (запуск из флагов, файла параметров
+// или не с терминала) только перечисляет их: ответ на вопрос съел бы
+// строку, предназначенную для названия эксперимента.
+func offerRecovery(dir string, ask bool) error {
+	entries, err := abandoned(dir)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	fmt.Println("Найдены незавершённые эксперименты (сбой или потеря питания):")
+	for _, e := range entries {
+		fmt.Printf("  %s  %s, последняя запись %s\n", e.ID, e.Manifest.Name,
+			e.LastActivity().Local().Format("2006-01-02 15:04:05"))
+	}
+	if !ask {
+		fmt.Println("Их можно завершить командой recover")
+		return nil
+	}
+	answer, err := prompt("Завершить их сейчас? [Y/n] ")
+	if err != nil {
+		return err
+	}
+	if answer == "n" || answer == "N" {
+		fmt.Println("Их можно завершить позже командой recover")
+		return nil
+	}
+	return recoverEntries(entries)
+}
+
+func recoverCmd(args []string) error {
+	fs, dir := newFlagSet("recover")
+	force := fs.Bool("force", false, "Восстановить и эксперимент, в который, возможно, ещё идёт запись")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		entries, err := abandoned(*dir)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("Прерванных экспериментов нет")
+			return nil
+		}
+		return recoverEntries(entries)
+	}
+
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	e, err := recording.Catalog{Dir: *dir}.Find(id)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !e.Running():
+		return fmt.Errorf("recover: эксперимент %s уже завершён", e.ID)
+	case !e.Abandoned(time.Now(), staleAfter) && !*force:
+		return fmt.Errorf("recover: в эксперимент %s писали %v назад, возможно, запись ещё идёт (используйте -force)",
+			e.ID, time.Since(e.LastActivity()).Round(time.Second))
+	}
+	return recoverEntries([]recording.Entry{e})
+}
+
+func archiveCmd(args []string) error {
+	fs, dir := newFlagSet("archive")
+	force := fs.Bool("force", false, "Архивировать и незавершённый эксперимент")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	catalog := recording.Catalog{Dir: *dir}
+	e, err := catalog.Find(id)
+	if err != nil {
+		return err
+	}
+	if e.Running() && !*force {
+		return fmt.Errorf("archive: эксперимент %s не завершён (используйте -force)", e.ID)
+	}
+	if err := catalog.Archive(e); err != nil {
+		return err
+	}
+	fmt.Printf("Эксперимент %s перенесён в %s\n", e.ID, filepath.Join(*dir, recording.ArchiveDir))
+	return nil
+}
+
+func deleteCmd(args []string) error {
+	fs, dir := newFlagSet("delete")
+	yes := fs.Bool("y", false, "Не спрашивать подтверждение")
+	force := fs.Bool("force", false, "Удалить и незавершённый эксперимент")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	catalog := recording.Catalog{Dir: *dir}
+	e, err := catalog.Find(id)
+	if err != nil {
+		return err
+	}
+	if e.Running() && !*force {
+		return fmt.Errorf("delete: эксперимент %s не завершён (используйте -force)", e.ID)
+	}
+	if !*yes {
+		answer, err := prompt(fmt.Sprintf("Удалить %s? [y/N] ", strings.Join(e.Files(), ", ")))
+		if err != nil {
+			return err
+		}
+		if answer != "y" && answer != "Y" {
+			fmt.Println("Отменено")
+			return nil
+		}
+	}
+	if err := catalog.Delete(e); err != nil {
+		return err
+	}
+	fmt.Printf("Эксперимент %s удалён\n", e.ID)
+	return nil
+}
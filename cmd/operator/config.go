@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/physicist2018/goserialcomm/pkg/derive"
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	specFile     = flag.String("spec", "", "YAML-файл с параметрами запуска эксперимента")
+	nameFlag     = flag.String("name", "", "Название эксперимента")
+	descFlag     = flag.String("description", "", "Описание эксперимента")
+	serverFlag   = flag.String("server", "", "Адрес источника: host:port, tcp://, ws://, wss://, http://…/events или serial:///dev/ttyUSB0?baud=9600")
+	dirFlag      = flag.String("dir", "experiments", "Каталог для файлов экспериментов")
+	durationFlag = flag.Duration("duration", 0, "Длительность эксперимента (0 — до команды stop)")
+	operatorFlag = flag.String("operator", "", "Имя оператора (по умолчанию — пользователь ОС)")
+	untilFlag    = flag.String("until", "", "Остановить эксперимент в заданное время (15:04, 2006-01-02 15:04 или RFC 3339)")
+	samplesFlag  = flag.Int64("samples", 0, "Остановить эксперимент после N строк данных (0 — без ограничения)")
+	stopWhenFlag = flag.String("stop-when", "", "Условие остановки по полям, например \"Depth < 0.5 for 30s after Depth > 2\"")
+	syncFlag     = flag.Duration("sync", time.Second, "Интервал сброса данных на диск (0 — после каждой строки)")
+	rotateSize   = flag.String("rotate-size", "", "Начинать новый сегмент файла данных после заданного размера (например 100MB)")
+	rotateEvery  = flag.Duration("rotate-every", 0, "Начинать новый сегмент файла данных через заданное время (например 24h)")
+	compressFlag = flag.String("compress", "", "Сжимать закрытые сегменты: gzip или zstd")
+	mergeFlag    = flag.Duration("merge-tolerance", 0, "Построить таблицу всех источников, выровненную по времени с допуском (0 — не строить)")
+	deriveFlag   = flag.String("derive", "", "Рассчитывать глубину по давлению, скорость звука и солёность: YAML-файл параметров, fresh (пресная вода) или sea (морская)")
+	qcFlag       = flag.String("qc", "", "Проверять качество данных: YAML-файл правил, firmware (пределы прошивки) или bridge (флаги моста)")
+	reportFlag   = flag.Bool("report", false, "После завершения построить HTML-отчёт об эксперименте")
+	tuiFlag      = flag.Bool("tui", false, "Полноэкранный режим: значения полей, спарклайны, состояние связи и строка команд")
+	tuiWindow    = flag.Duration("tui-window", 5*time.Minute, "Период спарклайнов в режиме -tui")
+	sourcesFlag  sourceFlag
+)
+
+func init() {
+	flag.Var(&sourcesFlag, "source", "Источник имя=адрес (можно повторять; заменяет -server)")
+}
+
+// sourceFlag — повторяемый флаг -source имя=host:port.
+type sourceFlag []SourceSpec
+
+func (s *sourceFlag) String() string {
+	parts := make([]string, len(*s))
+	for i, src := range *s {
+		parts[i] = src.Name + "=" + src.Server
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s *sourceFlag) Set(v string) error {
+	name, server, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("ожидается имя=host:port: %q", v)
+	}
+	*s = append(*s, SourceSpec{Name: name, Server: server})
+	return nil
+}
+
+// stdin — единственный читатель стандартного ввода. Несколько
+// bufio.Reader поверх os.Stdin забирают друг у друга буферизованные
+// строки, если ввод подан через pipe.
+var stdin = bufio.NewReader(os.Stdin)
+
+// RunSpec — параметры запуска эксперимента. Заполняется из YAML-файла
+// (-spec), поверх которого применяются флаги командной строки; чего
+// не хватает, спрашивается интерактивно.
+//
+//	name: Погружение 30 м
+//	description: Проверка датчика на пирсе
+//	server: 192.168.1.10:8080
+//	dir: /data/experiments
+//	duration: 2h
+//	operator: ivanov
+//
+// Эксперимент останавливается по первому из выполненных условий:
+// duration, until, samples, stop_when или команде stop.
+//
+//	until: "06:00"
+//	samples: 36000
+//	stop_when: Depth < 0.5 for 30s after Depth > 2
+//
+// Данные сбрасываются на диск раз в sync_interval (по умолчанию 1s, 0 —
+// после каждой строки); при сбое теряется не больше этого интервала.
+//
+// Для долгих записей файл данных делится на сегменты по размеру или
+// времени; закрытые сегменты можно сжимать (gzip или zstd):
+//
+//	rotate_size: 100MB
+//	rotate_every: 24h
+//	compress: gzip
+//
+// Вместо server можно перечислить несколько источников; каждый пишет
+// свой файл, время у всех общее — время приёма оператором.
+//
+//	sources:
+//	  - name: arduino
+//	    server: 192.168.1.10:8080
+//	  - name: ctd
+//	    server: 192.168.1.11:8080
+//	    fields: [C:mS/cm, T:degC]
+//	merge_tolerance: 500ms
+type RunSpec struct {
+	Name        string        `yaml:"name"`
+	Description *string       `yaml:"description"`
+	Server      string        `yaml:"server"`
+	Dir         string        `yaml:"dir"`
+	Duration    time.Duration `yaml:"duration"`
+	Operator    string        `yaml:"operator"`
+	Until       string        `yaml:"until"`
+	Samples     int64         `yaml:"samples"`
+	StopWhen    string        `yaml:"stop_when"`
+
+	SyncInterval *time.Duration `yaml:"sync_interval"`
+	RotateSize   string         `yaml:"rotate_size"`
+	RotateEvery  time.Duration  `yaml:"rotate_every"`
+	Compress     string         `yaml:"compress"`
+
+	Sources        []SourceSpec  `yaml:"sources"`
+	MergeTolerance time.Duration `yaml:"merge_tolerance"`
+	// Report — построить HTML-отчёт после завершения.
+	Report bool `yaml:"report"`
+	// QC — правила проверки качества: путь к YAML-файлу, "firmware"
+	// или "bridge" — хранить флаги, поставленные мостом.
+	QC string `yaml:"qc"`
+	// Derive — параметры расчёта производных величин: путь к
+	// YAML-файлу, "fresh" или "sea".
+	Derive string `yaml:"derive"`
+
+	// Разобранные Until, StopWhen, RotateSize, QC и Derive.
+	UntilTime   time.Time      `yaml:"-"`
+	Condition   *stopCondition `yaml:"-"`
+	RotateBytes int64          `yaml:"-"`
+	QCRules     *qc.Rules      `yaml:"-"`
+	Derived     *derive.Config `yaml:"-"`
+}
+
+// SourceSpec — источник эксперимента с несколькими источниками.
+type SourceSpec struct {
+	Name   string `yaml:"name"`
+	Server string `yaml:"server"`
+	// Fields — поля строк источника в виде "Имя" или "Имя:единицы";
+	// по умолчанию поля прошивки датчика.
+	Fields []string `yaml:"fields"`
+
+	// deriver рассчитывает производные поля; задаётся sourceList.
+	deriver *derive.Deriver
+}
+
+// fields возвращает описание полей источника, включая производные.
+func (s SourceSpec) fields() []sensor.Field {
+	fields := sensor.Fields
+	if len(s.Fields) > 0 {
+		fields = make([]sensor.Field, len(s.Fields))
+		for i, f := range s.Fields {
+			name, unit, _ := strings.Cut(f, ":")
+			fields[i] = sensor.Field{Name: strings.TrimSpace(name), Unit: strings.TrimSpace(unit)}
+		}
+	}
+	if s.deriver != nil {
+		fields = append(fields[:len(fields):len(fields)], s.deriver.Fields(fields)...)
+	}
+	return fields
+}
+
+// sourceList возвращает источники эксперимента; без sources — один
+// безымянный источник server.
+func (spec *RunSpec) sourceList() []SourceSpec {
+	sources := spec.Sources
+	if len(sources) == 0 {
+		sources = []SourceSpec{{Server: spec.Server}}
+	}
+	if spec.Derived == nil {
+		return sources
+	}
+	list := make([]SourceSpec, len(sources))
+	for i, s := range sources {
+		s.deriver = derive.New(spec.Derived)
+		list[i] = s
+	}
+	return list
+}
+
+// loadRunSpec читает файл -spec (если задан) и применяет флаги.
+func loadRunSpec() (*RunSpec, error) {
+	spec := &RunSpec{}
+	if *specFile != "" {
+		data, err := os.ReadFile(*specFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, spec); err != nil {
+			return nil, fmt.Errorf("%s: %w", *specFile, err)
+		}
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			spec.Name = *nameFlag
+		case "description":
+			spec.Description = descFlag
+		case "server":
+			spec.Server = *serverFlag
+		case "dir":
+			spec.Dir = *dirFlag
+		case "duration":
+			spec.Duration = *durationFlag
+		case "operator":
+			spec.Operator = *operatorFlag
+		case "until":
+			spec.Until = *untilFlag
+		case "samples":
+			spec.Samples = *samplesFlag
+		case "stop-when":
+			spec.StopWhen = *stopWhenFlag
+		case "source":
+			spec.Sources, spec.Server = sourcesFlag, ""
+		case "sync":
+			spec.SyncInterval = syncFlag
+		case "rotate-size":
+			spec.RotateSize = *rotateSize
+		case "rotate-every":
+			spec.RotateEvery = *rotateEvery
+		case "compress":
+			spec.Compress = *compressFlag
+		case "merge-tolerance":
+			spec.MergeTolerance = *mergeFlag
+		case "report":
+			spec.Report = *reportFlag
+		case "qc":
+			spec.QC = *qcFlag
+		case "derive":
+			spec.Derive = *deriveFlag
+		}
+	})
+
+	return spec, spec.check()
+}
+
+// check заполняет умолчания и проверяет параметры, разбирая until,
+// stop_when и rotate_size.
+func (spec *RunSpec) check() error {
+	if spec.Dir == "" {
+		spec.Dir = *dirFlag
+	}
+	if spec.SyncInterval == nil {
+		spec.SyncInterval = syncFlag
+	}
+	if *spec.SyncInterval < 0 {
+		return fmt.Errorf("интервал сброса не может быть отрицательным: %v", *spec.SyncInterval)
+	}
+	if spec.Operator == "" {
+		spec.Operator = currentUser()
+	}
+	if spec.Duration < 0 {
+		return fmt.Errorf("длительность не может быть отрицательной: %v", spec.Duration)
+	}
+	if spec.Samples < 0 {
+		return fmt.Errorf("число строк не может быть отрицательным: %d", spec.Samples)
+	}
+	if err := checkSources(spec); err != nil {
+		return err
+	}
+	if spec.RotateSize != "" {
+		n, err := parseSize(spec.RotateSize)
+		if err != nil {
+			return err
+		}
+		spec.RotateBytes = n
+	}
+	if spec.RotateEvery < 0 {
+		return fmt.Errorf("интервал ротации не может быть отрицательным: %v", spec.RotateEvery)
+	}
+	if spec.Compress != "" && !spec.rotating() {
+		return fmt.Errorf("сжатие применяется к сегментам: укажите rotate_size или rotate_every")
+	}
+	if err := recording.CheckCompression(spec.Compress); err != nil {
+		return err
+	}
+	if spec.Until != "" {
+		t, err := parseUntil(spec.Until, time.Now())
+		if err != nil {
+			return err
+		}
+		if !t.After(time.Now()) {
+			return fmt.Errorf("время остановки %s уже прошло", t.Format(time.RFC3339))
+		}
+		spec.UntilTime = t
+	}
+	if spec.StopWhen != "" {
+		c, err := parseStopCondition(spec.StopWhen)
+		if err != nil {
+			return err
+		}
+		spec.Condition = c
+	}
+	if spec.QC != "" && spec.QC != recording.QCByBridge {
+		r, err := qc.Load(spec.QC)
+		if err != nil {
+			return err
+		}
+		spec.QCRules = r
+	}
+	if spec.Derive != "" {
+		c, err := derive.Load(spec.Derive)
+		if err != nil {
+			return err
+		}
+		spec.Derived = c
+	}
+	return nil
+}
+
+// rotating сообщает, что файл данных делится на сегменты.
+func (spec *RunSpec) rotating() bool {
+	return spec.RotateBytes > 0 || spec.RotateEvery > 0
+}
+
+// parseSize разбирает размер вида 500, 64K, 100MB, 2GiB (двоичные
+// единицы).
+func parseSize(s string) (int64, error) {
+	num := strings.TrimRightFunc(strings.TrimSpace(s), unicode.IsLetter)
+	unit := strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), num)))
+	unit = strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "I")
+	mult := map[string]int64{"": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40}[unit]
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || mult == 0 || n <= 0 {
+		return 0, fmt.Errorf("недопустимый размер %q (например 100MB)", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// checkSources проверяет имена источников: они входят в имена файлов
+// и столбцов объединённой таблицы.
+func checkSources(spec *RunSpec) error {
+	if spec.MergeTolerance < 0 {
+		return fmt.Errorf("допуск выравнивания не может быть отрицательным: %v", spec.MergeTolerance)
+	}
+	if len(spec.Sources) == 0 {
+		return nil
+	}
+	if spec.Server != "" {
+		return fmt.Errorf("укажите либо server, либо sources")
+	}
+	seen := map[string]bool{}
+	for _, s := range spec.Sources {
+		if s.Name == "" || strings.ContainsAny(s.Name, ". /\\") {
+			return fmt.Errorf("недопустимое имя источника %q", s.Name)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("источник %q указан дважды", s.Name)
+		}
+		if s.Server == "" {
+			return fmt.Errorf("источник %s: не указан адрес", s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return nil
+}
+
+func currentUser() string {
+	for _, env := range []string{"USER", "USERNAME"} {
+		if u := os.Getenv(env); u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+// prompt спрашивает значение у пользователя.
+func prompt(question string) (string, error) {
+	fmt.Print(question)
+	answer, err := stdin.ReadString('\n')
+	if err != nil && !(err == io.EOF && answer != "") {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
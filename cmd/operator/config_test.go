@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"500", 500},
+		{"64K", 64 << 10},
+		{"64k", 64 << 10},
+		{"100MB", 100 << 20},
+		{"100 MB", 100 << 20},
+		{"2GiB", 2 << 30},
+		{"1.5G", 3 << 29},
+		{"1TB", 1 << 40},
+	}
+	for _, tt := range tests {
+		if got, err := parseSize(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v, ожидается %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "MB", "0", "-5K", "10PB", "10MBB", "ten"} {
+		if _, err := parseSize(bad); err == nil {
+			t.Errorf("parseSize(%q): ожидается ошибка", bad)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+)
+
+// Команды консоли во время эксперимента.
+const (
+	cmdStop   = "stop"
+	cmdPause  = "pause"
+	cmdResume = "resume"
+	cmdMark   = "mark"
+	cmdNote   = "note"
+	cmdStatus = "status"
+	cmdHelp   = "help"
+)
+
+const consoleHelp = `Команды:
+  stop          остановить эксперимент
+  pause         пауза: данные пишутся, но помечаются исключёнными
+  resume        продолжить после паузы
+  mark <текст>  отметка в данных, например "остановка лебёдки на 50 м"
+  note <текст>  заметка в манифесте эксперимента
+  status        скорость, число строк, последнее значение, размер файла
+  help          эта справка`
+
+// command — команда оператора, которую выполняет saveDataToFile.
+type command struct {
+	Name string
+	Text string
+	Time time.Time
+	// Reply получает состояние по команде status из HTTP API; без
+	// него состояние печатается.
+	Reply chan<- runStatus
+}
+
+// parseCommand разбирает строку консоли. Пустая строка даёт пустую
+// команду без ошибки.
+func parseCommand(input string) (command, error) {
+	name, text, _ := strings.Cut(strings.TrimSpace(input), " ")
+	cmd := command{Name: name, Text: strings.TrimSpace(text), Time: time.Now()}
+	switch cmd.Name {
+	case "", cmdStop, cmdPause, cmdResume, cmdStatus, cmdHelp:
+	case cmdMark, cmdNote:
+		if cmd.Text == "" {
+			return cmd, fmt.Errorf("команде %s нужен текст", cmd.Name)
+		}
+	default:
+		return cmd, fmt.Errorf("неизвестная команда %q", cmd.Name)
+	}
+	return cmd, nil
+}
+
+// readConsole читает команды со стандартного ввода: stop передаётся в
+// stop, остальные — в commands.
+func readConsole(ctx context.Context, stop chan<- string, commands chan<- command) {
+	for {
+		input, err := stdin.ReadString('\n')
+		if strings.TrimSpace(input) != "" {
+			cmd, cerr := parseCommand(input)
+			switch {
+			case cerr != nil:
+				fmt.Printf("%v\n%s\n", cerr, consoleHelp)
+			case cmd.Name == cmdStop:
+				fmt.Println("Останавливаем эксперимент...")
+				select {
+				case stop <- recording.StopCommand:
+				case <-ctx.Done():
+				}
+				return
+			case cmd.Name == cmdHelp:
+				fmt.Println(consoleHelp)
+			default:
+				select {
+				case commands <- cmd:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		if err != nil {
+			// Ввод закрыт (cron, systemd, pipe) — остаются
+			// таймер и сигналы.
+			if err != io.EOF {
+				log.Printf("Ошибка чтения ввода: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// consoleReader читает команды оператора: stop передаёт в stop,
+// остальные — в commands. Это readConsole или tui.readKeys.
+type consoleReader func(ctx context.Context, stop chan<- string, commands chan<- command)
+
+// waitForStopCommand ждёт команды stop, условия автоматической
+// остановки или сигнала завершения (Ctrl+C, systemctl stop). Прочие
+// команды консоли передаются в запись. Возвращает причину остановки.
+func waitForStopCommand(s *session, read consoleReader) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stop := make(chan string, 1)
+	go read(ctx, stop, s.commands)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Printf("Получен сигнал %v. Останавливаем эксперимент...\n", sig)
+			select {
+			case stop <- recording.StopSignal:
+			case <-ctx.Done():
+			}
+		case <-ctx.Done():
+		}
+	}()
+
+	return s.waitForStop(stop)
+}
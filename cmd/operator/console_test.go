@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		in         string
+		name, text string
+		err        string
+	}{
+		{"", "", "", ""},
+		{"  stop\n", cmdStop, "", ""},
+		{"pause", cmdPause, "", ""},
+		{"resume", cmdResume, "", ""},
+		{"status", cmdStatus, "", ""},
+		{"help", cmdHelp, "", ""},
+		{"mark  остановка лебёдки на 50 м \n", cmdMark, "остановка лебёдки на 50 м", ""},
+		{"note сменили кабель", cmdNote, "сменили кабель", ""},
+		{"mark", cmdMark, "", "нужен текст"},
+		{"note   ", cmdNote, "", "нужен текст"},
+		{"Stop", "Stop", "", "неизвестная команда"},
+		{"quit now", "quit", "now", "неизвестная команда"},
+	}
+	for _, tt := range tests {
+		cmd, err := parseCommand(tt.in)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%q: ошибка %v, ожидается %q", tt.in, err, tt.err)
+		}
+		if cmd.Name != tt.name || cmd.Text != tt.text || cmd.Time.IsZero() {
+			t.Errorf("%q: %+v, ожидается %s %q", tt.in, cmd, tt.name, tt.text)
+		}
+	}
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+	"gopkg.in/yaml.v3"
+)
+
+// Режим serve: оператор работает без консоли, эксперименты запускаются
+// и останавливаются через HTTP API, несколько одновременно.
+//
+//	GET  /api/experiments                    список экспериментов
+//	POST /api/experiments                    начать эксперимент
+//	GET  /api/experiments/<id>               состояние или манифест
+//	POST /api/experiments/<id>/stop          остановить и дождаться завершения
+//	POST /api/experiments/<id>/mark          {"text": "..."}: отметка в данных
+//	POST /api/experiments/<id>/note          {"text": "..."}: заметка в манифесте
+//	POST /api/experiments/<id>/pause         пауза
+//	POST /api/experiments/<id>/resume        продолжить после паузы
+//	GET  /api/experiments/<id>/files         файлы эксперимента
+//	GET  /api/experiments/<id>/files/<имя>   скачать файл
+//
+// Тело запроса на запуск — параметры запуска в том же виде, что файл
+// -spec (JSON или YAML); name и адреса источников обязательны, каталог
+// задаёт -dir сервера. Файлы qc и derive — встроенные наборы
+// (firmware, bridge, fresh, sea) или файлы внутри этого каталога; порт
+// serial:// — только с флагом -allow-serial. Ошибки возвращаются как
+// {"error": "..."}.
+
+// daemon — эксперименты, запущенные через HTTP API.
+type daemon struct {
+	dir    string
+	token  string
+	origin string // Access-Control-Allow-Origin
+	serial bool   // разрешены источники serial://
+
+	mu       sync.Mutex
+	runs     map[string]*daemonRun // по ID эксперимента
+	starting map[string]bool       // ID, для которых идёт startSession
+	wg       sync.WaitGroup
+}
+
+// daemonRun — эксперимент, идущий под управлением daemon.
+type daemonRun struct {
+	*session
+	stop chan string
+	done chan struct{} // закрыт, когда эксперимент завершён
+	err  error         // ошибка завершения, после done
+}
+
+func serveCmd(args []string) error {
+	fs, dir := newFlagSet("serve")
+	listen := fs.String("listen", "127.0.0.1:8090", "Адрес HTTP API; слушать сеть можно только с -token")
+	token := fs.String("token", os.Getenv("OPERATOR_TOKEN"), "Требовать заголовок Authorization: Bearer <token> (по умолчанию $OPERATOR_TOKEN)")
+	allowSerial := fs.Bool("allow-serial", false, "Разрешить запуск экспериментов с портом serial:// этой машины")
+	origin := fs.String("allow-origin", "", "Разрешить запросы из браузера со страниц этого источника (например https://dashboard.local или *)")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("serve: лишние аргументы: %s", strings.Join(fs.Args(), " "))
+	}
+	if *token == "" && !loopback(*listen) {
+		return fmt.Errorf("serve: адрес %s доступен из сети, задайте -token или $OPERATOR_TOKEN", *listen)
+	}
+	if err := createExperimentsDir(*dir); err != nil {
+		return fmt.Errorf("Ошибка создания директории: %w", err)
+	}
+
+	// Спросить о прерванных экспериментах некого
+	if entries, err := abandoned(*dir); err == nil && len(entries) > 0 {
+		ids := make([]string, len(entries))
+		for i, e := range entries {
+			ids[i] = e.ID
+		}
+		log.Printf("Незавершённые эксперименты (завершите командой recover): %s", strings.Join(ids, ", "))
+	}
+
+	d := &daemon{dir: *dir, token: *token, origin: *origin, serial: *allowSerial, runs: map[string]*daemonRun{}, starting: map[string]bool{}}
+	server := &http.Server{Addr: *listen, Handler: d}
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
+	log.Printf("HTTP API оператора на %s, каталог %s", *listen, *dir)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		log.Printf("Получен сигнал %v. Останавливаем эксперименты...", sig)
+	}
+
+	// Новые запросы не принимаются, идущие эксперименты завершаются
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	d.stopAll(recording.StopSignal)
+	return nil
+}
+
+// loopback сообщает, доступен ли адрес listen только с этой машины.
+// Пустой хост означает все интерфейсы.
+func loopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// stopAll останавливает все эксперименты и ждёт их завершения.
+func (d *daemon) stopAll(reason string) {
+	d.mu.Lock()
+	for _, r := range d.runs {
+		r.requestStop(reason)
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// requestStop просит остановить эксперимент; повторные запросы
+// игнорируются.
+func (r *daemonRun) requestStop(reason string) {
+	select {
+	case r.stop <- reason:
+	default:
+	}
+}
+
+// send передаёт команду горутине записи.
+func (r *daemonRun) send(ctx context.Context, cmd command) error {
+	select {
+	case r.commands <- cmd:
+		return nil
+	case <-r.done:
+		return errFinished
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var errFinished = errors.New("эксперимент уже завершён")
+
+// httpError — ошибка с кодом ответа.
+type httpError struct {
+	code int
+	err  error
+}
+
+func (e httpError) Error() string { return e.err.Error() }
+
+func badRequest(format string, args ...any) error {
+	return httpError{http.StatusBadRequest, fmt.Errorf(format, args...)}
+}
+
+func (d *daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Панель управления может работать с другого адреса
+	if d.origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", d.origin)
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	if d.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+d.token)) != 1 {
+		writeError(w, httpError{http.StatusUnauthorized, errors.New("нужен токен доступа")})
+		return
+	}
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/api/experiments")
+	if !ok || path != "" && path[0] != '/' {
+		http.NotFound(w, r)
+		return
+	}
+	path = strings.Trim(path, "/")
+	parts := strings.SplitN(path, "/", 3)
+	var err error
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		err = d.list(w)
+	case path == "" && r.Method == http.MethodPost:
+		err = d.start(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		err = d.status(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == cmdStop && r.Method == http.MethodPost:
+		err = d.stop(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "files" && r.Method == http.MethodGet:
+		err = d.files(w, parts[0])
+	case len(parts) == 3 && parts[1] == "files" && r.Method == http.MethodGet:
+		err = d.download(w, r, parts[0], parts[2])
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		err = d.command(w, r, parts[0], parts[1])
+	default:
+		err = httpError{http.StatusNotFound, fmt.Errorf("нет такого запроса: %s %s", r.Method, r.URL.Path)}
+	}
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	var he httpError
+	if errors.As(err, &he) {
+		code = he.code
+	}
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// entryInfo — эксперимент в списке.
+type entryInfo struct {
+	ID     string     `json:"id"`
+	Name   string     `json:"name,omitempty"`
+	Status string     `json:"status"`
+	Active bool       `json:"active"` // запись ведёт этот сервер
+	Start  time.Time  `json:"start"`
+	End    *time.Time `json:"end,omitempty"`
+	Lines  int64      `json:"lines"`
+}
+
+func (d *daemon) list(w http.ResponseWriter) error {
+	entries, err := recording.Catalog{Dir: d.dir}.List()
+	if err != nil {
+		return err
+	}
+	list := []entryInfo{}
+	for _, e := range entries {
+		info := entryInfo{ID: e.ID, Start: e.Start(), Status: "none"}
+		if m := e.Manifest; m != nil {
+			info.Name, info.Status, info.End, info.Lines = m.Name, m.Status, m.End, m.Lines
+		}
+		if e.Abandoned(time.Now(), staleAfter) {
+			info.Status = "abandoned"
+		}
+		info.Active = d.run(e.ID) != nil
+		list = append(list, info)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Start.After(list[j].Start) })
+	return writeJSON(w, http.StatusOK, list)
+}
+
+// start запускает эксперимент по параметрам из тела запроса.
+func (d *daemon) start(w http.ResponseWriter, r *http.Request) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	spec := &RunSpec{}
+	if err := yaml.Unmarshal(body, spec); err != nil {
+		return badRequest("параметры запуска: %v", err)
+	}
+	spec.Dir = d.dir
+	if spec.Name == "" {
+		return badRequest("не указано название эксперимента (name)")
+	}
+	if spec.Description == nil {
+		spec.Description = new(string)
+	}
+	if err := d.restrict(spec); err != nil {
+		return badRequest("%v", err)
+	}
+	if err := spec.check(); err != nil {
+		return badRequest("%v", err)
+	}
+	for _, src := range spec.sourceList() {
+		if src.Server == "" {
+			return badRequest("не указан адрес источника (server или sources)")
+		}
+	}
+
+	exp, err := getExperimentDetails(spec)
+	if err != nil {
+		return err
+	}
+	if err := d.reserve(exp); err != nil {
+		return err
+	}
+	s, err := startSession(spec, exp, sessionOptions{quiet: true})
+	var run *daemonRun
+	if err == nil {
+		run = &daemonRun{session: s, stop: make(chan string, 1), done: make(chan struct{})}
+	}
+	d.mu.Lock()
+	delete(d.starting, exp.Base)
+	if run != nil {
+		d.runs[exp.Base] = run
+	}
+	d.mu.Unlock()
+	if err != nil {
+		return httpError{http.StatusBadGateway, err}
+	}
+	log.Printf("Эксперимент %s начат", exp.Base)
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		run.err = s.finish(s.waitForStop(run.stop))
+		if run.err != nil {
+			log.Printf("Ошибка завершения эксперимента %s: %v", exp.Base, run.err)
+		}
+		d.mu.Lock()
+		delete(d.runs, exp.Base)
+		d.mu.Unlock()
+		close(run.done)
+		log.Printf("Эксперимент %s завершён (%s)", exp.Base, exp.StopReason)
+	}()
+
+	return d.writeStatus(w, r, run, http.StatusCreated)
+}
+
+// restrict не даёт клиенту API читать файлы и открывать порты машины:
+// пути qc и derive отсчитываются от каталога экспериментов и не могут
+// из него выходить, serial:// разрешается флагом -allow-serial.
+func (d *daemon) restrict(spec *RunSpec) error {
+	for _, f := range []struct {
+		name    string
+		value   *string
+		presets []string
+	}{
+		{"qc", &spec.QC, []string{"firmware", recording.QCByBridge}},
+		{"derive", &spec.Derive, []string{"fresh", "sea"}},
+	} {
+		if *f.value == "" || *f.value == f.presets[0] || *f.value == f.presets[1] {
+			continue
+		}
+		if !filepath.IsLocal(*f.value) {
+			return fmt.Errorf("%s: ожидается %s, %s или файл в каталоге экспериментов", f.name, f.presets[0], f.presets[1])
+		}
+		*f.value = filepath.Join(d.dir, *f.value)
+	}
+	if d.serial {
+		return nil
+	}
+	for _, src := range spec.sourceList() {
+		if strings.HasPrefix(strings.ToLower(src.Server), "serial:") {
+			return fmt.Errorf("источник %s: порт serial:// через API не разрешён (флаг -allow-serial)", src.Server)
+		}
+	}
+	return nil
+}
+
+// reserve занимает ID эксперимента до конца startSession, чтобы два
+// одновременных запроса не начали один и тот же эксперимент.
+func (d *daemon) reserve(exp *Experiment) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := os.Stat(exp.ManifestFile())
+	if err == nil || d.runs[exp.Base] != nil || d.starting[exp.Base] {
+		return httpError{http.StatusConflict, fmt.Errorf("эксперимент %s уже существует", exp.Base)}
+	}
+	d.starting[exp.Base] = true
+	return nil
+}
+
+func (d *daemon) run(id string) *daemonRun {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.runs[id]
+}
+
+// find ищет эксперимент каталога по ID или его началу.
+func (d *daemon) find(id string) (recording.Entry, error) {
+	e, err := recording.Catalog{Dir: d.dir}.Find(id)
+	if err != nil {
+		return e, httpError{http.StatusNotFound, err}
+	}
+	return e, nil
+}
+
+// status возвращает состояние идущего эксперимента или манифест
+// завершённого.
+func (d *daemon) status(w http.ResponseWriter, r *http.Request, id string) error {
+	e, err := d.find(id)
+	if err != nil {
+		return err
+	}
+	if run := d.run(e.ID); run != nil {
+		return d.writeStatus(w, r, run, http.StatusOK)
+	}
+	return d.writeManifest(w, e.ID)
+}
+
+func (d *daemon) writeStatus(w http.ResponseWriter, r *http.Request, run *daemonRun, code int) error {
+	reply := make(chan runStatus, 1)
+	err := run.send(r.Context(), command{Name: cmdStatus, Time: time.Now(), Reply: reply})
+	if errors.Is(err, errFinished) {
+		return d.writeManifest(w, run.exp.Base)
+	}
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, code, <-reply)
+}
+
+func (d *daemon) writeManifest(w http.ResponseWriter, id string) error {
+	e, err := d.find(id)
+	if err != nil {
+		return err
+	}
+	if e.Manifest == nil {
+		return httpError{http.StatusNotFound, fmt.Errorf("у эксперимента %s нет манифеста", e.ID)}
+	}
+	return writeJSON(w, http.StatusOK, e.Manifest)
+}
+
+// stop останавливает эксперимент и возвращает его манифест после
+// завершения.
+func (d *daemon) stop(w http.ResponseWriter, r *http.Request, id string) error {
+	e, err := d.find(id)
+	if err != nil {
+		return err
+	}
+	run := d.run(e.ID)
+	if run == nil {
+		return httpError{http.StatusConflict, fmt.Errorf("эксперимент %s не идёт на этом сервере", e.ID)}
+	}
+	run.requestStop(recording.StopCommand)
+	select {
+	case <-run.done:
+	case <-r.Context().Done():
+		return r.Context().Err()
+	}
+	if run.err != nil {
+		return run.err
+	}
+	return d.writeManifest(w, e.ID)
+}
+
+// command выполняет команду консоли: mark, note, pause или resume.
+func (d *daemon) command(w http.ResponseWriter, r *http.Request, id, name string) error {
+	var req struct {
+		Text string `json:"text"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			return badRequest("ожидается {\"text\": \"...\"}: %v", err)
+		}
+	}
+	switch name {
+	case cmdMark, cmdNote, cmdPause, cmdResume:
+	default:
+		return httpError{http.StatusNotFound, fmt.Errorf("неизвестная команда %q", name)}
+	}
+	cmd, err := parseCommand(name + " " + strings.ReplaceAll(req.Text, "\n", " "))
+	if err != nil {
+		return badRequest("%v", err)
+	}
+
+	e, err := d.find(id)
+	if err != nil {
+		return err
+	}
+	run := d.run(e.ID)
+	if run == nil {
+		return httpError{http.StatusConflict, fmt.Errorf("эксперимент %s не идёт на этом сервере", e.ID)}
+	}
+	if err := run.send(r.Context(), cmd); err != nil {
+		return httpError{http.StatusConflict, err}
+	}
+	return d.writeStatus(w, r, run, http.StatusOK)
+}
+
+// fileInfo — файл эксперимента в списке.
+type fileInfo struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+func (d *daemon) files(w http.ResponseWriter, id string) error {
+	e, err := d.find(id)
+	if err != nil {
+		return err
+	}
+	list := []fileInfo{}
+	for _, f := range e.Files() {
+		if info, err := os.Stat(f); err == nil {
+			list = append(list, fileInfo{Name: filepath.Base(f), Size: info.Size(), Modified: info.ModTime()})
+		}
+	}
+	return writeJSON(w, http.StatusOK, list)
+}
+
+// download отдаёт файл эксперимента; другие файлы каталога недоступны.
+func (d *daemon) download(w http.ResponseWriter, r *http.Request, id, name string) error {
+	e, err := d.find(id)
+	if err != nil {
+		return err
+	}
+	for _, f := range e.Files() {
+		if filepath.Base(f) == name {
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+			http.ServeFile(w, r, f)
+			return nil
+		}
+	}
+	return httpError{http.StatusNotFound, fmt.Errorf("у эксперимента %s нет файла %q", e.ID, name)}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDaemonRestrict(t *testing.T) {
+	tests := []struct {
+		name   string
+		serial bool
+		spec   RunSpec
+		qc     string
+		err    string
+	}{
+		{"встроенные наборы", false, RunSpec{Server: "localhost:8081", QC: "firmware", Derive: "sea"}, "firmware", ""},
+		{"файл в каталоге", false, RunSpec{Server: "localhost:8081", QC: "rules/qc.yaml"}, filepath.Join("exp", "rules/qc.yaml"), ""},
+		{"абсолютный путь", false, RunSpec{Server: "localhost:8081", QC: "/etc/passwd"}, "", "qc:"},
+		{"выход из каталога", false, RunSpec{Server: "localhost:8081", Derive: "../derive.yaml"}, "", "derive:"},
+		{"порт без флага", false, RunSpec{Server: "serial:///dev/ttyUSB0"}, "", "-allow-serial"},
+		{"порт среди источников", false, RunSpec{Sources: []SourceSpec{{Name: "a", Server: "tcp://x:1"}, {Name: "b", Server: "SERIAL://COM3"}}}, "", "-allow-serial"},
+		{"порт с флагом", true, RunSpec{Server: "serial:///dev/ttyUSB0"}, "", ""},
+		{"мост", false, RunSpec{Server: "ws://localhost:8080/ws", QC: "bridge"}, "bridge", ""},
+	}
+	for _, tt := range tests {
+		d := &daemon{dir: "exp", serial: tt.serial}
+		spec := tt.spec
+		err := d.restrict(&spec)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: ошибка %v, ожидается %q", tt.name, err, tt.err)
+		}
+		if err == nil && spec.QC != tt.qc {
+			t.Errorf("%s: qc = %q, ожидается %q", tt.name, spec.QC, tt.qc)
+		}
+	}
+}
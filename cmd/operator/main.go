@@ -3,160 +3,308 @@ package main
 import (
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net"
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/export"
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+	"github.com/physicist2018/goserialcomm/pkg/report"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+	"golang.org/x/term"
 )
 
+// Experiment — состояние эксперимента. Манифест пишется рядом с файлами
+// данных при запуске и обновляется при завершении.
 type Experiment struct {
-	Name        string
-	Description string
-	StartTime   time.Time
-	EndTime     time.Time
-	FileName    string
+	recording.Manifest
+	// Dir и Base — каталог и общее начало имён файлов эксперимента.
+	Dir, Base string
+
+	journal *recording.Journal
+}
+
+// path возвращает путь файла эксперимента.
+func (e *Experiment) path(file string) string { return filepath.Join(e.Dir, file) }
+
+// ManifestFile — путь манифеста.
+func (e *Experiment) ManifestFile() string { return e.path(e.Base + ".json") }
+
+// subcommand — команда оператора.
+type subcommand struct {
+	Name  string
+	Usage string
+	Run   func(args []string) error
+}
+
+var subcommands = []subcommand{
+	{"record", "[флаги]  провести эксперимент (по умолчанию)", recordCmd},
+	{"list", "[-search текст] [-since дата] [-archived]  список экспериментов", listCmd},
+	{"show", "<id>  манифест и сводка по эксперименту", showCmd},
+	{"export", "<id> -format csv|jsonl|parquet|netcdf [-o файл] [-source имя|-merge]  выгрузить данные", exportCmd},
+	{"plot", "<id> [-fields a,b] [-right c] [-profile глубина] [-o файл.svg|.png]  построить график", plotCmd},
+	{"report", "<id> [-o файл.html]  построить HTML-отчёт об эксперименте", reportCmd},
+	{"serve", "[-listen 127.0.0.1:8090] [-token ...]  HTTP API: запуск и остановка экспериментов удалённо", serveCmd},
+	{"recover", "[<id>]  завершить эксперименты, прерванные сбоем", recoverCmd},
+	{"archive", "<id>  перенести эксперимент в архив", archiveCmd},
+	{"delete", "<id> [-y]  удалить эксперимент", deleteCmd},
 }
 
 func main() {
+	flag.Usage = usage
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run выбирает команду по первому аргументу. Без команды (или если
+// первый аргумент — флаг) выполняется record, как раньше.
+func run(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return recordCmd(args)
+	}
+	for _, c := range subcommands {
+		if c.Name == args[0] {
+			return c.Run(args[1:])
+		}
+	}
+	if args[0] == "help" {
+		usage()
+		return nil
+	}
+	usage()
+	return fmt.Errorf("неизвестная команда %q", args[0])
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Использование: %s <команда> [аргументы]\n\nКоманды:\n", filepath.Base(os.Args[0]))
+	for _, c := range subcommands {
+		fmt.Fprintf(out, "  %-8s %s\n", c.Name, c.Usage)
+	}
+	fmt.Fprintln(out, "\nФлаги record:")
+	flag.PrintDefaults()
+}
+
+// recordCmd проводит эксперимент: запись данных источников, каждого в
+// свой файл, до команды stop или условия остановки.
+func recordCmd(args []string) error {
+	flag.CommandLine.Parse(args)
+	if flag.NArg() > 0 {
+		return fmt.Errorf("лишние аргументы: %s", strings.Join(flag.Args(), " "))
+	}
+
 	fmt.Println("=== Система управления экспериментами ===")
 	fmt.Println("Хранение данных в текстовых файлах CSV формата")
 
+	spec, err := loadRunSpec()
+	if err != nil {
+		return fmt.Errorf("Ошибка параметров запуска: %w", err)
+	}
+
 	// Создание директории для экспериментов
-	if err := createExperimentsDir(); err != nil {
-		log.Fatal("Ошибка создания директории:", err)
+	if err := createExperimentsDir(spec.Dir); err != nil {
+		return fmt.Errorf("Ошибка создания директории: %w", err)
+	}
+
+	// Прерванные сбоем эксперименты; спрашиваем, только если и название
+	// будет спрошено у человека за терминалом
+	ask := *specFile == "" && spec.Name == "" && term.IsTerminal(int(os.Stdin.Fd()))
+	if err := offerRecovery(spec.Dir, ask); err != nil {
+		log.Printf("Ошибка восстановления: %v", err)
 	}
 
 	// Запрос данных эксперимента
-	experiment, err := getExperimentDetails()
+	experiment, err := getExperimentDetails(spec)
 	if err != nil {
-		log.Fatal("Ошибка ввода:", err)
+		return fmt.Errorf("Ошибка ввода: %w", err)
 	}
 
-	// Создание файла эксперимента
-	file, err := createExperimentFile(experiment)
-	if err != nil {
-		log.Fatal("Ошибка создания файла:", err)
+	// В полноэкранном режиме вывод записи уходит в область сообщений,
+	// поэтому адрес нужно спросить заранее
+	opts, read := sessionOptions{}, consoleReader(readConsole)
+	var ui *tui
+	if *tuiFlag {
+		if len(spec.Sources) == 0 {
+			if spec.Server, err = getServerAddress(spec.Server); err != nil {
+				return fmt.Errorf("Ошибка ввода: %w", err)
+			}
+		}
+		if ui, err = newTUI(experiment, spec.sourceList(), *tuiWindow); err != nil {
+			return err
+		}
+		opts, read = sessionOptions{quiet: true, watch: ui.observe}, ui.readKeys
 	}
 
-	// Подключение к удаленному серверу
-	conn, err := connectToRemoteServer()
+	s, err := startSession(spec, experiment, opts)
 	if err != nil {
-		file.Close()
-		log.Fatal("Ошибка подключения:", err)
+		if ui != nil {
+			ui.close()
+		}
+		return err
 	}
 
 	fmt.Println("Подключение установлено. Начинаем сбор данных...")
-	fmt.Println("Для остановки введите 'stop'")
-
-	// Создание контекста для управления горутинами
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	var wg sync.WaitGroup
-	dataChan := make(chan string, 100) // Буферизованный канал
-
-	// Запуск сбора данных
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer close(dataChan) // Закрываем канал при завершении
-		collectData(ctx, conn, dataChan)
-	}()
-
-	// Запуск сохранения данных
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		saveDataToFile(ctx, file, dataChan)
-	}()
-
-	// Ожидание команды остановки
-	waitForStopCommand(cancel)
-
-	// Ожидаем завершения всех горутин
-	wg.Wait()
+	fmt.Println("Для остановки введите 'stop', список команд — 'help'")
+	if spec.Duration > 0 {
+		fmt.Printf("Эксперимент будет остановлен автоматически через %v\n", spec.Duration)
+	}
+	if !spec.UntilTime.IsZero() {
+		fmt.Printf("Эксперимент будет остановлен автоматически в %s\n", spec.UntilTime.Format("2006-01-02 15:04:05"))
+	}
+	if spec.Samples > 0 {
+		fmt.Printf("Эксперимент будет остановлен после %d строк данных\n", spec.Samples)
+	}
+	if spec.Condition != nil {
+		fmt.Printf("Эксперимент будет остановлен по условию %q\n", spec.Condition.String())
+	}
 
-	// Завершение эксперимента
-	experiment.EndTime = time.Now()
-	if err := finalizeExperiment(file, experiment); err != nil {
+	// Ожидание команды остановки и завершение эксперимента; итоги
+	// печатаются уже после выхода из полноэкранного режима
+	if ui != nil {
+		ui.run(s.commands)
+	}
+	s.halt(waitForStopCommand(s, read))
+	if ui != nil {
+		ui.close()
+	}
+	if err := s.finalize(); err != nil {
 		log.Printf("Ошибка завершения эксперимента: %v", err)
 	}
 
-	// Закрываем файл и соединение
-	file.Close()
-	conn.Close()
-
-	fmt.Println("Эксперимент завершен. Данные сохранены в:", experiment.FileName)
+	fmt.Println("Эксперимент завершен. Данные сохранены в:")
+	for _, src := range experiment.AllSources() {
+		for _, f := range src.Files() {
+			fmt.Println(" ", experiment.path(f))
+		}
+	}
+	if experiment.MergedFile != "" {
+		fmt.Println("Объединённая таблица:", experiment.path(experiment.MergedFile))
+	}
+	if experiment.ReportFile != "" {
+		fmt.Println("Отчёт:", experiment.path(experiment.ReportFile))
+	}
+	fmt.Println("Манифест:", experiment.ManifestFile())
+	return nil
 }
 
-func createExperimentsDir() error {
-	return os.MkdirAll("experiments", 0755)
+func createExperimentsDir(dir string) error {
+	return os.MkdirAll(dir, 0755)
 }
 
-func getExperimentDetails() (*Experiment, error) {
-	reader := bufio.NewReader(os.Stdin)
-
-	fmt.Print("Введите название эксперимента: ")
-	name, err := reader.ReadString('\n')
-	if err != nil {
-		return nil, err
+// fileNameReplacer убирает из названия эксперимента пробелы и
+// разделители каталогов.
+var fileNameReplacer = strings.NewReplacer(" ", "_", "/", "_", "\\", "_")
+
+// getExperimentDetails берёт название и описание из параметров запуска,
+// спрашивая у пользователя только недостающие.
+func getExperimentDetails(spec *RunSpec) (*Experiment, error) {
+	name := spec.Name
+	for name == "" {
+		var err error
+		name, err = prompt("Введите название эксперимента: ")
+		if err != nil {
+			return nil, err
+		}
 	}
-	name = strings.TrimSpace(name)
 
-	fmt.Print("Введите описание эксперимента: ")
-	description, err := reader.ReadString('\n')
-	if err != nil {
-		return nil, err
+	var description string
+	if spec.Description != nil {
+		description = *spec.Description
+	} else {
+		var err error
+		description, err = prompt("Введите описание эксперимента: ")
+		if err != nil {
+			return nil, err
+		}
 	}
-	description = strings.TrimSpace(description)
 
-	// Генерация имени файла на основе времени и названия
-	timestamp := time.Now().Format("20060102_150405")
-	fileName := fmt.Sprintf("experiments/%s_%s.csv",
-		strings.ReplaceAll(name, " ", "_"), timestamp)
+	// Генерация имени файлов на основе времени и названия
+	timestamp := time.Now().Format(recording.IDTimeLayout)
+	exp := &Experiment{
+		Dir:  spec.Dir,
+		Base: fmt.Sprintf("%s_%s", fileNameReplacer.Replace(name), timestamp),
+	}
 
-	return &Experiment{
-		Name:        name,
-		Description: description,
-		StartTime:   time.Now(),
-		FileName:    fileName,
-	}, nil
+	host, _ := os.Hostname()
+	exp.Manifest = recording.Manifest{
+		SchemaVersion: recording.SchemaVersion,
+		Status:        recording.StatusRunning,
+		Name:          name,
+		Description:   description,
+		Operator:      spec.Operator,
+		Host:          host,
+		Start:         time.Now(),
+	}
+	sources := spec.sourceList()
+	if len(spec.Sources) == 0 {
+		exp.Source = recording.Source{
+			DataFile: exp.Base + ".csv",
+			Fields:   recording.Columns(sources[0].fields()),
+		}
+	} else {
+		for _, src := range sources {
+			exp.Sources = append(exp.Sources, recording.Source{
+				Name:     src.Name,
+				DataFile: exp.Base + "." + src.Name + ".csv",
+				Fields:   recording.Columns(src.fields()),
+			})
+		}
+	}
+	switch {
+	case spec.QCRules != nil:
+		exp.QC = &recording.QCInfo{By: recording.QCByOperator, Rules: spec.QCRules}
+	case spec.QC == recording.QCByBridge:
+		exp.QC = &recording.QCInfo{By: recording.QCByBridge}
+	}
+	exp.Derived = spec.Derived
+	if spec.rotating() {
+		for _, src := range exp.AllSources() {
+			src.Segments = []recording.Segment{{File: recording.SegmentName(src.DataFile, 1), Start: exp.Start}}
+		}
+	}
+	return exp, nil
 }
 
-func createExperimentFile(exp *Experiment) (*os.File, error) {
+// createExperimentFiles создаёт CSV-файлы источников с одной строкой
+// заголовка. Метаданные эксперимента хранятся в манифесте, а не в CSV.
+func createExperimentFiles(exp *Experiment, sources []SourceSpec) ([]*os.File, error) {
 	// Создание всех необходимых директорий
-	dir := filepath.Dir(exp.FileName)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := os.MkdirAll(exp.Dir, 0755); err != nil {
 		return nil, err
 	}
 
-	file, err := os.Create(exp.FileName)
+	var files []*os.File
+	for i, src := range exp.AllSources() {
+		file, err := createDataFile(exp.path(src.Files()[0]), sources[i].fields(), exp.QC != nil)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func createDataFile(path string, fields []sensor.Field, withQC bool) (*os.File, error) {
+	file, err := os.Create(path)
 	if err != nil {
 		return nil, err
 	}
 
-	// Запись метаданных в файл
-	writer := bufio.NewWriter(file)
-
-	// Первая строка - название
-	writer.WriteString(fmt.Sprintf("Название эксперимента: %s\n", exp.Name))
-
-	// Вторая строка - описание
-	writer.WriteString(fmt.Sprintf("Описание: %s\n", exp.Description))
-
-	// Третья строка - время начала
-	writer.WriteString(fmt.Sprintf("Время начала: %s\n", exp.StartTime.Format("2006-01-02 15:04:05")))
-
-	// Пустая строка разделитель
-	writer.WriteString("\n")
-
 	// Заголовок CSV
-	writer.WriteString("timestamp\tvalue\n")
-
+	writer := recording.NewWriter(file, fields)
+	writer.QC = withQC
+	if err := writer.WriteHeader(); err != nil {
+		file.Close()
+		return nil, err
+	}
 	if err := writer.Flush(); err != nil {
 		file.Close()
 		return nil, err
@@ -165,125 +313,249 @@ func createExperimentFile(exp *Experiment) (*os.File, error) {
 	return file, nil
 }
 
-func connectToRemoteServer() (net.Conn, error) {
-	reader := bufio.NewReader(os.Stdin)
-
-	fmt.Print("Введите адрес сервера (host:port): ")
-	address, err := reader.ReadString('\n')
-	if err != nil {
-		return nil, err
+func getServerAddress(address string) (string, error) {
+	for address == "" {
+		var err error
+		address, err = prompt("Введите адрес источника (host:port или URL): ")
+		if err != nil {
+			return "", err
+		}
 	}
-	address = strings.TrimSpace(address)
+	return address, nil
+}
 
-	conn, err := net.DialTimeout("tcp", address, 10*time.Second)
-	if err != nil {
-		return nil, err
-	}
+// Параметры переподключения к источнику во время эксперимента.
+const (
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = 30 * time.Second
+)
 
-	return conn, nil
+// record — то, что сборщик передаёт на запись: принятая строка или
+// событие (потеря и восстановление связи) источника с номером Source.
+type record struct {
+	Source int
+	Time   time.Time
+	Line   string
+	Event  string
 }
 
-func collectData(ctx context.Context, conn net.Conn, dataChan chan<- string) {
-	reader := bufio.NewReader(conn)
+// collectData читает строки из соединения и передаёт их на запись. При
+// обрыве связи отмечает начало перерыва и переподключается к address с
+// нарастающей задержкой, пока не отменён ctx.
+func collectData(ctx context.Context, source int, conn connection, address string, dataChan chan<- record) {
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
 
-	for {
+	send := func(r record) bool {
+		r.Source = source
 		select {
+		case dataChan <- r:
+			return true
 		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		err := conn.readLines(ctx, func(line string) bool {
+			return send(record{Time: time.Now(), Line: line})
+		})
+		conn.Close()
+		conn = nil
+		if ctx.Err() != nil {
 			fmt.Println("Сбор данных остановлен")
 			return
-		default:
-			// Устанавливаем таймаут на чтение
-			conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		}
 
-			data, err := reader.ReadString('\n')
-			if err != nil {
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-					// Таймаут - это нормально, продолжаем цикл
-					continue
-				}
-				if strings.Contains(err.Error(), "use of closed network connection") {
-					// Соединение закрыто - выходим
-					return
-				}
-				log.Printf("Ошибка чтения данных: %v", err)
-				return
-			}
+		lost := time.Now()
+		log.Printf("Ошибка чтения данных: %v", err)
+		if !send(record{Time: lost, Event: recording.FormatEvent(recording.EventGapStart, err.Error())}) {
+			return
+		}
 
-			data = strings.TrimSpace(data)
-			if data == "" {
-				continue
-			}
+		conn = reconnect(ctx, address, lost)
+		if conn == nil {
+			fmt.Println("Сбор данных остановлен")
+			return
+		}
+		if !send(record{Time: time.Now(), Event: recording.EventGapEnd}) {
+			return
+		}
+	}
+}
 
-			// Отправка данных в канал
-			select {
-			case dataChan <- data:
-			case <-ctx.Done():
-				return
-			}
+// reconnect пытается восстановить соединение, удваивая задержку между
+// попытками до reconnectMaxDelay. Возвращает nil, если отменён ctx.
+func reconnect(ctx context.Context, address string, lost time.Time) connection {
+	delay := reconnectMinDelay
+	for {
+		fmt.Printf("\n!!! НЕТ СВЯЗИ С %s уже %v — ДАННЫЕ НЕ ЗАПИСЫВАЮТСЯ !!!\n",
+			address, time.Since(lost).Round(time.Second))
+		fmt.Printf("!!! Повторное подключение через %v...\n\n", delay)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		conn, err := connectToRemoteServer(address)
+		if err == nil {
+			fmt.Printf("\n*** Связь с %s восстановлена, перерыв %v ***\n\n",
+				address, time.Since(lost).Round(time.Second))
+			return conn
+		}
+		log.Printf("Ошибка подключения: %v", err)
+
+		delay *= 2
+		if delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
 		}
 	}
 }
 
-func saveDataToFile(ctx context.Context, file *os.File, dataChan <-chan string) {
+// saveDataToFile пишет принятые строки и события в файлы и выполняет
+// команды консоли. Это единственная горутина, которая меняет файлы
+// данных, манифест и журнал во время эксперимента.
+func saveDataToFile(ctx context.Context, dataChan <-chan record, commands <-chan command, rec *recorder) {
+	ticker := time.NewTicker(rec.tick())
+	defer ticker.Stop()
+	defer rec.syncAll()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case data, ok := <-dataChan:
+		case now := <-ticker.C:
+			rec.checkpoint(now)
+		case r, ok := <-dataChan:
 			if !ok {
 				// Канал закрыт
 				return
 			}
+			rec.save(r)
+		case res := <-rec.closed:
+			rec.segmentClosed(res)
+		case cmd := <-commands:
+			rec.execute(cmd)
+		}
+	}
+}
 
-			// Преобразование данных в CSV формат
-			timestamp := time.Now().Format("2006-01-02 15:04:05")
+// startJournal создаёт журнал эксперимента с записью о начале.
+func startJournal(exp *Experiment) (*recording.Journal, error) {
+	j, err := recording.CreateJournal(recording.JournalPath(exp.ManifestFile()))
+	if err != nil {
+		return nil, err
+	}
+	err = j.Append(recording.JournalEntry{
+		Time:  time.Now(),
+		Event: recording.JournalStart,
+		PID:   os.Getpid(),
+		Host:  exp.Host,
+	})
+	if err != nil {
+		j.Close()
+		return nil, err
+	}
+	return j, nil
+}
 
-			var csvLine string
-			if !strings.Contains(data, ",") {
-				csvLine = fmt.Sprintf("%s,raw_data,%s,\n", timestamp, data)
-			} else {
-				csvLine = data + "\n"
-			}
+func writeManifest(exp *Experiment) error {
+	exp.CountTotals()
+	return recording.WriteManifest(exp.ManifestFile(), &exp.Manifest)
+}
 
-			// Запись в файл
-			if _, err := file.WriteString(csvLine); err != nil {
-				log.Printf("Ошибка записи в файл: %v", err)
-			} else {
-				fmt.Printf("Сохранено: %s", csvLine)
+// finalizeExperiment закрывает манифест: время окончания, длительность,
+// контрольные суммы и сводки по уже закрытым файлам данных (всем
+// сегментам). Если в spec задан допуск выравнивания, источники
+// сводятся в объединённую таблицу; если задан Report — строится отчёт.
+func finalizeExperiment(exp *Experiment, end time.Time, spec *RunSpec) error {
+	exp.Finish(end)
+	exp.CountTotals()
+
+	fmt.Printf("Длительность эксперимента: %v, строк: %d, ошибок разбора: %d\n",
+		end.Sub(exp.Start).Round(time.Millisecond), exp.Lines, exp.ParseErrors)
+
+	for _, src := range exp.AllSources() {
+		// Контрольные суммы сегментов считаются при их закрытии
+		if len(src.Segments) == 0 {
+			sum, err := recording.FileSHA256(exp.path(src.DataFile))
+			if err != nil {
+				return err
 			}
-
-			// Синхронизация с диском
-			file.Sync()
+			src.SHA256 = sum
 		}
-	}
-}
 
-func waitForStopCommand(cancel context.CancelFunc) {
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		input, err := reader.ReadString('\n')
+		if len(exp.Sources) > 0 {
+			fmt.Printf("\n--- %s (%s): строк %d, ошибок разбора %d ---\n",
+				src.Name, src.Address, src.Lines, src.ParseErrors)
+		}
+		stats, err := recording.SummarizeSource(exp.Dir, src, exp.Excluded)
 		if err != nil {
-			log.Printf("Ошибка чтения ввода: %v", err)
+			log.Printf("Ошибка расчёта сводки %s: %v", src.DataFile, err)
 			continue
 		}
+		src.Stats = stats
+		printSummary(os.Stdout, stats, src.Fields)
+	}
 
-		if strings.TrimSpace(input) == "stop" {
-			fmt.Println("Останавливаем эксперимент...")
-			cancel()
-			break
+	if spec.MergeTolerance > 0 && len(exp.Sources) > 1 {
+		if err := writeMerged(exp, spec.MergeTolerance); err != nil {
+			log.Printf("Ошибка построения объединённой таблицы: %v", err)
+		}
+	}
+	if spec.Report {
+		if err := writeReport(exp); err != nil {
+			log.Printf("Ошибка построения отчёта: %v", err)
 		}
 	}
-}
 
-func finalizeExperiment(file *os.File, exp *Experiment) error {
-	writer := bufio.NewWriter(file)
+	return writeManifest(exp)
+}
 
-	// Запись времени окончания
-	writer.WriteString(fmt.Sprintf("\nВремя окончания: %s\n",
-		exp.EndTime.Format("2006-01-02 15:04:05")))
+// writeMerged пишет таблицу источников, выровненную по времени первого.
+func writeMerged(exp *Experiment, tolerance time.Duration) error {
+	t, err := loadMerged(exp.Dir, &exp.Manifest, export.Options{All: true}, tolerance)
+	if err != nil {
+		return err
+	}
+	name := exp.Base + ".merged.csv"
+	if err := writeFile(exp.path(name), export.WriteCSV, t); err != nil {
+		return err
+	}
+	exp.MergedFile = name
+	return nil
+}
 
-	writer.WriteString(fmt.Sprintf("Длительность эксперимента: %v\n",
-		exp.EndTime.Sub(exp.StartTime)))
+// writeReport строит HTML-отчёт об эксперименте по его файлам.
+func writeReport(exp *Experiment) error {
+	name := exp.Base + ".report.html"
+	if err := createReport(exp.path(name), exp.Dir, &exp.Manifest); err != nil {
+		return err
+	}
+	exp.ReportFile = name
+	return nil
+}
 
-	return writer.Flush()
+// createReport пишет отчёт по эксперименту с манифестом m в файл path.
+func createReport(path, dir string, m *recording.Manifest) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := report.Write(w, dir, m); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
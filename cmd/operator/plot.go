@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/export"
+	"github.com/physicist2018/goserialcomm/pkg/plot"
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+)
+
+func plotCmd(args []string) error {
+	fs, dir := newFlagSet("plot")
+	fields := fs.String("fields", "", "Поля по левой оси через запятую (по умолчанию — все)")
+	right := fs.String("right", "", "Поля по правой оси через запятую, например T2")
+	profile := fs.String("profile", "", "Построить профиль: поле глубины по оси Y, направленной вниз")
+	output := fs.String("o", "", "Файл графика .svg или .png (по умолчанию <id>.svg)")
+	width := fs.Float64("width", 0, "Ширина графика в точках (в PNG — вдвое больше пикселей)")
+	height := fs.Float64("height", 0, "Высота графика в точках")
+	title := fs.String("title", "", "Заголовок (по умолчанию — название эксперимента)")
+	marks := fs.Bool("marks", true, "Наносить отметки, заметки, паузы и перерывы связи")
+	all := fs.Bool("all", false, "Строить и по строкам, принятым во время паузы")
+	source := fs.String("source", "", "Источник эксперимента с несколькими источниками (по умолчанию — первый)")
+	merge := fs.Bool("merge", false, "Строить по всем источникам, выровненным по времени первого")
+	tolerance := fs.Duration("tolerance", time.Second, "Допуск выравнивания для -merge")
+	id, err := parseID(fs, args)
+	if err != nil {
+		return err
+	}
+	e, err := recording.Catalog{Dir: *dir}.Find(id)
+	if err != nil {
+		return err
+	}
+
+	var t *export.Table
+	opts := export.Options{All: *all}
+	if *merge {
+		if e.Manifest == nil {
+			return fmt.Errorf("plot: у эксперимента %s нет манифеста и источников", e.ID)
+		}
+		t, err = loadMerged(e.Dir(), e.Manifest, opts, *tolerance)
+	} else {
+		var src *recording.Source
+		if src, err = e.Source(*source); err != nil {
+			return err
+		}
+		t, err = export.LoadSource(e.Dir(), e.Manifest, src, opts)
+	}
+	if err != nil {
+		return err
+	}
+	if t.Len() == 0 {
+		return fmt.Errorf("plot: в эксперименте %s нет данных", e.ID)
+	}
+
+	left := splitFields(*fields)
+	if left == nil && *right == "" {
+		for _, c := range t.Columns {
+			if c.Name != *profile {
+				left = append(left, c.Name)
+			}
+		}
+	}
+	var fig *plot.Figure
+	if *profile != "" {
+		fig, err = plot.Profile(t, *profile, append(left, splitFields(*right)...))
+	} else {
+		fig, err = plot.TimeSeries(t, left, splitFields(*right))
+		if err == nil && *marks {
+			fig.AddEvents(t)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if *width > 0 {
+		fig.Width = *width
+	}
+	if *height > 0 {
+		fig.Height = *height
+	}
+	if *title != "" {
+		fig.Title = *title
+	}
+
+	if *output == "" {
+		*output = e.ID + ".svg"
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := fig.Write(w, *output); err != nil {
+		f.Close()
+		os.Remove(*output)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "График: %s\n", *output)
+	return nil
+}
+
+// splitFields разбирает список полей через запятую.
+func splitFields(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,578 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/alarm"
+	"github.com/physicist2018/goserialcomm/pkg/derive"
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// journalEvery — интервал контрольных точек журнала.
+const journalEvery = 10 * time.Second
+
+// recorder — состояние записи эксперимента: файлы источников, манифест,
+// журнал и счётчики для команды status.
+type recorder struct {
+	exp     *Experiment
+	sources []*sourceWriter
+
+	// Строки копятся в буферах и сбрасываются на диск раз в syncEvery;
+	// при нуле — после каждой строки.
+	syncEvery   time.Duration
+	lastSync    time.Time
+	synced      []int64 // строк источников на диске после lastSync
+	lastJournal time.Time
+
+	// Отсчёт для скорости «с прошлого status».
+	statusTime time.Time
+	// quiet — не печатать каждую сохранённую строку (serve, -tui).
+	quiet bool
+	// watch получает разобранные строки (-tui).
+	watch func(source int, t time.Time, sample sensor.Sample)
+
+	// Автоматическая остановка по числу строк данных и условию.
+	samples    int64
+	maxSamples int64
+	condition  *stopCondition
+	autoStop   chan<- string
+	stopping   bool
+
+	// Ротация: новый сегмент, когда текущий превысил rotateBytes или
+	// начат раньше rotateEvery назад. Закрытые сегменты сжимаются и
+	// хэшируются в фоне, результаты приходят в closed.
+	rotateBytes int64
+	rotateEvery time.Duration
+	compress    string
+	closed      chan segmentResult
+	pending     sync.WaitGroup
+}
+
+// segmentResult — закрытый сегмент после сжатия и подсчёта суммы.
+type segmentResult struct {
+	source, segment int
+	file, sha256    string
+	err             error
+}
+
+// sourceWriter — файл данных одного источника.
+type sourceWriter struct {
+	info   *recording.Source
+	fields []sensor.Field
+	file   *os.File
+	size   *countingWriter
+	writer *recording.Writer
+	// deriver дописывает к строке производные поля; nil — не нужны.
+	deriver *derive.Deriver
+	// checker ставит флаги качества по правилам оператора; флаги моста
+	// сохраняются, если checker нет, а qc включён.
+	checker *qc.Checker
+	qc      bool
+	seq     int64
+	segSeq  int64 // seq в начале текущего сегмента
+
+	last     record    // последняя принятая строка
+	lastData time.Time // время последней разобранной строки
+	lastText string
+
+	statusLines int64
+}
+
+func newRecorder(files []*os.File, specs []SourceSpec, exp *Experiment, spec *RunSpec, autoStop chan<- string) *recorder {
+	r := &recorder{
+		exp:        exp,
+		syncEvery:  *spec.SyncInterval,
+		statusTime: time.Now(),
+		maxSamples: spec.Samples,
+		condition:  spec.Condition,
+		autoStop:   autoStop,
+
+		rotateBytes: spec.RotateBytes,
+		rotateEvery: spec.RotateEvery,
+		compress:    spec.Compress,
+		closed:      make(chan segmentResult),
+	}
+	for i, info := range exp.AllSources() {
+		s := &sourceWriter{info: info, fields: specs[i].fields(), deriver: specs[i].deriver, qc: exp.QC != nil}
+		if spec.QCRules != nil {
+			s.checker = qc.NewChecker(spec.QCRules)
+		}
+		s.open(files[i])
+		r.sources = append(r.sources, s)
+	}
+	return r
+}
+
+// countingWriter считает байты, записанные в файл.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// open начинает запись в файл с уже записанным заголовком.
+func (s *sourceWriter) open(file *os.File) {
+	s.file = file
+	s.size = &countingWriter{w: file}
+	if info, err := file.Stat(); err == nil {
+		s.size.n = info.Size()
+	}
+	s.writer = recording.NewWriter(s.size, s.fields)
+	s.writer.QC = s.qc
+	s.segSeq = s.seq
+}
+
+// label — подпись источника в выводе; у единственного источника пуста.
+func (s *sourceWriter) label() string {
+	if s.info.Name == "" {
+		return ""
+	}
+	return "[" + s.info.Name + "] "
+}
+
+// save записывает принятую строку или событие сборщика.
+func (r *recorder) save(rec record) {
+	s := r.sources[rec.Source]
+	r.rotateIfNeeded(rec.Source, rec.Time)
+	if rec.Event != "" {
+		// Строка-маркер и перерыв в манифесте
+		r.trackGap(s, rec)
+		s.write(recording.Row{Time: rec.Time, Event: rec.Event}, rec.Event)
+		s.sync()
+		return
+	}
+	if e, ok := alarm.ParseStatus(rec.Line); ok {
+		// Тревога моста — событие, а не строка данных
+		event := recording.FormatEvent(recording.EventAlarm, fmt.Sprintf("%s=%s %s", e.Rule, e.State, e.Text))
+		s.write(recording.Row{Time: rec.Time, Event: event}, event)
+		s.sync()
+		return
+	}
+
+	// Разбор строки; неразобранные строки сохраняются только в raw.
+	// Ошибкой разбора считаются только искажённые строки данных, а не
+	// баннер прошивки и сообщения моста.
+	s.seq++
+	sample, err := sensor.ParseLine(rec.Line)
+	if errors.Is(err, sensor.ErrBadData) {
+		s.info.ParseErrors++
+	}
+	if err == nil {
+		s.lastData, s.lastText = rec.Time, sample.Text
+		if s.deriver != nil {
+			s.deriver.Apply(sample.Values)
+		}
+		if s.checker != nil {
+			sample.Flags = s.checker.Check(rec.Time, sample.Values)
+		}
+	}
+	s.info.Lines = s.seq
+	s.last = rec
+
+	echo := rec.Line
+	switch {
+	case r.quiet:
+		echo = ""
+	case r.exp.OpenPause() != nil:
+		echo = "(пауза) " + echo
+	}
+	s.write(recording.Row{Time: rec.Time, Seq: s.seq, Sample: sample, Raw: rec.Line}, echo)
+	if r.syncEvery == 0 {
+		s.sync()
+	}
+
+	if err == nil {
+		if r.watch != nil {
+			r.watch(rec.Source, rec.Time, sample)
+		}
+		r.checkAutoStop(rec.Time, sample)
+	}
+}
+
+// checkAutoStop проверяет число строк данных (всех источников) и
+// условие остановки.
+func (r *recorder) checkAutoStop(t time.Time, sample sensor.Sample) {
+	r.samples++
+	switch {
+	case r.stopping:
+	case r.maxSamples > 0 && r.samples >= r.maxSamples:
+		fmt.Printf("Записано %d строк данных. Останавливаем эксперимент...\n", r.samples)
+		r.stop(recording.StopSamples)
+	case r.condition != nil && r.condition.Check(t, sample):
+		fmt.Printf("Выполнено условие остановки %q. Останавливаем эксперимент...\n", r.condition.String())
+		r.stop(recording.StopCondition)
+	}
+}
+
+func (r *recorder) stop(reason string) {
+	r.stopping = true
+	select {
+	case r.autoStop <- reason:
+	default:
+	}
+}
+
+// write пишет строку в буфер файла.
+func (s *sourceWriter) write(row recording.Row, echo string) {
+	if err := s.writer.Write(row); err != nil {
+		log.Printf("Ошибка записи в файл: %v", err)
+	} else if echo != "" {
+		fmt.Printf("Сохранено: %s%s\n", s.label(), echo)
+	}
+}
+
+// sync сбрасывает буфер в файл и файл на диск.
+func (s *sourceWriter) sync() {
+	if err := s.writer.Flush(); err != nil {
+		log.Printf("Ошибка записи в файл: %v", err)
+	}
+	// Синхронизация с диском
+	s.file.Sync()
+}
+
+// writeEvent пишет событие оператора (пауза, метка) во все файлы и
+// сразу сбрасывает их на диск.
+func (r *recorder) writeEvent(t time.Time, event string) {
+	for _, s := range r.sources {
+		s.write(recording.Row{Time: t, Event: event}, event)
+		s.sync()
+	}
+}
+
+// checkpoint сбрасывает файлы на диск, если подошёл интервал, и не
+// чаще раза в journalEvery отмечает в журнале, сколько строк сохранено.
+func (r *recorder) checkpoint(now time.Time) {
+	if now.Sub(r.lastSync) >= r.syncEvery {
+		r.syncAll()
+		r.lastSync = now
+	}
+	if now.Sub(r.lastJournal) < journalEvery {
+		return
+	}
+	r.lastJournal = now
+	err := r.exp.journal.Append(recording.JournalEntry{Time: now, Event: recording.JournalSync, Lines: r.synced})
+	if err != nil {
+		log.Printf("Ошибка записи журнала: %v", err)
+	}
+}
+
+// syncAll сбрасывает на диск файлы всех источников.
+func (r *recorder) syncAll() {
+	r.synced = r.synced[:0]
+	for _, s := range r.sources {
+		s.sync()
+		r.synced = append(r.synced, s.info.Lines)
+	}
+}
+
+// rotateIfNeeded начинает новый сегмент источника, если текущий
+// достиг порога. Размер учитывает только сброшенные из буфера данные,
+// поэтому сегмент может превысить rotateBytes на размер буфера.
+func (r *recorder) rotateIfNeeded(i int, t time.Time) {
+	s := r.sources[i]
+	n := len(s.info.Segments)
+	if n == 0 {
+		return
+	}
+	seg := s.info.Segments[n-1]
+	if (r.rotateBytes <= 0 || s.size.n < r.rotateBytes) &&
+		(r.rotateEvery <= 0 || t.Sub(seg.Start) < r.rotateEvery) {
+		return
+	}
+	if err := r.rotate(i, t); err != nil {
+		log.Printf("Ошибка ротации %s: %v", s.file.Name(), err)
+	}
+}
+
+// rotate закрывает текущий сегмент источника и открывает следующий.
+func (r *recorder) rotate(i int, t time.Time) error {
+	s := r.sources[i]
+	n := len(s.info.Segments)
+	name := recording.SegmentName(s.info.DataFile, n+1)
+	file, err := createDataFile(r.exp.path(name), s.fields, r.exp.QC != nil)
+	if err != nil {
+		return err
+	}
+	r.closeSegment(i, t)
+	s.open(file)
+	s.info.Segments = append(s.info.Segments, recording.Segment{File: name, Start: t})
+	r.saveManifest()
+	fmt.Printf("%sНовый сегмент: %s\n", s.label(), name)
+	return nil
+}
+
+// closeSegment закрывает файл текущего сегмента источника и запускает
+// его сжатие и подсчёт контрольной суммы.
+func (r *recorder) closeSegment(i int, t time.Time) {
+	s := r.sources[i]
+	s.sync()
+	if err := s.file.Close(); err != nil {
+		log.Printf("Ошибка закрытия %s: %v", s.file.Name(), err)
+	}
+	n := len(s.info.Segments) - 1
+	seg := &s.info.Segments[n]
+	end := t
+	seg.End = &end
+	seg.Lines = s.seq - s.segSeq
+
+	path := s.file.Name()
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		res := segmentResult{source: i, segment: n, file: path}
+		if r.compress != "" {
+			res.file, res.err = recording.CompressFile(path, r.compress)
+		}
+		if res.err == nil {
+			res.sha256, res.err = recording.FileSHA256(res.file)
+		}
+		r.closed <- res
+	}()
+}
+
+// segmentClosed вносит в манифест сжатый файл и контрольную сумму
+// закрытого сегмента.
+func (r *recorder) segmentClosed(res segmentResult) {
+	seg := &r.sources[res.source].info.Segments[res.segment]
+	if res.err != nil {
+		log.Printf("Ошибка обработки сегмента %s: %v", seg.File, res.err)
+		return
+	}
+	seg.File, seg.SHA256 = filepath.Base(res.file), res.sha256
+	r.saveManifest()
+}
+
+// close закрывает файлы источников в конце записи и дожидается
+// обработки всех закрытых сегментов.
+func (r *recorder) close(end time.Time) {
+	for i, s := range r.sources {
+		if len(s.info.Segments) > 0 {
+			r.closeSegment(i, end)
+			continue
+		}
+		if err := s.file.Close(); err != nil {
+			log.Printf("Ошибка закрытия %s: %v", s.file.Name(), err)
+		}
+	}
+	go func() {
+		r.pending.Wait()
+		close(r.closed)
+	}()
+	for res := range r.closed {
+		r.segmentClosed(res)
+	}
+}
+
+// tick — период вызова checkpoint.
+func (r *recorder) tick() time.Duration {
+	if r.syncEvery > 0 && r.syncEvery < journalEvery {
+		return r.syncEvery
+	}
+	return journalEvery
+}
+
+// trackGap отмечает начало и конец перерыва источника в манифесте и
+// сразу сохраняет его, чтобы перерыв был виден и во время эксперимента.
+func (r *recorder) trackGap(s *sourceWriter, rec record) {
+	kind, reason := recording.ParseEvent(rec.Event)
+	switch kind {
+	case recording.EventGapStart:
+		s.info.Gaps = append(s.info.Gaps, recording.Gap{Start: rec.Time, Reason: reason})
+	case recording.EventGapEnd:
+		if g := s.info.OpenGap(); g != nil {
+			end := rec.Time
+			g.End = &end
+		}
+	}
+	r.saveManifest()
+}
+
+// execute выполняет команду консоли.
+func (r *recorder) execute(cmd command) {
+	switch cmd.Name {
+	case cmdPause:
+		if r.exp.OpenPause() != nil {
+			fmt.Println("Запись уже на паузе")
+			return
+		}
+		r.exp.Pauses = append(r.exp.Pauses, recording.Interval{Start: cmd.Time})
+		r.saveManifest()
+		r.writeEvent(cmd.Time, recording.EventPause)
+		fmt.Println("Пауза: данные пишутся, но помечены исключёнными. Для продолжения введите 'resume'")
+
+	case cmdResume:
+		p := r.exp.OpenPause()
+		if p == nil {
+			fmt.Println("Запись не на паузе")
+			return
+		}
+		end := cmd.Time
+		p.End = &end
+		r.saveManifest()
+		r.writeEvent(cmd.Time, recording.EventResume)
+		fmt.Printf("Запись продолжена, пауза длилась %v\n", end.Sub(p.Start).Round(time.Second))
+
+	case cmdMark:
+		r.writeEvent(cmd.Time, recording.FormatEvent(recording.EventMark, cmd.Text))
+
+	case cmdNote:
+		r.exp.Notes = append(r.exp.Notes, recording.Note{Time: cmd.Time, Text: cmd.Text})
+		r.saveManifest()
+		fmt.Println("Заметка добавлена в манифест")
+
+	case cmdStatus:
+		if cmd.Reply != nil {
+			cmd.Reply <- r.status(cmd.Time)
+			return
+		}
+		r.printStatus(cmd.Time)
+	}
+}
+
+// printStatus печатает состояние записи.
+func (r *recorder) printStatus(now time.Time) {
+	fmt.Println("--- Состояние эксперимента ---")
+	fmt.Printf("Идёт: %v\n", now.Sub(r.exp.Start).Round(time.Second))
+	if p := r.exp.OpenPause(); p != nil {
+		fmt.Printf("НА ПАУЗЕ с %s\n", p.Start.Format("15:04:05"))
+	}
+
+	// За доли секунды скорость не имеет смысла
+	elapsed := now.Sub(r.statusTime).Seconds()
+	for _, s := range r.sources {
+		if s.info.Name != "" {
+			fmt.Printf("Источник %s (%s):\n", s.info.Name, s.info.Address)
+		}
+		s.printStatus(now, r.exp.Start, elapsed)
+	}
+	if elapsed >= 1 {
+		r.statusTime = now
+	}
+}
+
+func (s *sourceWriter) printStatus(now, start time.Time, elapsed float64) {
+	if g := s.info.OpenGap(); g != nil {
+		fmt.Printf("НЕТ СВЯЗИ с %s: %s\n", g.Start.Format("15:04:05"), g.Reason)
+	}
+	fmt.Printf("Строк: %d, ошибок разбора: %d\n", s.info.Lines, s.info.ParseErrors)
+
+	if elapsed >= 1 {
+		fmt.Printf("Скорость: %.2f строк/с (с прошлого status), средняя %.2f строк/с\n",
+			float64(s.info.Lines-s.statusLines)/elapsed, float64(s.info.Lines)/now.Sub(start).Seconds())
+		s.statusLines = s.info.Lines
+	}
+
+	if s.lastText != "" {
+		fmt.Printf("Последнее значение: %s (%v назад)\n", s.lastText, now.Sub(s.lastData).Round(time.Second))
+	} else {
+		fmt.Println("Последнее значение: нет")
+	}
+	if s.last.Line != "" && s.last.Time.After(s.lastData) {
+		fmt.Printf("Последняя строка: %s (%v назад)\n", s.last.Line, now.Sub(s.last.Time).Round(time.Second))
+	}
+
+	if info, err := s.file.Stat(); err == nil {
+		segment := ""
+		if n := len(s.info.Segments); n > 0 {
+			segment = fmt.Sprintf(" (сегмент %d)", n)
+		}
+		fmt.Printf("Файл: %s%s, %s\n", s.file.Name(), segment, formatSize(info.Size()))
+	}
+}
+
+// runStatus — состояние записи для HTTP API.
+type runStatus struct {
+	ID      string         `json:"id"`
+	Name    string         `json:"name"`
+	Start   time.Time      `json:"start"`
+	Elapsed float64        `json:"elapsed_sec"`
+	Paused  bool           `json:"paused"`
+	Samples int64          `json:"samples"`
+	Sources []sourceStatus `json:"sources"`
+}
+
+type sourceStatus struct {
+	Name        string         `json:"name,omitempty"`
+	Address     string         `json:"address"`
+	Lines       int64          `json:"lines"`
+	ParseErrors int64          `json:"parse_errors"`
+	Rate        float64        `json:"rate"` // средняя, строк/с
+	LastValue   string         `json:"last_value,omitempty"`
+	LastData    *time.Time     `json:"last_data,omitempty"`
+	Gap         *recording.Gap `json:"gap,omitempty"` // идущий перерыв связи
+	File        string         `json:"file"`
+	Size        int64          `json:"size"`
+}
+
+// status возвращает состояние записи на момент now.
+func (r *recorder) status(now time.Time) runStatus {
+	elapsed := now.Sub(r.exp.Start).Seconds()
+	st := runStatus{
+		ID:      r.exp.Base,
+		Name:    r.exp.Name,
+		Start:   r.exp.Start,
+		Elapsed: elapsed,
+		Paused:  r.exp.OpenPause() != nil,
+		Samples: r.samples,
+	}
+	for _, s := range r.sources {
+		ss := sourceStatus{
+			Name:        s.info.Name,
+			Address:     s.info.Address,
+			Lines:       s.info.Lines,
+			ParseErrors: s.info.ParseErrors,
+			LastValue:   s.lastText,
+			File:        filepath.Base(s.file.Name()),
+		}
+		if g := s.info.OpenGap(); g != nil {
+			gap := *g
+			ss.Gap = &gap
+		}
+		if elapsed > 0 {
+			ss.Rate = float64(s.info.Lines) / elapsed
+		}
+		if !s.lastData.IsZero() {
+			t := s.lastData
+			ss.LastData = &t
+		}
+		if info, err := s.file.Stat(); err == nil {
+			ss.Size = info.Size()
+		}
+		st.Sources = append(st.Sources, ss)
+	}
+	return st
+}
+
+func (r *recorder) saveManifest() {
+	if err := writeManifest(r.exp); err != nil {
+		log.Printf("Ошибка записи манифеста: %v", err)
+	}
+}
+
+// formatSize печатает размер файла в двоичных единицах.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d Б", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %sиБ", float64(n)/float64(div), []string{"К", "М", "Г", "Т"}[exp])
+}
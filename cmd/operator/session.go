@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// session — идущий эксперимент: сборщики источников, горутина записи и
+// каналы, через которые ими управляют консоль или HTTP API.
+type session struct {
+	exp  *Experiment
+	spec *RunSpec
+	rec  *recorder
+
+	commands chan command
+	autoStop chan string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	end    time.Time // время остановки, после halt
+}
+
+// sessionOptions — как запись показывает принятые строки.
+type sessionOptions struct {
+	// quiet отключает вывод каждой сохранённой строки.
+	quiet bool
+	// watch получает разобранные строки источников (для -tui); его
+	// вызывает горутина записи.
+	watch func(source int, t time.Time, sample sensor.Sample)
+}
+
+// startSession создаёт файлы эксперимента, подключается к источникам и
+// запускает запись. Недостающие адреса источников спрашиваются у
+// пользователя.
+func startSession(spec *RunSpec, experiment *Experiment, opts sessionOptions) (*session, error) {
+	if _, err := os.Stat(experiment.ManifestFile()); err == nil {
+		return nil, fmt.Errorf("эксперимент %s уже существует", experiment.Base)
+	}
+
+	// Создание файлов эксперимента, по одному на источник; если
+	// запись не началась, они удаляются
+	sources := spec.sourceList()
+	files, err := createExperimentFiles(experiment, sources)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка создания файла: %w", err)
+	}
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+
+	// Подключение к удаленным серверам
+	conns := make([]connection, len(sources))
+	for i, src := range experiment.AllSources() {
+		address, err := getServerAddress(sources[i].Server)
+		if err != nil {
+			closeFiles()
+			return nil, fmt.Errorf("Ошибка ввода: %w", err)
+		}
+		if conns[i], err = connectToRemoteServer(address); err != nil {
+			for _, c := range conns[:i] {
+				c.Close()
+			}
+			closeFiles()
+			return nil, fmt.Errorf("Ошибка подключения к %s: %w", address, err)
+		}
+		src.Address = address
+	}
+
+	if err := writeManifest(experiment); err != nil {
+		log.Printf("Ошибка записи манифеста: %v", err)
+	}
+	if experiment.journal, err = startJournal(experiment); err != nil {
+		for _, c := range conns {
+			c.Close()
+		}
+		closeFiles()
+		return nil, fmt.Errorf("Ошибка создания журнала: %w", err)
+	}
+
+	// Создание контекста для управления горутинами
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &session{
+		exp:      experiment,
+		spec:     spec,
+		commands: make(chan command),
+		autoStop: make(chan string, 1),
+		cancel:   cancel,
+	}
+	dataChan := make(chan record, 100) // Буферизованный канал
+
+	// Запуск сбора данных; канал закрывается, когда остановлены все
+	// сборщики
+	var collectors sync.WaitGroup
+	for i, src := range experiment.AllSources() {
+		collectors.Add(1)
+		go func(i int, conn connection, address string) {
+			defer collectors.Done()
+			collectData(ctx, i, conn, address, dataChan)
+		}(i, conns[i], src.Address)
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		collectors.Wait()
+		close(dataChan) // Закрываем канал при завершении
+	}()
+
+	// Запуск сохранения данных
+	s.rec = newRecorder(files, sources, experiment, spec, s.autoStop)
+	s.rec.quiet, s.rec.watch = opts.quiet, opts.watch
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		saveDataToFile(ctx, dataChan, s.commands, s.rec)
+	}()
+	return s, nil
+}
+
+// finish останавливает запись по причине reason, закрывает файлы и
+// завершает эксперимент.
+func (s *session) finish(reason string) error {
+	s.halt(reason)
+	return s.finalize()
+}
+
+// halt останавливает сборщики и запись и закрывает файлы.
+func (s *session) halt(reason string) {
+	s.exp.StopReason = reason
+	s.cancel()
+
+	// Ожидаем завершения всех горутин
+	s.wg.Wait()
+
+	// Закрываем файлы (соединения закрывает collectData); файлы
+	// открытых сегментов меняются при ротации, их закрывает recorder
+	s.end = time.Now()
+	s.rec.close(s.end)
+}
+
+// finalize завершает остановленный эксперимент: сводки, контрольные
+// суммы, манифест.
+func (s *session) finalize() error {
+	// Журнал нужен только незавершённому эксперименту
+	if err := finalizeExperiment(s.exp, s.end, s.spec); err != nil {
+		s.exp.journal.Close()
+		return err
+	}
+	if err := s.exp.journal.Remove(); err != nil {
+		log.Printf("Ошибка удаления журнала: %v", err)
+	}
+	return nil
+}
+
+// waitForStop ждёт запроса остановки из stop, истечения duration или
+// until либо условия автоматической остановки. Возвращает причину
+// остановки.
+func (s *session) waitForStop(stop <-chan string) string {
+	spec := s.spec
+	var timeout, until <-chan time.Time
+	if spec.Duration > 0 {
+		timer := time.NewTimer(spec.Duration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	if !spec.UntilTime.IsZero() {
+		timer := time.NewTimer(time.Until(spec.UntilTime))
+		defer timer.Stop()
+		until = timer.C
+	}
+
+	select {
+	case reason := <-stop:
+		return reason
+	case <-timeout:
+		fmt.Printf("%s: время эксперимента истекло. Останавливаем эксперимент...\n", s.exp.Base)
+		return recording.StopDuration
+	case <-until:
+		fmt.Printf("%s: наступило время остановки %s. Останавливаем эксперимент...\n",
+			s.exp.Base, spec.UntilTime.Format("2006-01-02 15:04:05"))
+		return recording.StopUntil
+	case reason := <-s.autoStop:
+		return reason
+	}
+}
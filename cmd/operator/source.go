@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/physicist2018/goserialcomm/pkg/serialport"
+)
+
+// Адрес источника — host:port или URL:
+//
+//	tcp://192.168.1.10:8080             поток строк моста по TCP
+//	ws://192.168.1.10:8081/ws           WebSocket моста (wss:// — через TLS)
+//	http://192.168.1.10:8081/events     Server-Sent Events моста (и https://)
+//	serial:///dev/ttyUSB0?baud=9600     последовательный порт напрямую, без моста
+//
+// Строки всех источников разбираются и записываются одинаково.
+
+// dialTimeout — время на подключение к источнику.
+const dialTimeout = 10 * time.Second
+
+// defaultBaud — скорость порта serial:// по умолчанию, как у прошивки.
+const defaultBaud = 9600
+
+// connection — подключение к источнику строк.
+type connection interface {
+	// readLines читает строки до ошибки или отмены ctx и передаёт их
+	// handle; false из handle прекращает чтение.
+	readLines(ctx context.Context, handle func(line string) bool) error
+	Close() error
+}
+
+// connectToRemoteServer подключается к источнику по адресу.
+func connectToRemoteServer(address string) (connection, error) {
+	if !strings.Contains(address, "://") {
+		return dialTCP(address)
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "tcp":
+		return dialTCP(u.Host)
+	case "ws", "wss":
+		return dialWebSocket(u)
+	case "http", "https":
+		return dialEvents(u)
+	case "serial":
+		return openSerial(u)
+	}
+	return nil, fmt.Errorf("неизвестная схема адреса %q (tcp, ws, wss, http, https, serial)", u.Scheme)
+}
+
+// tcpConn — поток строк моста по TCP.
+type tcpConn struct {
+	net.Conn
+}
+
+func dialTCP(address string) (connection, error) {
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return tcpConn{conn}, nil
+}
+
+func (c tcpConn) readLines(ctx context.Context, handle func(line string) bool) error {
+	reader := bufio.NewReader(c.Conn)
+	var partial string
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		// Устанавливаем таймаут на чтение
+		c.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+
+		data, err := reader.ReadString('\n')
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				// Таймаут - это нормально; начало строки сохраняем
+				// до следующего чтения
+				partial += data
+				continue
+			}
+			if err == io.EOF {
+				return fmt.Errorf("источник закрыл соединение")
+			}
+			return err
+		}
+
+		data = partial + data
+		partial = ""
+		if !handleLines(data, handle) {
+			return ctx.Err()
+		}
+	}
+}
+
+// handleLines передаёт handle непустые строки текста. Возвращает false,
+// если handle попросил прекратить чтение.
+func handleLines(text string, handle func(line string) bool) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if !handle(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// closeOnCancel закрывает c при отмене ctx, чтобы прервать блокирующее
+// чтение. Возвращённую функцию нужно вызвать по окончании чтения.
+func closeOnCancel(ctx context.Context, c io.Closer) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// readStream читает строки из потока; общий случай для SSE и порта.
+func readStream(ctx context.Context, r io.ReadCloser, handle func(line string) bool) error {
+	defer closeOnCancel(ctx, r)()
+	reader := bufio.NewReader(r)
+	for {
+		data, err := reader.ReadString('\n')
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// При обрыве недописанная строка отбрасывается, как в tcpConn
+		if err == nil && !handleLines(data, handle) {
+			return ctx.Err()
+		}
+		if err == io.EOF {
+			return fmt.Errorf("источник закрыл соединение")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// wsConn — WebSocket моста (/ws): одно сообщение на строку.
+type wsConn struct {
+	*websocket.Conn
+}
+
+func dialWebSocket(u *url.URL) (connection, error) {
+	dialer := websocket.Dialer{
+		HandshakeTimeout: dialTimeout,
+		Proxy:            http.ProxyFromEnvironment,
+	}
+	conn, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return wsConn{conn}, nil
+}
+
+func (c wsConn) readLines(ctx context.Context, handle func(line string) bool) error {
+	defer closeOnCancel(ctx, c.Conn)()
+	for {
+		_, data, err := c.ReadMessage()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return fmt.Errorf("источник закрыл соединение")
+			}
+			return err
+		}
+		if !handleLines(string(data), handle) {
+			return ctx.Err()
+		}
+	}
+}
+
+// eventsConn — поток Server-Sent Events моста (/events). Строка данных
+// — поле data события; события из нескольких полей data дают несколько
+// строк.
+type eventsConn struct {
+	body io.ReadCloser
+}
+
+func dialEvents(u *url.URL) (connection, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: dialTimeout}).DialContext,
+		TLSHandshakeTimeout:   dialTimeout,
+		ResponseHeaderTimeout: dialTimeout,
+	}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: ожидался text/event-stream, получен %q", u, ct)
+	}
+	return eventsConn{resp.Body}, nil
+}
+
+func (c eventsConn) readLines(ctx context.Context, handle func(line string) bool) error {
+	return readStream(ctx, c.body, func(line string) bool {
+		// Комментарии (": ping"), event, id и retry пропускаются
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			return true
+		}
+		return handleLines(data, handle)
+	})
+}
+
+func (c eventsConn) Close() error { return c.body.Close() }
+
+// serialConn — последовательный порт без моста. Меток моста у строк нет,
+// source_ts остаётся пустым.
+type serialConn struct {
+	serialport.Port
+}
+
+func openSerial(u *url.URL) (connection, error) {
+	baud := defaultBaud
+	if s := u.Query().Get("baud"); s != "" {
+		var err error
+		if baud, err = strconv.Atoi(s); err != nil || baud <= 0 {
+			return nil, fmt.Errorf("%s: недопустимая скорость %q", u, s)
+		}
+	}
+	name := u.Path
+	if u.Host != "" {
+		name = u.Host + u.Path // serial://COM3
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%s: не указан порт", u)
+	}
+	port, err := serialport.Open(name, baud)
+	if err != nil {
+		return nil, err
+	}
+	return serialConn{port}, nil
+}
+
+func (c serialConn) readLines(ctx context.Context, handle func(line string) bool) error {
+	return readStream(ctx, c.Port, handle)
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+	"golang.org/x/term"
+)
+
+// Полноэкранный интерфейс записи (-tui): название и длительность
+// эксперимента, состояние связи, текущие значения полей с минимумом и
+// максимумом, спарклайны за последние минуты, скорость, предупреждения
+// о перерывах и строка команд. Всё, что запись печатает (переподключения,
+// ошибки, ответы на команды), попадает в область сообщений.
+
+const (
+	tuiRedraw     = 500 * time.Millisecond
+	tuiRateWindow = 10 * time.Second // скорость — за последние 10 с
+	tuiMessages   = 200              // хранимых строк сообщений
+)
+
+// sparkBlocks — уровни спарклайна снизу вверх.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// tui — экран записи.
+type tui struct {
+	out      *os.File // терминал; os.Stdout перенаправлен в pipe
+	oldState *term.State
+	stdout   *os.File
+	pipe     *os.File // читающий конец pipe
+	window   time.Duration
+
+	mu       sync.Mutex
+	name     string
+	start    time.Time
+	status   *runStatus // последнее состояние записи
+	sources  []*tuiSource
+	messages []string
+	input    []rune
+
+	redraw chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+type tuiSource struct {
+	name   string
+	fields []*tuiField
+	recent []time.Time // строки данных за tuiRateWindow
+}
+
+type tuiField struct {
+	sensor.Field
+	seen            bool
+	value, min, max float64
+	points          []tuiPoint // за окно спарклайна
+}
+
+type tuiPoint struct {
+	t time.Time
+	v float64
+}
+
+// newTUI переключает терминал в полноэкранный режим и перенаправляет
+// вывод записи в область сообщений. window — период спарклайнов.
+// Вызывать до запуска записи: os.Stdout подменяется.
+func newTUI(exp *Experiment, specs []SourceSpec, window time.Duration) (*tui, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil, fmt.Errorf("-tui: ввод и вывод должны быть терминалом")
+	}
+	t := &tui{
+		out:    os.Stdout,
+		window: window,
+		name:   exp.Name,
+		start:  exp.Start,
+		redraw: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	for i, src := range exp.AllSources() {
+		ts := &tuiSource{name: src.Name}
+		for _, f := range specs[i].fields() {
+			ts.fields = append(ts.fields, &tuiField{Field: f})
+		}
+		t.sources = append(t.sources, ts)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	if t.oldState, err = term.MakeRaw(int(os.Stdin.Fd())); err != nil {
+		r.Close()
+		w.Close()
+		return nil, err
+	}
+	t.pipe, t.stdout = r, w
+	os.Stdout = w
+	log.SetOutput(w)
+
+	// Альтернативный экран, как у less и top
+	fmt.Fprint(t.out, "\x1b[?1049h\x1b[2J")
+	t.wg.Add(1)
+	go t.readMessages()
+	return t, nil
+}
+
+// observe учитывает разобранную строку источника; вызывается горутиной
+// записи через sessionOptions.watch.
+func (t *tui) observe(source int, at time.Time, sample sensor.Sample) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	src := t.sources[source]
+	src.recent = append(trimTimes(src.recent, at.Add(-tuiRateWindow)), at)
+	for _, f := range src.fields {
+		v, ok := sample.Value(f.Name)
+		if !ok {
+			continue
+		}
+		if !f.seen {
+			f.min, f.max, f.seen = v, v, true
+		}
+		f.value, f.min, f.max = v, math.Min(f.min, v), math.Max(f.max, v)
+		f.points = append(trimPoints(f.points, at.Add(-t.window)), tuiPoint{at, v})
+	}
+}
+
+func trimTimes(ts []time.Time, from time.Time) []time.Time {
+	i := 0
+	for i < len(ts) && ts[i].Before(from) {
+		i++
+	}
+	return append(ts[:0], ts[i:]...)
+}
+
+func trimPoints(ps []tuiPoint, from time.Time) []tuiPoint {
+	i := 0
+	for i < len(ps) && ps[i].t.Before(from) {
+		i++
+	}
+	if i == 0 {
+		return ps
+	}
+	return append(ps[:0], ps[i:]...)
+}
+
+// run перерисовывает экран, пока не вызван close. Состояние записи
+// запрашивается командой status, как в HTTP API.
+func (t *tui) run(commands chan<- command) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(tuiRedraw)
+		defer ticker.Stop()
+		for {
+			t.draw()
+			select {
+			case <-t.done:
+				return
+			case <-t.redraw:
+			case now := <-ticker.C:
+				reply := make(chan runStatus, 1)
+				select {
+				case commands <- command{Name: cmdStatus, Time: now, Reply: reply}:
+					st := <-reply
+					t.mu.Lock()
+					t.status = &st
+					t.mu.Unlock()
+				case <-t.done:
+					return
+				}
+			}
+		}
+	}()
+}
+
+// readKeys читает строку команд с клавиатуры; по сигнатуре и действию
+// соответствует readConsole. Ctrl+C — то же, что stop.
+func (t *tui) readKeys(ctx context.Context, stop chan<- string, commands chan<- command) {
+	in := bufio.NewReader(os.Stdin)
+	escape := false
+	for {
+		r, _, err := in.ReadRune()
+		if err != nil {
+			if err != io.EOF {
+				t.message(fmt.Sprintf("Ошибка чтения ввода: %v", err))
+			}
+			return
+		}
+
+		// Стрелки и прочие ESC-последовательности пропускаются
+		if escape {
+			if r != '[' && r != 'O' && (r >= '@' && r <= '~') {
+				escape = false
+			}
+			continue
+		}
+
+		var line string
+		enter := false
+		t.mu.Lock()
+		switch r {
+		case 27:
+			escape = true
+		case 3: // Ctrl+C
+			line, enter = cmdStop, true
+		case '\r', '\n':
+			line, enter = string(t.input), true
+			t.input = t.input[:0]
+		case 127, 8:
+			if len(t.input) > 0 {
+				t.input = t.input[:len(t.input)-1]
+			}
+		case 21: // Ctrl+U
+			t.input = t.input[:0]
+		default:
+			if r >= ' ' {
+				t.input = append(t.input, r)
+			}
+		}
+		t.mu.Unlock()
+		t.kick()
+
+		if !enter || strings.TrimSpace(line) == "" {
+			continue
+		}
+		cmd, err := parseCommand(line)
+		switch {
+		case err != nil:
+			t.message(err.Error())
+		case cmd.Name == cmdStop:
+			t.message("Останавливаем эксперимент...")
+			select {
+			case stop <- recording.StopCommand:
+			case <-ctx.Done():
+			}
+			return
+		case cmd.Name == cmdHelp:
+			for _, l := range strings.Split(consoleHelp, "\n") {
+				t.message(l)
+			}
+		default:
+			t.message("> " + line)
+			select {
+			case commands <- cmd:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// readMessages переносит вывод записи в область сообщений.
+func (t *tui) readMessages() {
+	defer t.wg.Done()
+	sc := bufio.NewScanner(t.pipe)
+	for sc.Scan() {
+		if line := strings.TrimRight(sc.Text(), "\r"); line != "" {
+			t.message(line)
+		}
+	}
+}
+
+func (t *tui) message(line string) {
+	line = strings.ReplaceAll(line, "\t", "  ")
+	t.mu.Lock()
+	t.messages = append(t.messages, line)
+	if n := len(t.messages); n > tuiMessages {
+		t.messages = append(t.messages[:0], t.messages[n-tuiMessages:]...)
+	}
+	t.mu.Unlock()
+	t.kick()
+}
+
+// kick просит перерисовать экран.
+func (t *tui) kick() {
+	select {
+	case t.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// close возвращает терминал и вывод в исходное состояние. Вызывать
+// после остановки записи.
+func (t *tui) close() {
+	close(t.done)
+	os.Stdout = t.out
+	log.SetOutput(os.Stderr)
+	t.stdout.Close()
+	t.wg.Wait()
+	t.pipe.Close()
+
+	fmt.Fprint(t.out, "\x1b[?1049l")
+	term.Restore(int(os.Stdin.Fd()), t.oldState)
+
+	// Последние сообщения остаются видны после выхода
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	from := len(t.messages) - 10
+	if from < 0 {
+		from = 0
+	}
+	for _, m := range t.messages[from:] {
+		fmt.Fprintln(t.out, m)
+	}
+}
+
+// ANSI: цвет предупреждений и сброс.
+const (
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[1;33m"
+	ansiBold   = "\x1b[1m"
+	ansiReset  = "\x1b[0m"
+)
+
+// draw рисует экран целиком.
+func (t *tui) draw() {
+	width, height, err := term.GetSize(int(t.out.Fd()))
+	if err != nil || width < 20 || height < 5 {
+		width, height = 80, 24
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+
+	var lines []string
+	add := func(style, s string) {
+		s = fitWidth(s, width)
+		if style != "" {
+			s = style + s + ansiReset
+		}
+		lines = append(lines, s)
+	}
+
+	header := fmt.Sprintf(" %s   идёт %s", t.name, formatElapsed(now.Sub(t.start)))
+	if t.status != nil && t.status.Paused {
+		header += "   [ПАУЗА: данные исключаются]"
+	}
+	add(ansiBold, header)
+	add("", strings.Repeat("─", width))
+
+	for i, src := range t.sources {
+		var st *sourceStatus
+		if t.status != nil && i < len(t.status.Sources) {
+			st = &t.status.Sources[i]
+		}
+		t.drawSource(add, src, st, now, width)
+		add("", "")
+	}
+
+	// Сообщения занимают остаток экрана над строкой команд
+	room := height - len(lines) - 2
+	if room > 0 {
+		from := len(t.messages) - room
+		if from < 0 {
+			from = 0
+		}
+		for _, m := range t.messages[from:] {
+			add("", m)
+		}
+		for i := len(t.messages) - from; i < room; i++ {
+			add("", "")
+		}
+	}
+	if len(lines) > height-2 {
+		lines = lines[:height-2]
+	}
+	add("", strings.Repeat("─", width))
+	prompt := "> " + string(t.input)
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(l)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\r\n")
+	b.WriteString(fitWidth(prompt, width))
+	b.WriteString("\x1b[K\x1b[J")
+	fmt.Fprint(t.out, b.String())
+}
+
+// drawSource рисует источник: связь, счётчики и таблицу полей.
+func (t *tui) drawSource(add func(style, s string), src *tuiSource, st *sourceStatus, now time.Time, width int) {
+	title := " Источник"
+	if src.name != "" {
+		title += " " + src.name
+	}
+	if st == nil {
+		add(ansiBold, title)
+	} else {
+		add(ansiBold, fmt.Sprintf("%s  %s", title, st.Address))
+		rate := float64(len(trimTimes(src.recent, now.Add(-tuiRateWindow)))) / tuiRateWindow.Seconds()
+		add("", fmt.Sprintf("   строк %d, ошибок разбора %d, %.2f строк/с, файл %s (%s)",
+			st.Lines, st.ParseErrors, rate, st.File, formatSize(st.Size)))
+		switch {
+		case st.Gap != nil:
+			add(ansiRed, fmt.Sprintf("   !!! НЕТ СВЯЗИ %s (с %s): %s — ДАННЫЕ НЕ ЗАПИСЫВАЮТСЯ",
+				formatElapsed(now.Sub(st.Gap.Start)), st.Gap.Start.Local().Format("15:04:05"), st.Gap.Reason))
+		case st.LastData == nil:
+			add(ansiYellow, "   нет данных")
+		case now.Sub(*st.LastData) > staleData(rate):
+			add(ansiYellow, fmt.Sprintf("   нет данных уже %v", now.Sub(*st.LastData).Round(time.Second)))
+		default:
+			add("", "   связь есть")
+		}
+	}
+
+	// Таблица: поле, единицы, значение, минимум, максимум, спарклайн
+	const cols = "   %-6s %-5s %10s %10s %10s  "
+	spark := width - len(fmt.Sprintf(cols, "", "", "", "", ""))
+	add("", fmt.Sprintf(cols+"за %v", "Поле", "Ед.", "Значение", "Мин", "Макс", t.window))
+	for _, f := range src.fields {
+		if !f.seen {
+			add("", fmt.Sprintf(cols, f.Name, f.Unit, "-", "-", "-"))
+			continue
+		}
+		add("", fmt.Sprintf(cols, f.Name, f.Unit, formatValue(f.value), formatValue(f.min), formatValue(f.max))+
+			sparkline(f.points, now, t.window, spark))
+	}
+}
+
+// staleData — сколько без данных при наличии связи считать
+// подозрительным: пять обычных интервалов, но не меньше 3 с.
+func staleData(rate float64) time.Duration {
+	d := 3 * time.Second
+	if rate > 0 {
+		if gap := time.Duration(5 / rate * float64(time.Second)); gap > d {
+			d = gap
+		}
+	}
+	return d
+}
+
+// sparkline рисует средние значения по width интервалам окна window,
+// кончающегося в now. Пустые интервалы — пробелы.
+func sparkline(points []tuiPoint, now time.Time, window time.Duration, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	sums := make([]float64, width)
+	counts := make([]int, width)
+	from := now.Add(-window)
+	for _, p := range points {
+		i := int(float64(p.t.Sub(from)) / float64(window) * float64(width))
+		if i < 0 || i >= width {
+			continue
+		}
+		sums[i] += p.v
+		counts[i]++
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= float64(counts[i])
+			lo, hi = math.Min(lo, sums[i]), math.Max(hi, sums[i])
+		}
+	}
+	var b strings.Builder
+	for i := range sums {
+		switch {
+		case counts[i] == 0:
+			b.WriteByte(' ')
+		case hi == lo:
+			b.WriteRune(sparkBlocks[len(sparkBlocks)/2])
+		default:
+			level := int((sums[i] - lo) / (hi - lo) * float64(len(sparkBlocks)-1))
+			b.WriteRune(sparkBlocks[level])
+		}
+	}
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// formatElapsed печатает длительность как 01:02:03.
+func formatElapsed(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// fitWidth обрезает строку до width символов.
+func fitWidth(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
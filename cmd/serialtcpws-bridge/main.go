@@ -8,11 +8,15 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"go.bug.st/serial"
+	"github.com/physicist2018/goserialcomm/pkg/alarm"
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+	"github.com/physicist2018/goserialcomm/pkg/serialport"
 )
 
 var (
@@ -21,6 +25,8 @@ var (
 	comPort        = flag.String("com", "COM1", "Адрес COM-порта")
 	baudRate       = flag.Int("baud", 9600, "Скорость передачи данных (baud rate)")
 	maxConnections = flag.Int("max-conn", 10, "Максимальное число одновременных соединений")
+	alarmsFlag     = flag.String("alarms", "", "YAML-файл правил тревог и обработчиков (команда, вебхук, журнал)")
+	qcFlag         = flag.String("qc", "", "Проверять качество данных и добавлять флаги QARTOD к строкам: YAML-файл правил или firmware (пределы прошивки)")
 )
 
 var upgrader = websocket.Upgrader{
@@ -32,6 +38,7 @@ var upgrader = websocket.Upgrader{
 type ClientManager struct {
 	tcpClients map[net.Conn]bool
 	wsClients  map[*websocket.Conn]bool
+	sseClients map[chan string]bool
 	clientsMux sync.RWMutex
 }
 
@@ -39,6 +46,7 @@ func NewClientManager() *ClientManager {
 	return &ClientManager{
 		tcpClients: make(map[net.Conn]bool),
 		wsClients:  make(map[*websocket.Conn]bool),
+		sseClients: make(map[chan string]bool),
 	}
 }
 
@@ -80,11 +88,30 @@ func (cm *ClientManager) RemoveWSClient(conn *websocket.Conn) {
 	}
 }
 
+// AddSSEClient регистрирует клиента /events; строки приходят в канал.
+func (cm *ClientManager) AddSSEClient(remote string) chan string {
+	cm.clientsMux.Lock()
+	defer cm.clientsMux.Unlock()
+	ch := make(chan string, 100)
+	cm.sseClients[ch] = true
+	log.Printf("SSE клиент подключен: %s (активных TCP: %d, WS: %d, SSE: %d)",
+		remote, len(cm.tcpClients), len(cm.wsClients), len(cm.sseClients))
+	return ch
+}
+
+func (cm *ClientManager) RemoveSSEClient(ch chan string, remote string) {
+	cm.clientsMux.Lock()
+	defer cm.clientsMux.Unlock()
+	delete(cm.sseClients, ch)
+	log.Printf("SSE клиент отключен: %s (активных TCP: %d, WS: %d, SSE: %d)",
+		remote, len(cm.tcpClients), len(cm.wsClients), len(cm.sseClients))
+}
+
 func (cm *ClientManager) BroadcastData(data string) {
 	cm.clientsMux.RLock()
 	defer cm.clientsMux.RUnlock()
 
-	totalClients := len(cm.tcpClients) + len(cm.wsClients)
+	totalClients := len(cm.tcpClients) + len(cm.wsClients) + len(cm.sseClients)
 	if totalClients == 0 {
 		return
 	}
@@ -110,22 +137,51 @@ func (cm *ClientManager) BroadcastData(data string) {
 			}
 		}(conn, data)
 	}
+
+	// Отправка SSE клиентам; медленный клиент теряет строки, а не
+	// задерживает остальных
+	for ch := range cm.sseClients {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
 }
 
 func (cm *ClientManager) GetClientCount() int {
 	cm.clientsMux.RLock()
 	defer cm.clientsMux.RUnlock()
-	return len(cm.tcpClients) + len(cm.wsClients)
+	return len(cm.tcpClients) + len(cm.wsClients) + len(cm.sseClients)
 }
 
 func main() {
 	flag.Parse()
 
+	// Правила проверки качества
+	var checker *qc.Checker
+	if *qcFlag != "" {
+		rules, err := qc.Load(*qcFlag)
+		if err != nil {
+			log.Fatalf("Правила проверки качества: %v", err)
+		}
+		checker = qc.NewChecker(rules)
+	}
+
 	// Инициализация менеджера клиентов
 	clientManager := NewClientManager()
 
+	// Тревоги
+	if *alarmsFlag != "" {
+		a, err := newAlarms(*alarmsFlag, clientManager)
+		if err != nil {
+			log.Fatalf("Тревоги: %v", err)
+		}
+		alarms = a
+		go alarms.watch()
+	}
+
 	// Запуск горутины для чтения COM-порта
-	go readCOMPort(clientManager)
+	go readCOMPort(clientManager, checker)
 
 	// Запуск TCP-сервера
 	go startTCPServer(clientManager)
@@ -135,8 +191,14 @@ func main() {
 
 	log.Printf("Сервер запущен:")
 	log.Printf("  TCP сервер слушает на %s", *listenAddr)
-	log.Printf("  WebSocket сервер слушает на %s", *wsAddr)
+	log.Printf("  WebSocket сервер слушает на %s (/ws, события SSE — /events)", *wsAddr)
 	log.Printf("  COM-порт: %s, скорость: %d", *comPort, *baudRate)
+	if checker != nil {
+		log.Printf("  Проверка качества: %s", *qcFlag)
+	}
+	if alarms != nil {
+		log.Printf("  Тревоги: %s", *alarmsFlag)
+	}
 
 	// Бесконечный цикл для поддержания работы main
 	select {}
@@ -176,6 +238,10 @@ func startWebSocketServer(clientManager *ClientManager) {
 		handleWebSocketClient(w, r, clientManager)
 	})
 
+	http.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		handleSSEClient(w, r, clientManager)
+	})
+
 	http.HandleFunc("/", serveHTML)
 
 	log.Printf("WebSocket сервер запущен на %s", *wsAddr)
@@ -200,6 +266,9 @@ func handleTCPClient(conn net.Conn, clientManager *ClientManager, semaphore chan
 	// Отправляем приветственное сообщение
 	welcomeMsg := fmt.Sprintf("Подключение к COM-порту %s установлено. Ожидание данных...\n", *comPort)
 	conn.Write([]byte(welcomeMsg))
+	for _, status := range activeAlarms() {
+		conn.Write([]byte(status + "\n"))
+	}
 
 	// Читаем данные от клиента (для поддержания соединения)
 	buf := make([]byte, 1024)
@@ -232,6 +301,9 @@ func handleWebSocketClient(w http.ResponseWriter, r *http.Request, clientManager
 	// Отправляем приветственное сообщение
 	welcomeMsg := fmt.Sprintf("Подключение к COM-порту %s установлено. Ожидание данных...", *comPort)
 	conn.WriteMessage(websocket.TextMessage, []byte(welcomeMsg))
+	for _, status := range activeAlarms() {
+		conn.WriteMessage(websocket.TextMessage, []byte(status))
+	}
 
 	// Обрабатываем сообщения от клиента
 	for {
@@ -249,14 +321,50 @@ func handleWebSocketClient(w http.ResponseWriter, r *http.Request, clientManager
 	}
 }
 
-func readCOMPort(clientManager *ClientManager) {
-	// Настройка COM-порта
-	mode := &serial.Mode{
-		BaudRate: *baudRate,
+// handleSSEClient отдаёт строки порта как Server-Sent Events: по событию
+// на строку. Нужен там, где доступен только HTTP.
+func handleSSEClient(w http.ResponseWriter, r *http.Request, clientManager *ClientManager) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "потоковая передача не поддерживается", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := clientManager.AddSSEClient(r.RemoteAddr)
+	defer clientManager.RemoveSSEClient(ch, r.RemoteAddr)
+
+	fmt.Fprintf(w, ": Подключение к COM-порту %s установлено. Ожидание данных...\n\n", *comPort)
+	for _, status := range activeAlarms() {
+		fmt.Fprintf(w, "data: %s\n\n", status)
+	}
+	flusher.Flush()
+
+	// Комментарий раз в 15 с не даёт прокси закрыть простаивающее
+	// соединение
+	ping := time.NewTicker(15 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data := <-ch:
+			fmt.Fprintf(w, "data: %s\n\n", strings.TrimRight(data, "\r\n"))
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		flusher.Flush()
 	}
+}
 
+// readCOMPort читает строки прошивки и рассылает их клиентам с меткой
+// времени. Если checker не nil, к строкам данных добавляются флаги
+// качества (см. qc.Prefix).
+func readCOMPort(clientManager *ClientManager, checker *qc.Checker) {
 	for {
-		port, err := serial.Open(*comPort, mode)
+		port, err := serialport.Open(*comPort, *baudRate)
 		if err != nil {
 			log.Printf("Не удалось открыть COM-порт %s: %v. Повторная попытка через 5 секунд...", *comPort, err)
 			time.Sleep(5 * time.Second)
@@ -264,7 +372,6 @@ func readCOMPort(clientManager *ClientManager) {
 		}
 
 		log.Printf("COM-порт %s открыт успешно", *comPort)
-		defer port.Close()
 
 		// Создаем буферизированный читатель для COM-порта
 		reader := bufio.NewReader(port)
@@ -282,15 +389,34 @@ func readCOMPort(clientManager *ClientManager) {
 			}
 
 			// Добавляем время к строке
-			timestamp := time.Now().Format("20060102150405")
+			now := time.Now()
+			timestamp := now.Format(sensor.BridgeTimeLayout)
 			dataWithTime := fmt.Sprintf("%s\t%s", timestamp, line)
 
+			// Флаги качества и тревоги — только для строк данных
+			var events []alarm.Event
+			if checker != nil || alarms != nil {
+				if sample, err := sensor.ParseLine(line); err == nil {
+					if checker != nil {
+						flags := checker.Check(now, sample.Values)
+						dataWithTime = fmt.Sprintf("%s\t%s\t%s%s\n", timestamp, strings.TrimRight(line, "\r\n"), qc.Prefix, qc.Format(flags))
+					}
+					if alarms != nil {
+						events = alarms.monitor.Observe(now, sample.Values)
+					}
+				}
+			}
+
 			// Отправляем данные всем подключенным клиентам
 			if clientManager.GetClientCount() > 0 {
 				clientManager.BroadcastData(dataWithTime)
 				log.Printf("Данные отправлены %d клиентам: %s", clientManager.GetClientCount(), line)
 			}
+			if alarms != nil {
+				alarms.handle(events)
+			}
 		}
+		port.Close()
 
 		// Если произошла ошибка, ждем перед повторным подключением
 		log.Printf("Переподключение к COM-порту через 2 секунды...")
@@ -298,6 +424,65 @@ func readCOMPort(clientManager *ClientManager) {
 	}
 }
 
+// alarms — тревоги моста; nil, если правила не заданы.
+var alarms *bridgeAlarms
+
+// bridgeAlarms — состояние тревог и их обработчики.
+type bridgeAlarms struct {
+	monitor  *alarm.Monitor
+	notifier *alarm.Notifier
+	clients  *ClientManager
+}
+
+func newAlarms(path string, clientManager *ClientManager) (*bridgeAlarms, error) {
+	cfg, err := alarm.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := alarm.NewNotifier(cfg.Hooks)
+	if err != nil {
+		return nil, err
+	}
+	return &bridgeAlarms{
+		monitor:  alarm.NewMonitor(cfg.Rules, time.Now()),
+		notifier: notifier,
+		clients:  clientManager,
+	}, nil
+}
+
+// watch раз в секунду проверяет правила отсутствия данных: строки в
+// это время не приходят.
+func (a *bridgeAlarms) watch() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		a.handle(a.monitor.Tick(now))
+	}
+}
+
+// handle рассылает смены состояния тревог клиентам и обработчикам.
+func (a *bridgeAlarms) handle(events []alarm.Event) {
+	for _, e := range events {
+		status := e.Status()
+		log.Printf("Тревога %s", status)
+		a.clients.BroadcastData(status + "\n")
+		a.notifier.Notify(e)
+	}
+}
+
+// activeAlarms возвращает строки состояния действующих тревог для
+// только что подключившегося клиента.
+func activeAlarms() []string {
+	if alarms == nil {
+		return nil
+	}
+	var lines []string
+	for _, e := range alarms.monitor.Active() {
+		lines = append(lines, e.Status())
+	}
+	return lines
+}
+
 func serveHTML(w http.ResponseWriter, r *http.Request) {
 	html := `<!DOCTYPE html>
 <html>
@@ -451,6 +636,16 @@ func serveHTML(w http.ResponseWriter, r *http.Request) {
             lines.forEach(line => {
                 if (line.trim() === '') return;
 
+                // Состояние тревог
+                if (line.startsWith('alarm:')) {
+                    if (/^alarm:[^=]*=active/.test(line)) {
+                        logError('⚠ ' + line);
+                    } else {
+                        logInfo(line);
+                    }
+                    return;
+                }
+
                 // Информационные строки
                 if (!/^\d/.test(line)) {
                     logInfo(line);
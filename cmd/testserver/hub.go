@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// hub раздаёт один общий поток всем подключенным клиентам. Каждому
+// клиенту отводится буферизованный канал: медленный клиент теряет
+// строки, но не тормозит остальных.
+type hub struct {
+	mu      sync.Mutex
+	clients map[net.Conn]chan []byte
+}
+
+func newHub() *hub {
+	return &hub{clients: make(map[net.Conn]chan []byte)}
+}
+
+func (h *hub) Write(p []byte) (int, error) {
+	b := append([]byte(nil), p...)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, ch := range h.clients {
+		select {
+		case ch <- b:
+		default:
+			stats.dropped.Add(1)
+		}
+	}
+	return len(p), nil
+}
+
+// serve передаёт клиенту общий поток, пока он не отключится.
+func (h *hub) serve(c net.Conn) {
+	ch := make(chan []byte, 1024)
+	h.mu.Lock()
+	h.clients[c] = ch
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		if _, ok := h.clients[c]; ok {
+			delete(h.clients, c)
+			close(ch)
+		}
+		h.mu.Unlock()
+	}()
+
+	for b := range ch {
+		if _, err := c.Write(b); err != nil {
+			log.Printf("Клиент %s отключен: %v", c.RemoteAddr(), err)
+			return
+		}
+		stats.sent.Add(int64(len(b)))
+	}
+}
+
+// disconnectAll обрывает всех клиентов (неисправность disconnect).
+func (h *hub) disconnectAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c, ch := range h.clients {
+		delete(h.clients, c)
+		close(ch)
+		c.Close()
+	}
+}
+
+// stats — счётчики для периодического отчёта при нагрузочных тестах.
+var stats struct {
+	clients atomic.Int64
+	sent    atomic.Int64
+	dropped atomic.Int64
+}
+
+func reportStats(every time.Duration) {
+	var lastSent int64
+	for range time.Tick(every) {
+		sent := stats.sent.Load()
+		log.Printf("Клиентов: %d, отправлено: %.1f КБ/с, отброшено записей: %d",
+			stats.clients.Load(), float64(sent-lastSent)/1024/every.Seconds(), stats.dropped.Load())
+		lastSent = sent
+	}
+}
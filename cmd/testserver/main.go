@@ -1,19 +1,197 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
+	"log"
+	"math/rand"
 	"net"
+	"strings"
 	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/emulator"
+	"github.com/physicist2018/goserialcomm/pkg/serialport"
+)
+
+var (
+	listenAddr = flag.String("listen", ":8080", "Адрес прослушивания TCP-сервера")
+	mode       = flag.String("mode", "csv", "Режим: csv (синтетические каналы), firmware (эмуляция прошивки) или replay (повтор записи)")
+	usePTY     = flag.Bool("pty", false, "Писать вывод эмулятора прошивки или повтора в псевдотерминал вместо TCP")
+	stamp      = flag.Bool("stamp", true, "Добавлять метку времени моста к строкам firmware и replay в TCP")
+	shared     = flag.Bool("shared", false, "Один общий поток для всех клиентов вместо отдельного на каждое соединение")
+	quiet      = flag.Bool("quiet", false, "Не печатать каждую отправленную строку")
+	statsEvery = flag.Duration("stats", 10*time.Second, "Интервал вывода статистики (0 — не выводить)")
+	seed       = flag.Int64("seed", 1, "Начальное значение генератора случайных чисел")
+	scenario   = flag.String("scenario", "", "YAML-файл сценария с фазами и неисправностями (режим firmware)")
+	replayFile = flag.String("replay", "", "Файл эксперимента cmd/operator для повтора (режим replay)")
+	loop       = flag.Bool("loop", false, "Повторять запись по кругу (режим replay)")
+
+	rate     = flag.Float64("rate", 0.5, "Частота строк, Гц (режим csv)")
+	channels = flag.Int("channels", 1, "Число каналов (режим csv)")
+	unit     = flag.String("unit", "C", "Единица измерения в строках (режим csv)")
+	precise  = flag.Bool("precise", false, "Метки времени с миллисекундами и три знака после точки без столбца единиц (режим csv)")
+	gens     = genFlag{"temperature=saw:20,25,20s"}
+
+	tankDepth      = flag.Float64("depth", 1.0, "Глубина датчика в бассейне, м")
+	tankTemp       = flag.Float64("temp", 20.0, "Температура воды, °C")
+	noise          = flag.Float64("noise", 0.05, "СКО шума показаний")
+	ms5837Failures = flag.Int("ms5837-failures", 0, "Число неудачных init() MS5837 при загрузке")
+	tsys01Failures = flag.Int("tsys01-failures", 0, "Число неудачных init() TSYS01 при загрузке")
+	hangEvery      = flag.Duration("hang-every", 0, "Зависание и сброс по сторожевому таймеру через заданное время (0 — никогда)")
+	speed          = flag.Float64("speed", 1, "Ускорение времени эмулятора (для replay 0 — без пауз)")
+)
+
+func init() {
+	flag.Var(&gens, "gen", "Генератор канала [имя=]вид:параметры — const:20, sine:20,2.5,60s, walk:20,0.1, step:20,25,30s, saw:20,25,20s (можно повторять)")
+}
+
+// genFlag — повторяемый флаг -gen. Первое явное значение заменяет
+// значение по умолчанию.
+type genFlag []string
+
+var gensSet bool
+
+func (g *genFlag) String() string { return strings.Join(*g, " ") }
+
+func (g *genFlag) Set(v string) error {
+	if !gensSet {
+		*g, gensSet = nil, true
+	}
+	*g = append(*g, v)
+	return nil
+}
+
+// Сценарий и общее начало отсчёта для всех клиентов.
+var (
+	activeScenario *emulator.Scenario
+	scenarioStart  time.Time
+	replay         *emulator.Replay
 )
 
+// source — то, что печатает строки в порт: синтетический поток, эмулятор
+// прошивки или повтор.
+type source interface {
+	Run(ctx context.Context, w io.Writer) error
+}
+
 func main() {
-	ln, err := net.Listen("tcp", ":8080")
+	flag.Parse()
+
+	if *scenario != "" {
+		s, err := emulator.LoadScenario(*scenario)
+		if err != nil {
+			log.Fatalf("Ошибка загрузки сценария: %v", err)
+		}
+		if s.Seed != nil && !flagSet("seed") {
+			*seed = *s.Seed
+		}
+		activeScenario = s
+		scenarioStart = time.Now()
+		log.Printf("Сценарий %s: %d фаз, %v, seed %d", *scenario, len(s.Phases), s.Duration(), *seed)
+	}
+
+	switch *mode {
+	case "csv":
+		if _, err := newCSVStream(); err != nil {
+			log.Fatalf("Ошибка настройки каналов: %v", err)
+		}
+		fmt.Printf("Генерация CSV данных: %d каналов, %.4g Гц\n", *channels, *rate)
+	case "firmware":
+	case "replay":
+		rec, err := emulator.LoadRecording(*replayFile)
+		if err != nil {
+			log.Fatalf("Ошибка чтения записи: %v", err)
+		}
+		log.Printf("Запись %q: %d строк, %v", rec.Name, len(rec.Records), rec.Duration())
+		replay = &emulator.Replay{Recording: rec, Speed: *speed, Loop: *loop}
+	default:
+		log.Fatalf("Неизвестный режим: %s", *mode)
+	}
+
+	if *usePTY {
+		runPTY()
+		return
+	}
+	if *statsEvery > 0 {
+		go reportStats(*statsEvery)
+	}
+	if *shared {
+		h := newHub()
+		go runShared(h)
+		serveTCP(h.serve)
+		return
+	}
+	serveTCP(serveSource)
+}
+
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// newSource создаёт источник строк для текущего режима.
+func newSource() source {
+	switch *mode {
+	case "csv":
+		s, _ := newCSVStream()
+		return s
+	case "firmware":
+		return newFirmware()
+	default:
+		return replay
+	}
+}
+
+func newCSVStream() (*emulator.CSVStream, error) {
+	r := rand.New(rand.NewSource(*seed))
+	s := &emulator.CSVStream{Rate: *rate, Precise: *precise}
+	for i := 0; i < *channels; i++ {
+		spec := gens[i%len(gens)]
+		name, genSpec, named := strings.Cut(spec, "=")
+		if !named {
+			genSpec = spec
+		}
+		if !named || i >= len(gens) {
+			name = fmt.Sprintf("ch%d", i+1)
+		}
+		g, err := emulator.ParseGenerator(genSpec, r)
+		if err != nil {
+			return nil, err
+		}
+		s.Channels = append(s.Channels, emulator.Channel{Name: name, Unit: *unit, Generator: g})
+	}
+	return s, nil
+}
+
+func newFirmware() *emulator.Firmware {
+	fw := emulator.NewFirmware(emulator.Tank{Depth: *tankDepth, Temperature: *tankTemp}, *seed)
+	fw.Noise = *noise
+	fw.MS5837Failures = *ms5837Failures
+	fw.TSYS01Failures = *tsys01Failures
+	fw.HangEvery = *hangEvery
+	fw.Speed = *speed
+	if activeScenario != nil {
+		fw.Env = activeScenario
+		fw.Faults = activeScenario
+		fw.Start = scenarioStart
+	}
+	return fw
+}
+
+func serveTCP(handle func(c net.Conn)) {
+	ln, err := net.Listen("tcp", *listenAddr)
 	if err != nil {
-		panic(err)
+		log.Fatalf("Ошибка запуска сервера на %s: %v", *listenAddr, err)
 	}
 
-	fmt.Println("Тестовый сервер запущен на :8080")
-	fmt.Println("Генерация CSV данных...")
+	fmt.Printf("Тестовый сервер запущен на %s (режим %s)\n", *listenAddr, *mode)
 
 	for {
 		conn, err := ln.Accept()
@@ -23,19 +201,87 @@ func main() {
 
 		go func(c net.Conn) {
 			defer c.Close()
-
-			ticker := time.NewTicker(2 * time.Second)
-			defer ticker.Stop()
-
-			counter := 0
-			for range ticker.C {
-				counter++
-				data := fmt.Sprintf("%s,temperature,%.1f,C\n",
-					time.Now().Format("2006-01-02 15:04:05"),
-					20.0+float64(counter%10)*0.5)
-				c.Write([]byte(data))
-				fmt.Print("Отправлено: ", data)
-			}
+			stats.clients.Add(1)
+			defer stats.clients.Add(-1)
+			handle(c)
 		}(conn)
 	}
 }
+
+// output добавляет к w метку времени моста (для TCP) и печать в консоль.
+// Строки режима csv содержат собственное время и не помечаются.
+func output(w io.Writer, stamped bool) io.Writer {
+	if stamped && *mode != "csv" {
+		w = emulator.NewBridgeWriter(w)
+	}
+	if *quiet {
+		return w
+	}
+	return io.MultiWriter(w, logWriter{})
+}
+
+// serveSource запускает отдельный поток для одного клиента.
+func serveSource(c net.Conn) {
+	log.Printf("Клиент %s подключен", c.RemoteAddr())
+	if err := newSource().Run(context.Background(), output(countingWriter{c}, *stamp)); err != nil {
+		log.Printf("Клиент %s отключен: %v", c.RemoteAddr(), err)
+	}
+}
+
+// runShared крутит один источник для всех клиентов хаба. Когда источник
+// завершается (конец записи, обрыв по сценарию), клиенты отключаются, а
+// источник перезапускается.
+func runShared(h *hub) {
+	for {
+		err := newSource().Run(context.Background(), output(h, *stamp))
+		h.disconnectAll()
+		if err != nil && err != emulator.ErrDisconnect {
+			log.Printf("Ошибка источника: %v", err)
+		}
+		log.Printf("Общий поток завершён, перезапуск через 1 секунду...")
+		time.Sleep(time.Second)
+	}
+}
+
+func runPTY() {
+	pty, err := serialport.OpenPTY()
+	if err != nil {
+		log.Fatalf("Не удалось создать псевдотерминал: %v", err)
+	}
+	defer pty.Close()
+
+	log.Printf("Эмулятор пишет в %s", pty.SlaveName)
+	log.Printf("Запуск моста: serialtcpws-bridge -com %s", pty.SlaveName)
+
+	for {
+		err := newSource().Run(context.Background(), output(pty, false))
+		if err == nil {
+			log.Printf("Запись воспроизведена полностью")
+			return
+		}
+		if err != emulator.ErrDisconnect {
+			log.Fatalf("Ошибка записи в псевдотерминал: %v", err)
+		}
+		// Псевдотерминал нельзя «выдернуть», поэтому обрыв по сценарию
+		// выглядит как молчание, после которого прошивка перезагружается.
+		log.Printf("Обрыв по сценарию, перезапуск через 1 секунду...")
+		time.Sleep(time.Second)
+	}
+}
+
+// countingWriter учитывает отправленные байты в статистике.
+type countingWriter struct{ w io.Writer }
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	stats.sent.Add(int64(n))
+	return n, err
+}
+
+// logWriter печатает отправленные строки в консоль.
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	fmt.Printf("Отправлено: %q\n", p)
+	return len(p), nil
+}
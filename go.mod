@@ -3,8 +3,11 @@ module github.com/physicist2018/goserialcomm
 go 1.20
 
 require (
+	github.com/creack/pty v1.1.21
 	github.com/gorilla/websocket v1.5.0
 	go.bug.st/serial v1.6.3
+	golang.org/x/term v0.19.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,87 @@
+// Пакет alarm следит за значениями полей и поднимает тревоги по
+// правилам (см. Rule): выход за порог, слишком быстрый рост или
+// падение и отсутствие данных. Тревога поднимается, когда условие
+// держится Debounce, и снимается с гистерезисом: значение должно
+// вернуться за порог на Hysteresis и продержаться там Debounce.
+//
+// Мост рассылает смену состояния тревог клиентам строками состояния
+// (см. Event.Status) и передаёт её обработчикам (см. Notifier):
+// команде, HTTP-вебхуку и файлу журнала.
+package alarm
+
+import (
+	"strings"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// State — состояние тревоги.
+type State string
+
+const (
+	Active  State = "active"
+	Cleared State = "clear"
+)
+
+// Event — смена состояния тревоги.
+type Event struct {
+	Rule  string    `json:"rule"`
+	State State     `json:"state"`
+	Time  time.Time `json:"time"`
+	Field string    `json:"field,omitempty"`
+	// Value — значение, на котором сработало правило: поле или его
+	// скорость изменения в минуту; нет у правил no_data.
+	Value *float64 `json:"value,omitempty"`
+	// Text описывает условие, Message — текст из правила.
+	Text    string `json:"text"`
+	Message string `json:"message,omitempty"`
+}
+
+// Prefix начинает строку состояния тревоги в потоке моста:
+//
+//	alarm:depth=active\t20250829030648\tDepth = 25.3 > 25: превышена рабочая глубина
+const Prefix = "alarm:"
+
+// Status возвращает строку состояния для клиентов моста (без перевода
+// строки). Метка времени — в формате метки моста.
+func (e Event) Status() string {
+	var b strings.Builder
+	b.WriteString(Prefix)
+	b.WriteString(e.Rule)
+	b.WriteByte('=')
+	b.WriteString(string(e.State))
+	b.WriteByte('\t')
+	b.WriteString(e.Time.Format(sensor.BridgeTimeLayout))
+	b.WriteByte('\t')
+	b.WriteString(e.Description())
+	return b.String()
+}
+
+// ParseStatus разбирает строку состояния, записанную Status. Условие и
+// текст правила возвращаются вместе в Text.
+func ParseStatus(line string) (Event, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), Prefix)
+	if !ok {
+		return Event{}, false
+	}
+	head, text, _ := strings.Cut(rest, "\t")
+	stamp, text, _ := strings.Cut(text, "\t")
+	rule, state, ok := strings.Cut(head, "=")
+	if !ok || rule == "" || State(state) != Active && State(state) != Cleared {
+		return Event{}, false
+	}
+	t, err := time.ParseInLocation(sensor.BridgeTimeLayout, stamp, time.Local)
+	if err != nil {
+		return Event{}, false
+	}
+	return Event{Rule: rule, State: State(state), Time: t, Text: text}, true
+}
+
+// Description — условие и текст правила одной строкой.
+func (e Event) Description() string {
+	if e.Message == "" {
+		return e.Text
+	}
+	return e.Text + ": " + e.Message
+}
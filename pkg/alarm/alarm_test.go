@@ -0,0 +1,31 @@
+package alarm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	v := 25.3
+	e := Event{Rule: "depth", State: Active, Time: time.Date(2025, 8, 29, 3, 6, 48, 0, time.Local),
+		Field: "Depth", Value: &v, Text: "Depth = 25.3 > 25", Message: "превышена рабочая глубина"}
+	line := e.Status()
+	if want := "alarm:depth=active\t20250829030648\tDepth = 25.3 > 25: превышена рабочая глубина"; line != want {
+		t.Errorf("Status = %q, ожидается %q", line, want)
+	}
+	got, ok := ParseStatus(line + "\n")
+	if !ok || got.Rule != e.Rule || got.State != e.State || !got.Time.Equal(e.Time) || got.Text != e.Description() {
+		t.Errorf("ParseStatus = %+v, %v", got, ok)
+	}
+	for _, bad := range []string{
+		"20250829030648\tP:1013.25",
+		"alarm:depth\t20250829030648\tтекст",
+		"alarm:depth=on\t20250829030648\tтекст",
+		"alarm:=clear\t20250829030648\tтекст",
+		"alarm:depth=clear\tвчера\tтекст",
+	} {
+		if _, ok := ParseStatus(bad); ok {
+			t.Errorf("ParseStatus(%q): ожидается отказ", bad)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package alarm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// hookTimeout ограничивает время работы команды и запроса вебхука.
+const hookTimeout = 30 * time.Second
+
+// Notifier передаёт события обработчикам Hooks по порядку в отдельной
+// горутине, чтобы медленная команда или вебхук не задерживали приём
+// строк. Ошибки обработчиков пишутся в журнал программы.
+type Notifier struct {
+	hooks  Hooks
+	client *http.Client
+	file   *os.File
+	events chan Event
+}
+
+// NewNotifier открывает файл журнала тревог и запускает обработку.
+func NewNotifier(h Hooks) (*Notifier, error) {
+	n := &Notifier{
+		hooks:  h,
+		client: &http.Client{Timeout: hookTimeout},
+		events: make(chan Event, 100),
+	}
+	if h.Log != "" {
+		f, err := os.OpenFile(h.Log, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		n.file = f
+	}
+	go n.run()
+	return n, nil
+}
+
+// Notify ставит событие в очередь. Если очередь переполнена, событие
+// отбрасывается.
+func (n *Notifier) Notify(e Event) {
+	select {
+	case n.events <- e:
+	default:
+		log.Printf("Очередь обработчиков тревог переполнена, событие %s=%s пропущено", e.Rule, e.State)
+	}
+}
+
+func (n *Notifier) run() {
+	for e := range n.events {
+		if n.file != nil {
+			if err := n.writeLog(e); err != nil {
+				log.Printf("Журнал тревог %s: %v", n.hooks.Log, err)
+			}
+		}
+		if len(n.hooks.Exec) > 0 {
+			if err := n.exec(e); err != nil {
+				log.Printf("Команда тревоги %s: %v", n.hooks.Exec[0], err)
+			}
+		}
+		if n.hooks.Webhook != "" {
+			if err := n.webhook(e); err != nil {
+				log.Printf("Вебхук тревоги %s: %v", n.hooks.Webhook, err)
+			}
+		}
+	}
+}
+
+func (n *Notifier) writeLog(e Event) error {
+	_, err := fmt.Fprintf(n.file, "%s\t%s\t%s\t%s\n",
+		e.Time.Local().Format("2006-01-02 15:04:05"), e.Rule, e.State, e.Description())
+	return err
+}
+
+func (n *Notifier) exec(e Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	args := append(append([]string(nil), n.hooks.Exec[1:]...), e.Status())
+	cmd := exec.CommandContext(ctx, n.hooks.Exec[0], args...)
+	value := ""
+	if e.Value != nil {
+		value = strconv.FormatFloat(*e.Value, 'g', -1, 64)
+	}
+	cmd.Env = append(os.Environ(),
+		"ALARM_RULE="+e.Rule,
+		"ALARM_STATE="+string(e.State),
+		"ALARM_TIME="+e.Time.Format(time.RFC3339),
+		"ALARM_FIELD="+e.Field,
+		"ALARM_VALUE="+value,
+		"ALARM_TEXT="+e.Description(),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (n *Notifier) webhook(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.hooks.Webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("ответ %s", resp.Status)
+	}
+	return nil
+}
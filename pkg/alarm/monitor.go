@@ -0,0 +1,191 @@
+package alarm
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Monitor хранит состояние тревог. Строки передаются в Observe, а для
+// правил no_data нужно регулярно вызывать Tick. Безопасен для
+// использования из нескольких горутин.
+type Monitor struct {
+	mu      sync.Mutex
+	watches []*watch
+}
+
+// watch — правило и его состояние.
+type watch struct {
+	Rule
+	active  bool
+	since   time.Time // с какого момента выполняется условие смены состояния
+	raised  Event     // событие, поднявшее тревогу
+	history []point   // значения за окно скорости
+	last    time.Time // последняя строка данных (no_data)
+}
+
+type point struct {
+	t time.Time
+	v float64
+}
+
+// NewMonitor создаёт монитор; отсутствие данных отсчитывается от start.
+func NewMonitor(rules []Rule, start time.Time) *Monitor {
+	m := &Monitor{}
+	for _, r := range rules {
+		m.watches = append(m.watches, &watch{Rule: r, last: start})
+	}
+	return m
+}
+
+// Observe проверяет значения строки данных, принятой в момент t, и
+// возвращает смены состояния тревог.
+func (m *Monitor) Observe(t time.Time, values map[string]float64) []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var events []Event
+	for _, w := range m.watches {
+		v, ok := values[w.Field]
+		present := ok && !math.IsNaN(v)
+		if w.NoData != 0 {
+			if w.Field == "" || present {
+				w.last = t
+				if e, ok := w.step(t, false, true, nil); ok {
+					events = append(events, e)
+				}
+			}
+			continue
+		}
+		if !present {
+			continue
+		}
+		if e, ok := w.observe(t, v); ok {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Tick проверяет правила no_data в момент t.
+func (m *Monitor) Tick(t time.Time) []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var events []Event
+	for _, w := range m.watches {
+		if w.NoData == 0 {
+			continue
+		}
+		silent := t.Sub(w.last) > w.NoData
+		if e, ok := w.step(t, silent, !silent, nil); ok {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Active возвращает события, поднявшие действующие тревоги.
+func (m *Monitor) Active() []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var events []Event
+	for _, w := range m.watches {
+		if w.active {
+			events = append(events, w.raised)
+		}
+	}
+	return events
+}
+
+// observe проверяет правило с порогом или скоростью.
+func (w *watch) observe(t time.Time, v float64) (Event, bool) {
+	switch {
+	case w.Above != nil:
+		return w.step(t, v > *w.Above, v <= *w.Above-w.Hysteresis, &v)
+	case w.Below != nil:
+		return w.step(t, v < *w.Below, v >= *w.Below+w.Hysteresis, &v)
+	}
+
+	rate, ok := w.rate(t, v)
+	if !ok {
+		return Event{}, false
+	}
+	if w.Rise != 0 {
+		return w.step(t, rate > w.Rise, rate <= w.Rise-w.Hysteresis, &rate)
+	}
+	return w.step(t, -rate > w.Fall, -rate <= w.Fall-w.Hysteresis, &rate)
+}
+
+// rate оценивает скорость изменения в минуту по самой старой строке в
+// окне; пока строки не охватывают всё окно, оценки нет.
+func (w *watch) rate(t time.Time, v float64) (float64, bool) {
+	window := w.window()
+	w.history = append(w.history, point{t, v})
+	for len(w.history) > 1 && !w.history[1].t.After(t.Add(-window)) {
+		w.history = w.history[1:]
+	}
+	first := w.history[0]
+	if t.Sub(first.t) < window {
+		return 0, false
+	}
+	return (v - first.v) / t.Sub(first.t).Minutes(), true
+}
+
+// step меняет состояние, если условие смены (raise для спокойного
+// правила, clear для сработавшего) держится Debounce.
+func (w *watch) step(t time.Time, raise, clear bool, value *float64) (Event, bool) {
+	cond := raise
+	if w.active {
+		cond = clear
+	}
+	if !cond {
+		w.since = time.Time{}
+		return Event{}, false
+	}
+	if w.since.IsZero() {
+		w.since = t
+	}
+	if t.Sub(w.since) < w.Debounce {
+		return Event{}, false
+	}
+	w.active = !w.active
+	w.since = time.Time{}
+	e := w.event(t, value)
+	if w.active {
+		w.raised = e
+	}
+	return e, true
+}
+
+func (w *watch) event(t time.Time, value *float64) Event {
+	e := Event{Rule: w.Name, State: Cleared, Time: t, Field: w.Field, Value: value, Message: w.Message}
+	if w.active {
+		e.State = Active
+	}
+	var v float64
+	if value != nil {
+		v = *value
+	}
+	switch {
+	case w.NoData != 0 && w.active:
+		e.Text = fmt.Sprintf("нет данных дольше %v", w.NoData)
+		if w.Field != "" {
+			e.Text = fmt.Sprintf("нет значений %s дольше %v", w.Field, w.NoData)
+		}
+	case w.NoData != 0:
+		e.Text = "данные поступают"
+	case w.Above != nil && w.active:
+		e.Text = fmt.Sprintf("%s = %g > %g", w.Field, v, *w.Above)
+	case w.Below != nil && w.active:
+		e.Text = fmt.Sprintf("%s = %g < %g", w.Field, v, *w.Below)
+	case w.Above != nil || w.Below != nil:
+		e.Text = fmt.Sprintf("%s = %g, норма", w.Field, v)
+	case w.Rise != 0 && w.active:
+		e.Text = fmt.Sprintf("%s растёт на %.3g в минуту > %g", w.Field, v, w.Rise)
+	case w.Fall != 0 && w.active:
+		e.Text = fmt.Sprintf("%s падает на %.3g в минуту > %g", w.Field, -v, w.Fall)
+	default:
+		e.Text = fmt.Sprintf("%s меняется на %.3g в минуту, норма", w.Field, v)
+	}
+	return e
+}
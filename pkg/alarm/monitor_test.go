@@ -0,0 +1,111 @@
+package alarm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	t0 := time.Date(2025, 8, 29, 3, 6, 48, 0, time.UTC)
+	limit := func(v float64) *float64 { return &v }
+	type step struct {
+		at    time.Duration
+		value float64
+		tick  bool   // Tick вместо Observe
+		want  string // смена состояния: "active", "clear" или пусто
+	}
+	tests := []struct {
+		name  string
+		rule  Rule
+		steps []step
+	}{
+		{"порог с гистерезисом", Rule{Field: "Depth", Above: limit(25), Hysteresis: 0.5}, []step{
+			{0, 24, false, ""},
+			{time.Second, 25.3, false, "active"},
+			{2 * time.Second, 26, false, ""},
+			{3 * time.Second, 24.8, false, ""}, // ниже порога, но в пределах гистерезиса
+			{4 * time.Second, 24.5, false, "clear"},
+			{5 * time.Second, 25, false, ""},
+		}},
+		{"нижний порог", Rule{Field: "T2", Below: limit(0), Hysteresis: 1}, []step{
+			{0, -0.5, false, "active"},
+			{time.Second, 0.5, false, ""},
+			{2 * time.Second, 1, false, "clear"},
+		}},
+		{"задержка срабатывания", Rule{Field: "Depth", Above: limit(25), Debounce: 3 * time.Second}, []step{
+			{0, 26, false, ""},
+			{2 * time.Second, 26, false, ""},
+			{2500 * time.Millisecond, 24, false, ""}, // дребезг: отсчёт заново
+			{3 * time.Second, 26, false, ""},
+			{5 * time.Second, 26, false, ""},
+			{6 * time.Second, 26, false, "active"},
+			{7 * time.Second, 20, false, ""},
+			{10 * time.Second, 20, false, "clear"},
+		}},
+		{"скорость роста", Rule{Field: "T2", Rise: 1, Window: time.Minute, Hysteresis: 0.5}, []step{
+			{0, 20, false, ""},
+			{30 * time.Second, 21, false, ""}, // окно ещё не заполнено
+			{time.Minute, 21.5, false, "active"},
+			{90 * time.Second, 21.4, false, "clear"}, // 0.4 в минуту от строки 30 с
+		}},
+		{"скорость падения", Rule{Field: "P", Fall: 10}, []step{
+			{0, 1100, false, ""},
+			{time.Minute, 1085, false, "active"},
+			{2 * time.Minute, 1080, false, "clear"},
+		}},
+		{"нет данных", Rule{NoData: 10 * time.Second}, []step{
+			{5 * time.Second, 0, true, ""},
+			{11 * time.Second, 0, true, "active"},
+			{12 * time.Second, 0, true, ""},
+			{13 * time.Second, 1000, false, "clear"},
+			{20 * time.Second, 0, true, ""},
+			{24 * time.Second, 0, true, "active"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Name = "r"
+			m := NewMonitor([]Rule{tt.rule}, t0)
+			for i, s := range tt.steps {
+				var events []Event
+				if s.tick {
+					events = m.Tick(t0.Add(s.at))
+				} else {
+					events = m.Observe(t0.Add(s.at), map[string]float64{tt.rule.Field: s.value})
+				}
+				var got []string
+				for _, e := range events {
+					got = append(got, string(e.State))
+				}
+				if strings.Join(got, " ") != s.want {
+					t.Errorf("шаг %d (%v): события %v, ожидается %q", i+1, s.at, events, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMonitorActive(t *testing.T) {
+	t0 := time.Date(2025, 8, 29, 3, 6, 48, 0, time.UTC)
+	above := 25.0
+	m := NewMonitor([]Rule{
+		{Name: "depth", Field: "Depth", Above: &above, Message: "глубоко"},
+		{Name: "silence", NoData: time.Minute},
+	}, t0)
+	events := m.Observe(t0, map[string]float64{"Depth": 30, "P": 1000})
+	if len(events) != 1 {
+		t.Fatalf("события %v", events)
+	}
+	e := events[0]
+	if e.Rule != "depth" || *e.Value != 30 || e.Description() != "Depth = 30 > 25: глубоко" {
+		t.Errorf("событие %+v", e)
+	}
+	if active := m.Active(); len(active) != 1 || active[0].Rule != "depth" {
+		t.Errorf("Active = %v", active)
+	}
+	// Строка без поля правила не меняет его состояние
+	if events := m.Observe(t0.Add(time.Second), map[string]float64{"P": 1000}); len(events) != 0 {
+		t.Errorf("события без поля %v", events)
+	}
+}
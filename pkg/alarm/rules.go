@@ -0,0 +1,152 @@
+package alarm
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config — правила тревог и обработчики. Пример файла:
+//
+//	rules:
+//	  - name: depth              # выход за порог
+//	    field: Depth
+//	    above: 25
+//	    hysteresis: 0.5          # снимается при Depth <= 24.5
+//	    debounce: 3s
+//	    message: превышена рабочая глубина датчика
+//	  - name: t2-rise            # рост быстрее 1 °C в минуту
+//	    field: T2
+//	    rise: 1
+//	    window: 1m
+//	  - name: silence            # нет строк данных 30 с
+//	    no_data: 30s
+//	hooks:
+//	  exec: [/usr/local/bin/notify, мост]
+//	  webhook: http://localhost:9000/alarm
+//	  log: alarms.log
+type Config struct {
+	Rules []Rule `yaml:"rules"`
+	Hooks Hooks  `yaml:"hooks"`
+}
+
+// Rule — правило тревоги. Задаётся ровно одно условие: Above, Below,
+// Rise, Fall или NoData.
+type Rule struct {
+	Name  string `yaml:"name"`
+	Field string `yaml:"field"`
+
+	Above *float64 `yaml:"above"`
+	Below *float64 `yaml:"below"`
+	// Rise и Fall — скорость роста и падения, единиц поля в минуту,
+	// по изменению за Window (по умолчанию DefaultWindow).
+	Rise   float64       `yaml:"rise"`
+	Fall   float64       `yaml:"fall"`
+	Window time.Duration `yaml:"window"`
+	// NoData — нет строк данных (с полем Field, если оно задано).
+	NoData time.Duration `yaml:"no_data"`
+
+	// Hysteresis — насколько значение должно вернуться за порог, чтобы
+	// тревога снялась; в единицах поля или его скорости.
+	Hysteresis float64 `yaml:"hysteresis"`
+	// Debounce — сколько условие должно держаться, чтобы тревога
+	// поднялась или снялась.
+	Debounce time.Duration `yaml:"debounce"`
+	Message  string        `yaml:"message"`
+}
+
+// DefaultWindow — окно оценки скорости изменения по умолчанию.
+const DefaultWindow = time.Minute
+
+// Hooks — обработчики смены состояния тревог; пустые не вызываются.
+type Hooks struct {
+	// Exec — команда и аргументы. Строка состояния добавляется
+	// последним аргументом, событие передаётся и в переменных окружения
+	// ALARM_RULE, ALARM_STATE, ALARM_TIME, ALARM_FIELD, ALARM_VALUE и
+	// ALARM_TEXT.
+	Exec []string `yaml:"exec"`
+	// Webhook — адрес, на который событие отправляется POST-запросом в
+	// JSON.
+	Webhook string `yaml:"webhook"`
+	// Log — файл журнала тревог; строки дописываются в конец.
+	Log string `yaml:"log"`
+}
+
+// Load читает правила и обработчики из YAML-файла и проверяет их.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Validate проверяет правила и обработчики.
+func (c *Config) Validate() error {
+	if len(c.Rules) == 0 {
+		return fmt.Errorf("нет правил тревог")
+	}
+	names := make(map[string]bool)
+	for i, r := range c.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("правило %d: не задано имя", i+1)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("правило %s задано дважды", r.Name)
+		}
+		names[r.Name] = true
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("правило %s: %w", r.Name, err)
+		}
+	}
+	if c.Hooks.Webhook != "" {
+		u, err := url.Parse(c.Hooks.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook: ожидается адрес http:// или https://, получено %q", c.Hooks.Webhook)
+		}
+	}
+	return nil
+}
+
+func (r Rule) validate() error {
+	n := 0
+	for _, set := range []bool{r.Above != nil, r.Below != nil, r.Rise != 0, r.Fall != 0, r.NoData != 0} {
+		if set {
+			n++
+		}
+	}
+	switch {
+	case n != 1:
+		return fmt.Errorf("ожидается одно условие: above, below, rise, fall или no_data")
+	case r.NoData == 0 && r.Field == "":
+		return fmt.Errorf("не задано поле")
+	case r.Rise < 0 || r.Fall < 0:
+		return fmt.Errorf("скорость должна быть положительной")
+	case r.NoData < 0 || r.Window < 0 || r.Debounce < 0:
+		return fmt.Errorf("длительность не может быть отрицательной")
+	case r.Hysteresis < 0:
+		return fmt.Errorf("гистерезис не может быть отрицательным")
+	}
+	return nil
+}
+
+// window возвращает окно оценки скорости.
+func (r Rule) window() time.Duration {
+	if r.Window == 0 {
+		return DefaultWindow
+	}
+	return r.Window
+}
@@ -0,0 +1,66 @@
+package alarm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alarms.yaml")
+	load := func(text string) (*Config, error) {
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return Load(path)
+	}
+
+	c, err := load(`
+rules:
+  - name: depth
+    field: Depth
+    above: 25
+    hysteresis: 0.5
+    debounce: 3s
+  - name: t2-rise
+    field: T2
+    rise: 1
+  - name: silence
+    no_data: 30s
+hooks:
+  webhook: http://localhost:9000/alarm
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Rules) != 3 || *c.Rules[0].Above != 25 || c.Rules[0].Debounce != 3*time.Second || c.Rules[2].NoData != 30*time.Second {
+		t.Errorf("правила %+v", c.Rules)
+	}
+	if c.Rules[1].window() != DefaultWindow {
+		t.Errorf("окно по умолчанию %v", c.Rules[1].window())
+	}
+
+	tests := []struct {
+		text, err string
+	}{
+		{"rules: []\n", "нет правил"},
+		{"rules:\n  - {field: P, above: 1}\n", "не задано имя"},
+		{"rules:\n  - {name: a, field: P, above: 1}\n  - {name: a, field: P, below: 1}\n", "дважды"},
+		{"rules:\n  - {name: a, field: P, above: 1, below: 0}\n", "одно условие"},
+		{"rules:\n  - {name: a, field: P}\n", "одно условие"},
+		{"rules:\n  - {name: a, above: 1}\n", "не задано поле"},
+		{"rules:\n  - {name: a, field: P, rise: -1}\n", "положительной"},
+		{"rules:\n  - {name: a, field: P, above: 1, debounce: -1s}\n", "отрицательной"},
+		{"rules:\n  - {name: a, field: P, above: 1, hysteresis: -1}\n", "гистерезис"},
+		{"rules:\n  - {name: a, no_data: 1s}\nhooks: {webhook: ftp://host/}\n", "webhook"},
+		{"rules:\n  - {name: a, no_data: 1s, after: 2}\n", "after"},
+	}
+	for _, tt := range tests {
+		_, err := load(tt.text)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: ошибка %v, ожидается %q", tt.text, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Пакет derive рассчитывает производные величины по полям строки:
+// глубину по давлению (прошивка считает её с плотностью 1029 кг/м³,
+// что неверно для пресной воды), скорость звука и практическую
+// солёность, если у источника есть канал электропроводности.
+//
+// Производные поля добавляются к значениям строки рядом с исходными:
+//
+//	d := derive.New(cfg)
+//	fields = append(fields, d.Fields(fields)...)
+//	d.Apply(sample.Values)
+package derive
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+	"gopkg.in/yaml.v3"
+)
+
+// Имена производных полей.
+const (
+	FieldDepth      = "DepthCalc"
+	FieldSoundSpeed = "SoundSpeed"
+	FieldSalinity   = "Salinity"
+)
+
+// Формулы скорости звука (Config.SoundSpeed).
+const (
+	Mackenzie   = "mackenzie"
+	ChenMillero = "chen-millero"
+)
+
+// Config — параметры расчёта. Пример файла:
+//
+//	density: 998.2          # пресная вода; 0 — формула UNESCO для морской
+//	latitude: 55.7
+//	surface_pressure: 1008  # мбар; по умолчанию 1013.25
+//	salinity: 0             # если нет канала электропроводности
+//	conductivity: C         # поле электропроводности, мСм/см
+//	sound_speed: chen-millero
+type Config struct {
+	// Pressure и Temperature — поля давления (мбар) и температуры
+	// воды; по умолчанию P и T2.
+	Pressure    string `yaml:"pressure" json:"pressure,omitempty"`
+	Temperature string `yaml:"temperature" json:"temperature,omitempty"`
+	// Conductivity — поле электропроводности, мСм/см; без него
+	// солёность не рассчитывается, а берётся Salinity.
+	Conductivity string `yaml:"conductivity" json:"conductivity,omitempty"`
+
+	// Density — плотность воды, кг/м³; 0 — глубина по формуле UNESCO
+	// для морской воды.
+	Density  float64 `yaml:"density" json:"density,omitempty"`
+	Latitude float64 `yaml:"latitude" json:"latitude"`
+	// SurfacePressure — давление на поверхности, мбар; по умолчанию
+	// 1013.25.
+	SurfacePressure float64 `yaml:"surface_pressure" json:"surface_pressure,omitempty"`
+	// Salinity — солёность для скорости звука без канала
+	// электропроводности; по умолчанию 35, для пресной воды — 0.
+	Salinity *float64 `yaml:"salinity" json:"salinity,omitempty"`
+	// SoundSpeed — формула скорости звука: mackenzie (по умолчанию)
+	// или chen-millero.
+	SoundSpeed string `yaml:"sound_speed" json:"sound_speed,omitempty"`
+}
+
+// Fresh и Sea — параметры для пресной и морской воды.
+var (
+	Fresh = Config{Density: 998.2, Salinity: new(float64)}
+	Sea   = Config{}
+)
+
+// Load читает параметры из YAML-файла. Имена "fresh" и "sea" означают
+// Fresh и Sea.
+func Load(path string) (*Config, error) {
+	switch path {
+	case "fresh":
+		c := Fresh
+		return &c, nil
+	case "sea":
+		c := Sea
+		return &c, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Validate проверяет параметры.
+func (c *Config) Validate() error {
+	switch {
+	case c.Density < 0:
+		return fmt.Errorf("плотность не может быть отрицательной")
+	case c.Density != 0 && (c.Density < 900 || c.Density > 1100):
+		return fmt.Errorf("плотность %g кг/м³ вне разумных пределов 900–1100", c.Density)
+	case c.Latitude < -90 || c.Latitude > 90:
+		return fmt.Errorf("широта %g вне пределов ±90", c.Latitude)
+	case c.SurfacePressure < 0:
+		return fmt.Errorf("давление на поверхности не может быть отрицательным")
+	case c.Salinity != nil && (*c.Salinity < 0 || *c.Salinity > 42):
+		return fmt.Errorf("солёность %g вне пределов 0–42", *c.Salinity)
+	}
+	switch c.SoundSpeed {
+	case "", Mackenzie, ChenMillero:
+	default:
+		return fmt.Errorf("формула скорости звука %q: ожидается %s или %s", c.SoundSpeed, Mackenzie, ChenMillero)
+	}
+	return nil
+}
+
+// String кратко описывает параметры.
+func (c *Config) String() string {
+	d := New(c)
+	s := d.depthDescription() + "; " + d.soundSpeedDescription()
+	if c.Conductivity != "" {
+		s += "; солёность PSS-78 по " + c.Conductivity
+	}
+	return s
+}
+
+// Deriver рассчитывает производные поля по параметрам Config.
+type Deriver struct {
+	cfg                   Config
+	pressure, temperature string
+	surface, salinity     float64
+}
+
+// New создаёт расчёт с параметрами c, дополненными значениями по
+// умолчанию.
+func New(c *Config) *Deriver {
+	d := &Deriver{cfg: *c, pressure: "P", temperature: "T2", surface: 1013.25, salinity: 35}
+	if c.Pressure != "" {
+		d.pressure = c.Pressure
+	}
+	if c.Temperature != "" {
+		d.temperature = c.Temperature
+	}
+	if c.SurfacePressure != 0 {
+		d.surface = c.SurfacePressure
+	}
+	if c.Salinity != nil {
+		d.salinity = *c.Salinity
+	}
+	return d
+}
+
+// Fields возвращает производные поля, которые можно рассчитать по
+// полям fields.
+func (d *Deriver) Fields(fields []sensor.Field) []sensor.Field {
+	has := make(map[string]bool)
+	for _, f := range fields {
+		has[f.Name] = true
+	}
+	var out []sensor.Field
+	if has[d.pressure] {
+		out = append(out, sensor.Field{
+			Name:         FieldDepth,
+			Unit:         "m",
+			Description:  d.depthDescription(),
+			StandardName: "depth",
+		})
+	}
+	if has[d.pressure] && has[d.temperature] && has[d.cfg.Conductivity] {
+		out = append(out, sensor.Field{
+			Name:         FieldSalinity,
+			Unit:         "1e-3",
+			Description:  fmt.Sprintf("Практическая солёность PSS-78 по %s и %s", d.cfg.Conductivity, d.temperature),
+			StandardName: "sea_water_practical_salinity",
+		})
+	}
+	if has[d.pressure] && has[d.temperature] {
+		out = append(out, sensor.Field{
+			Name:         FieldSoundSpeed,
+			Unit:         "m s-1",
+			Description:  d.soundSpeedDescription(),
+			StandardName: "speed_of_sound_in_sea_water",
+		})
+	}
+	return out
+}
+
+func (d *Deriver) depthDescription() string {
+	if d.cfg.Density != 0 {
+		return fmt.Sprintf("Глубина по %s (плотность %g, широта %g)", d.pressure, d.cfg.Density, d.cfg.Latitude)
+	}
+	return fmt.Sprintf("Глубина по %s (UNESCO 1983, широта %g)", d.pressure, d.cfg.Latitude)
+}
+
+func (d *Deriver) soundSpeedDescription() string {
+	name := "Маккензи"
+	if d.cfg.SoundSpeed == ChenMillero {
+		name = "Чен — Миллеро"
+	}
+	s := fmt.Sprintf("солёность %g", d.salinity)
+	if d.cfg.Conductivity != "" {
+		s = "солёность по " + d.cfg.Conductivity
+	}
+	return fmt.Sprintf("Скорость звука (%s, %s)", name, s)
+}
+
+// Apply дописывает в values производные поля, для которых есть
+// исходные значения.
+func (d *Deriver) Apply(values map[string]float64) {
+	p, ok := present(values, d.pressure)
+	if !ok {
+		return
+	}
+	p = (p - d.surface) / 100 // мбар → дбар избыточного давления
+	depth := DepthHydrostatic(p, d.cfg.Density, d.cfg.Latitude)
+	if d.cfg.Density == 0 {
+		depth = DepthUNESCO(p, d.cfg.Latitude)
+	}
+	values[FieldDepth] = round(depth, 1e3)
+
+	t, ok := present(values, d.temperature)
+	if !ok {
+		return
+	}
+	s := d.salinity
+	if d.cfg.Conductivity != "" {
+		c, ok := present(values, d.cfg.Conductivity)
+		if !ok {
+			return // скорость звука без солёности неверна
+		}
+		s = SalinityPSS78(c, t, math.Max(p, 0))
+		values[FieldSalinity] = round(s, 1e4)
+	}
+	c := SoundSpeedMackenzie(t, s, math.Max(depth, 0))
+	if d.cfg.SoundSpeed == ChenMillero {
+		c = SoundSpeedChenMillero(t, s, math.Max(p, 0))
+	}
+	values[FieldSoundSpeed] = round(c, 1e3)
+}
+
+// round округляет v до 1/scale: точнее исходных данных значения не
+// бывают, а в файле так меньше шума.
+func round(v, scale float64) float64 { return math.Round(v*scale) / scale }
+
+func present(values map[string]float64, name string) (float64, bool) {
+	v, ok := values[name]
+	return v, ok && !math.IsNaN(v)
+}
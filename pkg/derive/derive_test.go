@@ -0,0 +1,97 @@
+package derive
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+func TestFields(t *testing.T) {
+	withC := append(append([]sensor.Field(nil), sensor.Fields...), sensor.Field{Name: "C", Unit: "mS/cm"})
+	tests := []struct {
+		name   string
+		cfg    Config
+		fields []sensor.Field
+		want   []string
+	}{
+		{"прошивка", Sea, sensor.Fields, []string{FieldDepth, FieldSoundSpeed}},
+		{"с электропроводностью", Config{Conductivity: "C"}, withC, []string{FieldDepth, FieldSalinity, FieldSoundSpeed}},
+		{"без температуры", Config{Temperature: "T9"}, sensor.Fields, []string{FieldDepth}},
+		{"без давления", Sea, sensor.Fields[1:], nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, f := range New(&tt.cfg).Fields(tt.fields) {
+			got = append(got, f.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v, ожидается %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestApply проверяет перевод давления, выбор формул и округление;
+// сами формулы — в TestSeawater.
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		values map[string]float64
+		want   map[string]float64
+	}{
+		{"пресная вода", Fresh, map[string]float64{"P": 2013.25, "T2": 20},
+			map[string]float64{FieldDepth: 10.243, FieldSoundSpeed: round(SoundSpeedMackenzie(20, 0, 10.243), 1e3)}},
+		{"морская вода", Config{SoundSpeed: ChenMillero, Latitude: 30}, map[string]float64{"P": 1001013.25, "T2": 40 / 1.00024},
+			map[string]float64{FieldDepth: 9712.653, FieldSoundSpeed: round(SoundSpeedChenMillero(40/1.00024, 35, 10000), 1e3)}},
+		{"солёность по электропроводности", Config{Conductivity: "C", SurfacePressure: 1000},
+			map[string]float64{"P": 1000, "T2": 15 / 1.00024, "C": C3515},
+			map[string]float64{FieldDepth: 0, FieldSalinity: 35, FieldSoundSpeed: round(SoundSpeedMackenzie(15/1.00024, 35, 0), 1e3)}},
+		{"нет электропроводности", Config{Conductivity: "C"}, map[string]float64{"P": 1013.25, "T2": 10},
+			map[string]float64{FieldDepth: 0}},
+		{"нет давления", Sea, map[string]float64{"T2": 10}, map[string]float64{}},
+	}
+	for _, tt := range tests {
+		values := tt.values
+		New(&tt.cfg).Apply(values)
+		for name, want := range tt.want {
+			if got, ok := values[name]; !ok || got != want {
+				t.Errorf("%s: %s = %v, ожидается %v", tt.name, name, got, want)
+			}
+		}
+		if n := len(tt.values); len(values) != n {
+			t.Errorf("%s: лишние поля %v", tt.name, values)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "derive.yaml")
+	load := func(text string) (*Config, error) {
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return Load(path)
+	}
+	c, err := load("density: 998.2\nlatitude: 55.7\nsalinity: 0\nsound_speed: chen-millero\n")
+	if err != nil || c.Density != 998.2 || *c.Salinity != 0 || c.SoundSpeed != ChenMillero {
+		t.Errorf("параметры %+v, %v", c, err)
+	}
+	if c, err := Load("fresh"); err != nil || c.Density != Fresh.Density {
+		t.Errorf("fresh: %+v, %v", c, err)
+	}
+	for text, want := range map[string]string{
+		"density: 500\n":        "плотность",
+		"latitude: 91\n":        "широта",
+		"salinity: 50\n":        "солёность",
+		"sound_speed: wilson\n": "формула",
+		"densty: 1000\n":        "densty",
+	} {
+		if _, err := load(text); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: ошибка %v, ожидается %q", text, err, want)
+		}
+	}
+}
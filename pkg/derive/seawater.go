@@ -0,0 +1,92 @@
+package derive
+
+import "math"
+
+// Формулы UNESCO 1983 (Fofonoff, Millard. Algorithms for computation of
+// fundamental properties of seawater. UNESCO technical papers in marine
+// science 44) и Mackenzie 1981. Давление — избыточное, в децибарах;
+// температура — по шкале ITS-90, для формул в IPTS-68 пересчитывается.
+
+// t68 переводит температуру ITS-90 в IPTS-68.
+func t68(t90 float64) float64 { return 1.00024 * t90 }
+
+// Gravity — ускорение свободного падения на широте lat (градусы) и
+// давлении p, м/с².
+func Gravity(lat, p float64) float64 {
+	x := math.Sin(lat * math.Pi / 180)
+	x *= x
+	return 9.780318*(1+(5.2788e-3+2.36e-5*x)*x) + 1.092e-6*p
+}
+
+// DepthUNESCO — глубина в морской воде (S = 35, T = 0 °C) по давлению p
+// на широте lat, м.
+func DepthUNESCO(p, lat float64) float64 {
+	return (((-1.82e-15*p+2.279e-10)*p-2.2512e-5)*p + 9.72659) * p / Gravity(lat, p)
+}
+
+// DepthHydrostatic — глубина в жидкости постоянной плотности rho
+// (кг/м³) по давлению p на широте lat, м.
+func DepthHydrostatic(p, rho, lat float64) float64 {
+	return p * 1e4 / (rho * Gravity(lat, 0))
+}
+
+// SoundSpeedMackenzie — скорость звука по формуле Маккензи (1981) при
+// температуре t (°C), солёности s и глубине d (м), м/с.
+func SoundSpeedMackenzie(t, s, d float64) float64 {
+	return 1448.96 + 4.591*t - 5.304e-2*t*t + 2.374e-4*t*t*t +
+		1.340*(s-35) + 1.630e-2*d + 1.675e-7*d*d -
+		1.025e-2*t*(s-35) - 7.139e-13*t*d*d*d
+}
+
+// SoundSpeedChenMillero — скорость звука по формуле Чена — Миллеро
+// (UNESCO 1983) при температуре t (°C), солёности s и давлении p, м/с.
+func SoundSpeedChenMillero(t, s, p float64) float64 {
+	t = t68(t)
+	p /= 10 // бар
+	sr := math.Sqrt(math.Abs(s))
+
+	// Член S²
+	d := 1.727e-3 - 7.9836e-6*p
+
+	// Член S^3/2
+	b1 := 7.3637e-5 + 1.7945e-7*t
+	b0 := -1.922e-2 - 4.42e-5*t
+	b := b0 + b1*p
+
+	// Член S
+	a3 := (-3.389e-13*t+6.649e-12)*t + 1.100e-10
+	a2 := ((7.988e-12*t-1.6002e-10)*t+9.1041e-9)*t - 3.9064e-7
+	a1 := (((-2.0122e-10*t+1.0507e-8)*t-6.4885e-8)*t-1.2580e-5)*t + 9.4742e-5
+	a0 := (((-3.21e-8*t+2.006e-6)*t+7.164e-5)*t-1.262e-2)*t + 1.389
+	a := ((a3*p+a2)*p+a1)*p + a0
+
+	// Член S⁰
+	c3 := (-2.3643e-12*t+3.8504e-10)*t - 9.7729e-9
+	c2 := (((1.0405e-12*t-2.5335e-10)*t+2.5974e-8)*t-1.7107e-6)*t + 3.1260e-5
+	c1 := (((-6.1185e-10*t+1.3621e-7)*t-8.1788e-6)*t+6.8982e-4)*t + 0.153563
+	c0 := ((((3.1464e-9*t-1.47800e-6)*t+3.3420e-4)*t-5.80852e-2)*t+5.03711)*t + 1402.388
+	c := ((c3*p+c2)*p+c1)*p + c0
+
+	return c + (a+b*sr+d*s)*s
+}
+
+// C3515 — электропроводность морской воды при S = 35, T = 15 °C и
+// атмосферном давлении, мСм/см.
+const C3515 = 42.914
+
+// SalinityPSS78 — практическая солёность (PSS-78) по электропроводности
+// c (мСм/см), температуре t (°C) и давлению p.
+func SalinityPSS78(c, t, p float64) float64 {
+	r := c / C3515
+	t = t68(t)
+
+	rt := 0.6766097 + (2.00564e-2+(1.104259e-4+(-6.9698e-7+1.0031e-9*t)*t)*t)*t
+	rp := 1 + p*(2.070e-5+(-6.370e-10+3.989e-15*p)*p)/
+		(1+(3.426e-2+4.464e-4*t)*t+(4.215e-1-3.107e-3*t)*r)
+	x := math.Sqrt(math.Abs(r / (rp * rt)))
+
+	dt := t - 15
+	ds := dt / (1 + 0.0162*dt) *
+		(0.0005 + (-0.0056+(-0.0066+(-0.0375+(0.0636-0.0144*x)*x)*x)*x)*x)
+	return 0.0080 + (-0.1692+(25.3851+(14.0941+(-7.0261+2.7081*x)*x)*x)*x)*x + ds
+}
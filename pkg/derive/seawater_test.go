@@ -0,0 +1,30 @@
+package derive
+
+import (
+	"math"
+	"testing"
+)
+
+// Контрольные значения — из UNESCO technical papers in marine science 44
+// и статьи Mackenzie 1981; температуры заданы в IPTS-68.
+func TestSeawater(t *testing.T) {
+	tests := []struct {
+		name      string
+		got, want float64
+		tolerance float64
+	}{
+		{"DepthUNESCO", DepthUNESCO(10000, 30), 9712.653, 1e-3},
+		{"SoundSpeedChenMillero", SoundSpeedChenMillero(40/1.00024, 40, 10000), 1731.995, 1e-3},
+		{"SoundSpeedChenMillero S=0", SoundSpeedChenMillero(0, 0, 0), 1402.388, 1e-3},
+		{"SalinityPSS78", SalinityPSS78(1.888091*C3515, 40/1.00024, 10000), 40.0000, 1e-4},
+		{"SalinityPSS78 C3515", SalinityPSS78(C3515, 15/1.00024, 0), 35.0000, 1e-4},
+		{"SoundSpeedMackenzie", SoundSpeedMackenzie(25, 35, 1000), 1550.744, 1e-3},
+		{"Gravity", Gravity(0, 0), 9.780318, 1e-9},
+		{"DepthHydrostatic", DepthHydrostatic(10, 1000, 0), 10.224, 1e-3},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > tt.tolerance {
+			t.Errorf("%s = %.6f, ожидается %.6f", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package emulator
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// BridgeTimeLayout — формат метки времени, которую мост добавляет к строке.
+const BridgeTimeLayout = sensor.BridgeTimeLayout
+
+// BridgeWriter ведёт себя как readCOMPort в мостах: накапливает байты до
+// '\n' и отправляет каждую полную строку с префиксом "<метка>\t".
+// Позволяет подключать оператор к эмулятору напрямую, без моста.
+type BridgeWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewBridgeWriter(w io.Writer) *BridgeWriter {
+	return &BridgeWriter{w: w}
+}
+
+func (b *BridgeWriter) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	for {
+		i := bytes.IndexByte(b.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := b.buf[:i+1]
+		stamped := make([]byte, 0, len(BridgeTimeLayout)+1+len(line))
+		stamped = append(stamped, time.Now().Format(BridgeTimeLayout)...)
+		stamped = append(stamped, '\t')
+		stamped = append(stamped, line...)
+		if _, err := b.w.Write(stamped); err != nil {
+			return len(p), err
+		}
+		b.buf = b.buf[i+1:]
+	}
+}
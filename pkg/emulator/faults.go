@@ -0,0 +1,74 @@
+package emulator
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// FaultKind — вид неисправности, которую эмулятор вносит в поток.
+type FaultKind string
+
+const (
+	FaultNone       FaultKind = ""
+	FaultDropout    FaultKind = "dropout"    // датчик отвалился: значения вне диапазона, строки не печатаются
+	FaultGarbage    FaultKind = "garbage"    // случайные байты между строками
+	FaultPartial    FaultKind = "partial"    // обрезанные строки без перевода строки
+	FaultBaud       FaultKind = "baud"       // несовпадение скорости: вместо строк шум
+	FaultSilence    FaultKind = "silence"    // прошивка работает, но в порт ничего не приходит
+	FaultDisconnect FaultKind = "disconnect" // обрыв соединения
+	FaultReset      FaultKind = "reset"      // зависание и сброс по сторожевому таймеру
+)
+
+// ErrDisconnect возвращается из Run, когда сценарий обрывает соединение.
+var ErrDisconnect = errors.New("emulator: обрыв соединения по сценарию")
+
+// Fault — неисправность, действующая в данный момент.
+type Fault struct {
+	Kind FaultKind
+	// Rate — доля затронутых строк для garbage и partial (0 — все).
+	Rate float64
+	// Phase — сквозной номер фазы сценария; reset срабатывает один раз
+	// при входе в фазу.
+	Phase int
+}
+
+// FaultSource сообщает, какая неисправность действует в момент t.
+type FaultSource interface {
+	FaultAt(t time.Duration) Fault
+}
+
+// hits — затронута ли очередная строка при заданной доле.
+func (f Fault) hits(r *rand.Rand) bool {
+	return f.Rate <= 0 || r.Float64() < f.Rate
+}
+
+// corrupt применяет неисправность к строке, которую прошивка собирается
+// напечатать (вместе с "\r\n"), и возвращает то, что уйдёт в порт.
+func (f Fault) corrupt(r *rand.Rand, line []byte) ([]byte, error) {
+	switch f.Kind {
+	case FaultSilence:
+		return nil, nil
+	case FaultDisconnect:
+		return nil, ErrDisconnect
+	case FaultGarbage:
+		if f.hits(r) {
+			return append(randomBytes(r, 1+r.Intn(40)), line...), nil
+		}
+	case FaultPartial:
+		if f.hits(r) && len(line) > 1 {
+			return line[:r.Intn(len(line)-1)], nil
+		}
+	case FaultBaud:
+		// При неверной скорости UART каждый байт превращается в мусор,
+		// а '\n' встречается лишь случайно.
+		return randomBytes(r, len(line)), nil
+	}
+	return line, nil
+}
+
+func randomBytes(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	r.Read(b)
+	return b
+}
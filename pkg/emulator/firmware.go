@@ -0,0 +1,250 @@
+// Пакет emulator воспроизводит поведение прошивки sketch_sep02a.ino
+// (MS5837 + TSYS01), чтобы мосты и оператор можно было проверять без
+// Arduino.
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Константы прошивки и библиотеки MS5837.
+const (
+	FluidDensity    = 1029.0          // sensor_ms5837.setFluidDensity(1029)
+	WatchdogTimeout = 8 * time.Second // wdt_enable(WDTO_8S)
+	InitRetries     = 3               // nretry < 3
+	LoopPeriod      = time.Second     // delay(100) + delay(900)
+)
+
+// Conditions — физические условия вокруг датчиков.
+type Conditions struct {
+	Pressure     float64 // мбар, MS5837
+	Temperature  float64 // °C, MS5837 (T1)
+	Temperature2 float64 // °C, TSYS01 (T2)
+}
+
+// Environment выдаёт условия на момент t от начала работы эмулятора.
+type Environment interface {
+	At(t time.Duration) Conditions
+}
+
+// Tank — неподвижный датчик на постоянной глубине в бассейне.
+type Tank struct {
+	Depth       float64 // м
+	Temperature float64 // °C
+}
+
+func (e Tank) At(time.Duration) Conditions {
+	p := PressureAtDepth(e.Depth)
+	return Conditions{Pressure: p, Temperature: e.Temperature, Temperature2: e.Temperature}
+}
+
+// PressureAtDepth — обратная к MS5837::depth() формула, мбар.
+func PressureAtDepth(depth float64) float64 {
+	return (depth*FluidDensity*9.80665 + 101300) / 100
+}
+
+// Reading — показания в том виде, в каком их печатает прошивка.
+type Reading struct {
+	P, T1, Depth, Alt, T2 float64
+}
+
+// NewReading вычисляет Depth и Alt так же, как библиотека MS5837.
+func NewReading(c Conditions) Reading {
+	return Reading{
+		P:     c.Pressure,
+		T1:    c.Temperature,
+		Depth: (c.Pressure*100 - 101300) / (FluidDensity * 9.80665),
+		Alt:   (1 - math.Pow(c.Pressure/1013.25, .190284)) * 145366.45 * .3048,
+		T2:    c.Temperature2,
+	}
+}
+
+// InRange повторяет проверку диапазона в loop(): строки вне диапазона
+// прошивка молча не печатает.
+func (r Reading) InRange() bool {
+	return r.P > 500 && r.T1 > -3 && r.T2 > -3 && r.P < 6000 && r.T1 < 100 && r.T2 < 100
+}
+
+// String форматирует строку как Serial.print(float) — два знака после точки.
+func (r Reading) String() string {
+	return fmt.Sprintf("P:%.2f, T1:%.2f, Depth:%.2f, Alt:%.2f, T2:%.2f",
+		r.P, r.T1, r.Depth, r.Alt, r.T2)
+}
+
+// Firmware эмулирует sketch_sep02a.ino.
+type Firmware struct {
+	Env  Environment
+	Rand *rand.Rand
+
+	// Noise — СКО шума показаний (мбар для P, °C для температур).
+	Noise float64
+
+	// MS5837Failures и TSYS01Failures — сколько попыток init() подряд
+	// завершаются ошибкой при каждой загрузке. Больше InitRetries —
+	// прошивка зависает и перезагружается по сторожевому таймеру.
+	MS5837Failures int
+	TSYS01Failures int
+
+	// HangEvery — через сколько времени работы loop() прошивка зависает
+	// и перезагружается сторожевым таймером. Ноль — не зависает.
+	HangEvery time.Duration
+
+	// Speed ускоряет время эмулятора (2 — вдвое быстрее). Ноль — 1.
+	Speed float64
+
+	// Faults — источник неисправностей (обычно сценарий). Может быть nil.
+	Faults FaultSource
+
+	// Start — начало отсчёта времени эмулятора. Если не задано, отсчёт
+	// идёт от вызова Run; общий Start позволяет нескольким клиентам
+	// проходить сценарий синхронно.
+	Start time.Time
+
+	resetPhase int
+}
+
+// NewFirmware создаёт эмулятор с параметрами по умолчанию.
+func NewFirmware(env Environment, seed int64) *Firmware {
+	return &Firmware{
+		Env:   env,
+		Rand:  rand.New(rand.NewSource(seed)),
+		Noise: 0.05,
+	}
+}
+
+// Run печатает в w то же, что прошивка в Serial, пока не отменён ctx или
+// не произошла ошибка записи.
+func (f *Firmware) Run(ctx context.Context, w io.Writer) error {
+	if f.Start.IsZero() {
+		f.Start = time.Now()
+	}
+	if f.Rand == nil {
+		f.Rand = rand.New(rand.NewSource(1))
+	}
+	f.resetPhase = -1
+	for {
+		err := f.boot(ctx, w)
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// boot — одна загрузка: setup() и loop() до зависания.
+func (f *Firmware) boot(ctx context.Context, w io.Writer) error {
+	if err := f.println(w, "Starting"); err != nil {
+		return err
+	}
+
+	msOK, err := f.initSensor(ctx, w, "MS5837", f.MS5837Failures)
+	if err != nil || ctx.Err() != nil {
+		return err
+	}
+	tsOK, err := f.initSensor(ctx, w, "TSYS01", f.TSYS01Failures)
+	if err != nil || ctx.Err() != nil {
+		return err
+	}
+	if !msOK || !tsOK {
+		if err := f.println(w, "It is nesessary for both sensors to work together"); err != nil {
+			return err
+		}
+		// while (1) { delay(1000); } без wdt_reset()
+		f.sleep(ctx, WatchdogTimeout)
+		return nil
+	}
+
+	booted := f.elapsed()
+	for {
+		fault := f.fault()
+		hang := fault.Kind == FaultReset && fault.Phase != f.resetPhase
+		if hang {
+			f.resetPhase = fault.Phase
+		}
+		if hang || f.HangEvery > 0 && f.elapsed()-booted >= f.HangEvery {
+			f.sleep(ctx, WatchdogTimeout)
+			return nil
+		}
+		if !f.sleep(ctx, LoopPeriod/10) {
+			return nil
+		}
+		r := NewReading(f.noisy(f.Env.At(f.elapsed())))
+		if f.fault().Kind == FaultDropout {
+			// Отвалившийся по I2C датчик возвращает мусор, который
+			// отсекается проверкой диапазона.
+			r.T2 = math.NaN()
+		}
+		if r.InRange() {
+			if err := f.println(w, r.String()); err != nil {
+				return err
+			}
+		}
+		if !f.sleep(ctx, LoopPeriod-LoopPeriod/10) {
+			return nil
+		}
+	}
+}
+
+func (f *Firmware) initSensor(ctx context.Context, w io.Writer, name string, failures int) (bool, error) {
+	for n := 0; n < InitRetries && n < failures; n++ {
+		if err := f.println(w, name+" Init failed, retry in 1 sec"); err != nil {
+			return false, err
+		}
+		if !f.sleep(ctx, time.Second) {
+			return false, nil
+		}
+	}
+	return failures <= InitRetries, nil
+}
+
+func (f *Firmware) noisy(c Conditions) Conditions {
+	if f.Noise == 0 || f.Rand == nil {
+		return c
+	}
+	c.Pressure += f.Rand.NormFloat64() * f.Noise
+	c.Temperature += f.Rand.NormFloat64() * f.Noise
+	c.Temperature2 += f.Rand.NormFloat64() * f.Noise
+	return c
+}
+
+// println — аналог Serial.println: строка завершается "\r\n". Текущая
+// неисправность может исказить или подавить строку.
+func (f *Firmware) println(w io.Writer, s string) error {
+	out, err := f.fault().corrupt(f.Rand, []byte(s+"\r\n"))
+	if err != nil || len(out) == 0 {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func (f *Firmware) fault() Fault {
+	if f.Faults == nil {
+		return Fault{Phase: -1}
+	}
+	return f.Faults.FaultAt(f.elapsed())
+}
+
+func (f *Firmware) speed() float64 {
+	if f.Speed <= 0 {
+		return 1
+	}
+	return f.Speed
+}
+
+// elapsed — время эмулятора с начала Run с учётом ускорения.
+func (f *Firmware) elapsed() time.Duration {
+	return time.Duration(float64(time.Since(f.Start)) * f.speed())
+}
+
+// sleep ждёт d времени эмулятора; возвращает false, если ctx отменён.
+func (f *Firmware) sleep(ctx context.Context, d time.Duration) bool {
+	return sleepCtx(ctx, time.Duration(float64(d)/f.speed()))
+}
@@ -0,0 +1,95 @@
+package emulator_test
+
+import (
+	"bufio"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/emulator"
+	"github.com/physicist2018/goserialcomm/pkg/serialport"
+)
+
+// TestFirmwareOverLoopback проверяет по строкам, принятым хостом через
+// loopback-порт, повторы init() и перезагрузки по сторожевому таймеру
+// так, как их делает sketch_sep02a.ino.
+func TestFirmwareOverLoopback(t *testing.T) {
+	const (
+		start   = "Starting"
+		ms      = "MS5837 Init failed, retry in 1 sec"
+		ts      = "TSYS01 Init failed, retry in 1 sec"
+		both    = "It is nesessary for both sensors to work together"
+		reading = "P:" // одна или несколько строк показаний подряд
+	)
+	tests := []struct {
+		name  string
+		setup func(f *emulator.Firmware)
+		want  []string
+	}{
+		{"без сбоев", func(*emulator.Firmware) {}, []string{start, reading}},
+		{"MS5837 со второй попытки", func(f *emulator.Firmware) { f.MS5837Failures = 1 },
+			[]string{start, ms, reading}},
+		{"MS5837 с последней попытки", func(f *emulator.Firmware) { f.MS5837Failures = emulator.InitRetries },
+			[]string{start, ms, ms, ms, reading}},
+		{"TSYS01 не отвечает", func(f *emulator.Firmware) { f.TSYS01Failures = emulator.InitRetries + 1 },
+			[]string{start, ts, ts, ts, both, start, ts, ts, ts, both, start}},
+		{"оба датчика со сбоями", func(f *emulator.Firmware) {
+			f.MS5837Failures = emulator.InitRetries + 1
+			f.TSYS01Failures = 2
+		}, []string{start, ms, ms, ms, ts, ts, both, start}},
+		{"зависание loop()", func(f *emulator.Firmware) { f.HangEvery = 5 * emulator.LoopPeriod },
+			[]string{start, reading, start, reading, start}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := emulator.NewFirmware(emulator.Tank{Depth: 1, Temperature: 20}, 1)
+			f.Noise = 0
+			f.Speed = 200
+			tt.setup(f)
+
+			got := runFirmware(t, f, len(tt.want), reading)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("строки:\n%q\nожидается:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// runFirmware запускает f на стороне устройства loopback-пары и читает
+// строки на стороне хоста, пока не наберёт n. Подряд идущие показания
+// заменяются одной строкой reading.
+func runFirmware(t *testing.T, f *emulator.Firmware, n int, reading string) []string {
+	t.Helper()
+	device, host := serialport.NewLoopback()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	done := make(chan error, 1)
+	go func() { done <- f.Run(ctx, device) }()
+	go func() {
+		// Закрытие прерывает чтение, если строк так и не дождались.
+		<-ctx.Done()
+		host.Close()
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	var lines []string
+	sc := bufio.NewScanner(host)
+	for len(lines) < n && sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if strings.HasPrefix(line, reading) {
+			if len(lines) > 0 && lines[len(lines)-1] == reading {
+				continue
+			}
+			line = reading
+		}
+		lines = append(lines, line)
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("за отведённое время принято %d строк из %d: %q", len(lines), n, lines)
+	}
+	return lines
+}
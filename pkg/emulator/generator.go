@@ -0,0 +1,170 @@
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generator выдаёт значение канала в момент t от начала потока.
+type Generator interface {
+	Value(t time.Duration) float64
+}
+
+type constGen struct{ v float64 }
+
+func (g constGen) Value(time.Duration) float64 { return g.v }
+
+type sineGen struct {
+	offset, amp float64
+	period      time.Duration
+}
+
+func (g sineGen) Value(t time.Duration) float64 {
+	return g.offset + g.amp*math.Sin(2*math.Pi*float64(t)/float64(g.period))
+}
+
+// walkGen — случайное блуждание: каждый вызов добавляет N(0, sigma).
+type walkGen struct {
+	v, sigma float64
+	rand     *rand.Rand
+}
+
+func (g *walkGen) Value(time.Duration) float64 {
+	g.v += g.rand.NormFloat64() * g.sigma
+	return g.v
+}
+
+// stepGen — меандр между low и high с заданным периодом.
+type stepGen struct {
+	low, high float64
+	period    time.Duration
+}
+
+func (g stepGen) Value(t time.Duration) float64 {
+	if t%g.period < g.period/2 {
+		return g.low
+	}
+	return g.high
+}
+
+// sawGen — пила: рост от low до high за период и сброс к low.
+type sawGen struct {
+	low, high float64
+	period    time.Duration
+}
+
+func (g sawGen) Value(t time.Duration) float64 {
+	return g.low + (g.high-g.low)*float64(t%g.period)/float64(g.period)
+}
+
+// ParseGenerator разбирает описание генератора вида "вид:параметры":
+//
+//	const:20           постоянное значение
+//	sine:20,2.5,60s    смещение, амплитуда, период
+//	walk:20,0.1        начальное значение, СКО шага
+//	step:20,25,30s     нижний и верхний уровень, период
+//	saw:20,25,20s      нижний и верхний уровень, период
+func ParseGenerator(spec string, r *rand.Rand) (Generator, error) {
+	kind, args, _ := strings.Cut(spec, ":")
+	arity := map[string]int{"const": 1, "sine": 3, "walk": 2, "step": 3, "saw": 3}
+	n, ok := arity[kind]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный генератор %q (const, sine, walk, step, saw)", kind)
+	}
+	parts := strings.Split(args, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("генератор %q: ожидается параметров: %d", spec, n)
+	}
+
+	// Третий параметр sine, step и saw — период, остальные — числа.
+	nums := make([]float64, n)
+	var period time.Duration
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		var err error
+		if i == 2 {
+			period, err = time.ParseDuration(p)
+			if err == nil && period <= 0 {
+				err = fmt.Errorf("период должен быть положительным")
+			}
+		} else {
+			nums[i], err = strconv.ParseFloat(p, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("генератор %q: %w", spec, err)
+		}
+	}
+
+	switch kind {
+	case "const":
+		return constGen{nums[0]}, nil
+	case "sine":
+		return sineGen{nums[0], nums[1], period}, nil
+	case "walk":
+		return &walkGen{v: nums[0], sigma: nums[1], rand: r}, nil
+	case "saw":
+		return sawGen{nums[0], nums[1], period}, nil
+	default:
+		return stepGen{nums[0], nums[1], period}, nil
+	}
+}
+
+// Channel — именованный канал синтетического потока.
+type Channel struct {
+	Name      string
+	Unit      string
+	Generator Generator
+}
+
+// CSVStream печатает для каждого канала с частотой Rate строки
+// исходного тестового сервера
+//
+//	2025-08-29 03:06:48,temperature,21.5,C
+//
+// или, если задан Precise, строки с миллисекундами и тремя знаками
+// после точки без столбца единиц:
+//
+//	2025-08-29 03:06:48.120,temperature,21.537
+type CSVStream struct {
+	Rate     float64 // Гц
+	Channels []Channel
+	Precise  bool
+}
+
+func (s *CSVStream) Run(ctx context.Context, w io.Writer) error {
+	if s.Rate <= 0 {
+		return fmt.Errorf("emulator: частота должна быть положительной")
+	}
+	// start берётся до запуска таймера, чтобы время от начала на тике
+	// было не меньше периода: иначе пила сбрасывается на тик позже.
+	start := time.Now()
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / s.Rate))
+	defer ticker.Stop()
+
+	var buf []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			buf = buf[:0]
+			for _, ch := range s.Channels {
+				v := ch.Generator.Value(now.Sub(start))
+				if s.Precise {
+					buf = fmt.Appendf(buf, "%s,%s,%.3f\n", now.Format("2006-01-02 15:04:05.000"), ch.Name, v)
+				} else {
+					buf = fmt.Appendf(buf, "%s,%s,%.1f,%s\n", now.Format("2006-01-02 15:04:05"), ch.Name, v, ch.Unit)
+				}
+			}
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+}
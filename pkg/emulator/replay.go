@@ -0,0 +1,213 @@
+package emulator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+)
+
+// Метаданные, которые cmd/operator пишет в начало и конец файла.
+const (
+	metaName        = "Название эксперимента:"
+	metaDescription = "Описание:"
+	metaStart       = "Время начала:"
+	metaOperator    = "Оператор:"
+	metaSchema      = "Версия схемы:"
+	metaEnd         = "Время окончания:"
+	metaDuration    = "Длительность эксперимента:"
+)
+
+// Record — одна строка данных записи.
+type Record struct {
+	Time time.Time
+	Line string // строка в том виде, в каком её напечатала прошивка
+}
+
+// Recording — файл эксперимента, записанный cmd/operator.
+type Recording struct {
+	Name        string
+	Description string
+	Start, End  time.Time
+	Records     []Record
+}
+
+// LoadRecording читает файл эксперимента cmd/operator: как CSV по схеме
+// пакета recording (с манифестом рядом), так и старый формат с "raw_data".
+func LoadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &Recording{}
+	if rd, err := recording.NewReader(bytes.NewReader(data)); err == nil && !rd.Legacy() && hasColumn(rd.Header(), recording.ColSeq) {
+		err = rec.readCSV(rd)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if m, err := recording.ReadManifest(recording.ManifestPath(path)); err == nil {
+			rec.Name, rec.Description, rec.Start = m.Name, m.Description, m.Start
+			if m.End != nil {
+				rec.End = *m.End
+			}
+		}
+		return rec, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "timestamp"), rec.parseMeta(line):
+		default:
+			row, ok := recording.ParseLegacyLine(line)
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: нет метки времени: %q", path, n, line)
+			}
+			t := row.Sample.SourceTime
+			if t.IsZero() {
+				t = row.Time
+			}
+			rec.Records = append(rec.Records, Record{Time: t, Line: row.Sample.Text})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	spreadWithinSecond(rec.Records)
+	return rec, nil
+}
+
+func hasColumn(header []string, name string) bool {
+	for _, h := range header {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (rec *Recording) readCSV(rd *recording.Reader) error {
+	for _, line := range rd.Preamble {
+		rec.parseMeta(line)
+	}
+	for {
+		row, err := rd.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if row.Event != "" {
+			continue
+		}
+		rec.Records = append(rec.Records, Record{Time: row.Time, Line: row.Sample.Text})
+	}
+}
+
+// parseMeta разбирает строку метаданных; false — строка не метаданные.
+func (rec *Recording) parseMeta(line string) bool {
+	switch {
+	case strings.HasPrefix(line, metaName):
+		rec.Name = strings.TrimSpace(strings.TrimPrefix(line, metaName))
+	case strings.HasPrefix(line, metaDescription):
+		rec.Description = strings.TrimSpace(strings.TrimPrefix(line, metaDescription))
+	case strings.HasPrefix(line, metaStart):
+		rec.Start, _ = parseOperatorTime(strings.TrimPrefix(line, metaStart))
+	case strings.HasPrefix(line, metaEnd):
+		rec.End, _ = parseOperatorTime(strings.TrimPrefix(line, metaEnd))
+	case strings.HasPrefix(line, metaOperator), strings.HasPrefix(line, metaSchema),
+		strings.HasPrefix(line, metaDuration):
+	default:
+		return false
+	}
+	return true
+}
+
+func parseOperatorTime(s string) (time.Time, error) {
+	return time.ParseInLocation(recording.LegacyTimeLayout, strings.TrimSpace(s), time.Local)
+}
+
+// spreadWithinSecond равномерно распределяет строки с одинаковой
+// секундной меткой внутри этой секунды, чтобы при воспроизведении они
+// не уходили пачкой.
+func spreadWithinSecond(records []Record) {
+	for i := 0; i < len(records); {
+		j := i + 1
+		for j < len(records) && records[j].Time.Equal(records[i].Time) {
+			j++
+		}
+		step := time.Second / time.Duration(j-i)
+		for k := i; k < j; k++ {
+			records[k].Time = records[k].Time.Add(time.Duration(k-i) * step)
+		}
+		i = j
+	}
+}
+
+// Duration — длительность записи по меткам времени строк.
+func (r *Recording) Duration() time.Duration {
+	if len(r.Records) == 0 {
+		return 0
+	}
+	return r.Records[len(r.Records)-1].Time.Sub(r.Records[0].Time)
+}
+
+// Replay повторяет строки записи с исходными интервалами.
+type Replay struct {
+	Recording *Recording
+	// Speed — ускорение: 1 — исходный темп, 10 — в десять раз быстрее,
+	// 0 — без пауз.
+	Speed float64
+	// Loop — начинать запись сначала после последней строки.
+	Loop bool
+}
+
+// Run печатает строки записи в w с "\r\n", как прошивка.
+func (p *Replay) Run(ctx context.Context, w io.Writer) error {
+	records := p.Recording.Records
+	if len(records) == 0 {
+		return fmt.Errorf("emulator: запись не содержит строк данных")
+	}
+	for {
+		for i, r := range records {
+			if i > 0 && p.Speed > 0 {
+				gap := time.Duration(float64(r.Time.Sub(records[i-1].Time)) / p.Speed)
+				if !sleepCtx(ctx, gap) {
+					return nil
+				}
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			if _, err := io.WriteString(w, r.Line+"\r\n"); err != nil {
+				return err
+			}
+		}
+		if !p.Loop {
+			return nil
+		}
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
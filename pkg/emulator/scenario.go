@@ -0,0 +1,197 @@
+package emulator
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario — последовательность фаз с заданной длительностью. Реализует
+// Environment и FaultSource, поэтому подключается к Firmware напрямую.
+//
+// Пример файла:
+//
+//	seed: 42
+//	loop: false
+//	phases:
+//	  - name: на поверхности
+//	    duration: 30s
+//	    depth: 0
+//	    temp: 20
+//	  - name: погружение
+//	    duration: 2m
+//	    depth: [0, 30]
+//	    temp: [20, 8]
+//	  - name: мусор в линии
+//	    duration: 20s
+//	    fault: garbage
+//	    rate: 0.3
+//	  - duration: 10s
+//	    fault: disconnect
+type Scenario struct {
+	Seed   *int64  `yaml:"seed"`
+	Loop   bool    `yaml:"loop"`
+	Phases []Phase `yaml:"phases"`
+}
+
+// Phase — отрезок сценария. Незаданные величины продолжаются с конечного
+// значения предыдущей фазы.
+type Phase struct {
+	Name     string        `yaml:"name"`
+	Duration time.Duration `yaml:"duration"`
+	Depth    *Ramp         `yaml:"depth"`
+	Temp     *Ramp         `yaml:"temp"`
+	Temp2    *Ramp         `yaml:"temp2"`
+	Fault    FaultKind     `yaml:"fault"`
+	Rate     float64       `yaml:"rate"`
+}
+
+// Ramp — линейное изменение величины за фазу. В YAML задаётся числом
+// (постоянное значение) или парой [от, до].
+type Ramp struct {
+	From, To float64
+}
+
+func (r *Ramp) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		var v float64
+		if err := node.Decode(&v); err != nil {
+			return err
+		}
+		r.From, r.To = v, v
+		return nil
+	case yaml.SequenceNode:
+		var v []float64
+		if err := node.Decode(&v); err != nil {
+			return err
+		}
+		if len(v) != 2 {
+			return fmt.Errorf("строка %d: ожидается [от, до]", node.Line)
+		}
+		r.From, r.To = v[0], v[1]
+		return nil
+	}
+	return fmt.Errorf("строка %d: ожидается число или [от, до]", node.Line)
+}
+
+func (r *Ramp) at(frac float64) float64 {
+	return r.From + (r.To-r.From)*frac
+}
+
+// LoadScenario читает сценарий из YAML-файла и проверяет его.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Scenario
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := s.resolve(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// resolve проверяет фазы и заполняет незаданные величины.
+func (s *Scenario) resolve() error {
+	if len(s.Phases) == 0 {
+		return fmt.Errorf("сценарий не содержит фаз")
+	}
+	depth, temp := Ramp{0, 0}, Ramp{20, 20}
+	var temp2 *Ramp
+	for i := range s.Phases {
+		p := &s.Phases[i]
+		if p.Duration <= 0 {
+			return fmt.Errorf("фаза %d (%s): не задана длительность", i+1, p.Name)
+		}
+		switch p.Fault {
+		case FaultNone, FaultDropout, FaultGarbage, FaultPartial, FaultBaud,
+			FaultSilence, FaultDisconnect, FaultReset:
+		default:
+			return fmt.Errorf("фаза %d (%s): неизвестная неисправность %q", i+1, p.Name, p.Fault)
+		}
+		if p.Depth == nil {
+			p.Depth = &Ramp{depth.To, depth.To}
+		}
+		if p.Temp == nil {
+			p.Temp = &Ramp{temp.To, temp.To}
+		}
+		if p.Temp2 == nil {
+			// T2 по умолчанию следует за T1, пока не задана явно.
+			if temp2 == nil {
+				p.Temp2 = p.Temp
+			} else {
+				p.Temp2 = &Ramp{temp2.To, temp2.To}
+			}
+		} else {
+			temp2 = p.Temp2
+		}
+		depth, temp = *p.Depth, *p.Temp
+	}
+	return nil
+}
+
+// Duration — длительность одного прохода сценария.
+func (s *Scenario) Duration() time.Duration {
+	var d time.Duration
+	for _, p := range s.Phases {
+		d += p.Duration
+	}
+	return d
+}
+
+// phaseAt возвращает фазу, её сквозной номер и долю прошедшего времени
+// фазы. После окончания сценария без loop держится конец последней фазы.
+func (s *Scenario) phaseAt(t time.Duration) (*Phase, int, float64) {
+	total := s.Duration()
+	cycle := 0
+	if t >= total {
+		if !s.Loop {
+			last := len(s.Phases) - 1
+			return &s.Phases[last], last, 1
+		}
+		cycle = int(t / total)
+		t %= total
+	}
+	for i := range s.Phases {
+		p := &s.Phases[i]
+		if t < p.Duration {
+			return p, cycle*len(s.Phases) + i, float64(t) / float64(p.Duration)
+		}
+		t -= p.Duration
+	}
+	last := len(s.Phases) - 1
+	return &s.Phases[last], cycle*len(s.Phases) + last, 1
+}
+
+func (s *Scenario) At(t time.Duration) Conditions {
+	p, _, frac := s.phaseAt(t)
+	return Conditions{
+		Pressure:     PressureAtDepth(p.Depth.at(frac)),
+		Temperature:  p.Temp.at(frac),
+		Temperature2: p.Temp2.at(frac),
+	}
+}
+
+func (s *Scenario) FaultAt(t time.Duration) Fault {
+	p, n, _ := s.phaseAt(t)
+	if t >= s.Duration() && !s.Loop {
+		// Сценарий закончился: неисправности последней фазы снимаются.
+		return Fault{Phase: n}
+	}
+	return Fault{Kind: p.Fault, Rate: p.Rate, Phase: n}
+}
+
+// PhaseName — имя фазы в момент t, для журнала.
+func (s *Scenario) PhaseName(t time.Duration) string {
+	p, n, _ := s.phaseAt(t)
+	if p.Name != "" {
+		return p.Name
+	}
+	return fmt.Sprintf("фаза %d", n%len(s.Phases)+1)
+}
@@ -0,0 +1,213 @@
+// Пакет export выгружает записи экспериментов cmd/operator в форматы
+// для обработки: CSV только с данными, JSON Lines, Parquet и NetCDF в
+// соглашениях CF.
+//
+// Все форматы строятся из Table — строк данных записи, собранных по
+// столбцам, вместе с манифестом:
+//
+//	src, err := entry.Source("")
+//	t, err := export.LoadSource(entry.Dir(), entry.Manifest, src, export.Options{})
+//	err = export.WriteParquet(w, t)
+package export
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/derive"
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// Options — параметры выгрузки.
+type Options struct {
+	// All — выгружать и строки, принятые во время паузы. Такие строки
+	// помечаются в столбце excluded.
+	All bool
+	// Derive — рассчитать производные величины (см. пакет derive); уже
+	// записанные производные поля пересчитываются.
+	Derive *derive.Config
+}
+
+// Table — строки данных записи по столбцам. Строки-события и строки без
+// данных в таблицу не входят.
+type Table struct {
+	// Manifest — манифест записи; nil для файлов без манифеста.
+	Manifest *recording.Manifest
+	Columns  []recording.Column
+
+	Time       []time.Time
+	SourceTime []time.Time // нулевое время — метки моста нет
+	Seq        []int64
+	Values     [][]float64 // по столбцам Columns; NaN — значения нет
+	Excluded   []bool
+
+	// Flags — флаги качества по столбцам Columns (0 — флага нет); nil,
+	// если в записи нет столбцов <поле>_qc.
+	Flags [][]qc.Flag
+
+	// Marks — отметки оператора (события mark) с их текстом.
+	Marks []recording.Note
+}
+
+// Len возвращает число строк.
+func (t *Table) Len() int { return len(t.Time) }
+
+// Load читает файл данных записи (одного источника). Единицы и описания
+// столбцов берутся из манифеста, для старых файлов — из sensor.Fields.
+func Load(dataPath string, m *recording.Manifest, opts Options) (*Table, error) {
+	rd, err := recording.OpenFile(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	var src *recording.Source
+	if m != nil {
+		for _, s := range m.AllSources() {
+			for _, f := range s.Files() {
+				if f == filepath.Base(dataPath) {
+					src = s
+				}
+			}
+		}
+	}
+	t, err := load(rd, m, src, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dataPath, err)
+	}
+	return t, nil
+}
+
+// LoadSource читает все файлы данных (сегменты) источника src из
+// каталога dir.
+func LoadSource(dir string, m *recording.Manifest, src *recording.Source, opts Options) (*Table, error) {
+	rd, err := recording.OpenSource(dir, src)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	t, err := load(rd, m, src, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src.DataFile, err)
+	}
+	return t, nil
+}
+
+func load(rd *recording.Reader, m *recording.Manifest, src *recording.Source, opts Options) (*Table, error) {
+	t := &Table{Manifest: m, Columns: columns(rd.Fields(), src)}
+	var deriver *derive.Deriver
+	if opts.Derive != nil {
+		deriver = derive.New(opts.Derive)
+		t.Columns = withDerived(deriver, t.Columns)
+	}
+	t.Values = make([][]float64, len(t.Columns))
+	if rd.HasQC() {
+		t.Flags = make([][]qc.Flag, len(t.Columns))
+	}
+	for {
+		row, err := rd.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if kind, text := recording.ParseEvent(row.Event); kind == recording.EventMark {
+			t.Marks = append(t.Marks, recording.Note{Time: row.Time, Text: text})
+		}
+		if row.Event != "" || row.Sample.Values == nil {
+			continue
+		}
+		excluded := m != nil && m.Excluded(row.Time)
+		if excluded && !opts.All {
+			continue
+		}
+
+		t.Time = append(t.Time, row.Time)
+		t.SourceTime = append(t.SourceTime, row.Sample.SourceTime)
+		t.Seq = append(t.Seq, row.Seq)
+		t.Excluded = append(t.Excluded, excluded)
+		if deriver != nil {
+			deriver.Apply(row.Sample.Values)
+		}
+		for i, c := range t.Columns {
+			v, ok := row.Sample.Value(c.Name)
+			if !ok {
+				v = math.NaN()
+			}
+			t.Values[i] = append(t.Values[i], v)
+			if t.Flags != nil {
+				t.Flags[i] = append(t.Flags[i], row.Sample.Flags[c.Name])
+			}
+		}
+	}
+	return t, nil
+}
+
+// columns описывает поля файла по источнику из манифеста (src может
+// быть nil) или по полям прошивки.
+func columns(names []string, src *recording.Source) []recording.Column {
+	known := map[string]recording.Column{}
+	for _, c := range recording.Columns(sensor.Fields) {
+		known[c.Name] = c
+	}
+	if src != nil {
+		for _, c := range src.Fields {
+			known[c.Name] = c
+		}
+	}
+	cols := make([]recording.Column, len(names))
+	for i, name := range names {
+		c, ok := known[name]
+		if !ok {
+			c = recording.Column{Name: name}
+		}
+		cols[i] = c
+	}
+	return cols
+}
+
+// withDerived добавляет к cols производные поля; описания уже
+// записанных производных полей заменяются новыми.
+func withDerived(d *derive.Deriver, cols []recording.Column) []recording.Column {
+	fields := make([]sensor.Field, len(cols))
+	index := make(map[string]int)
+	for i, c := range cols {
+		fields[i] = sensor.Field{Name: c.Name}
+		index[c.Name] = i
+	}
+	for _, c := range recording.Columns(d.Fields(fields)) {
+		if i, ok := index[c.Name]; ok {
+			cols[i] = c
+		} else {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// Writer — функция выгрузки таблицы в конкретный формат.
+type Writer func(w io.Writer, t *Table) error
+
+// Formats — форматы выгрузки по именам.
+var Formats = map[string]Writer{
+	"csv":     WriteCSV,
+	"jsonl":   WriteJSONL,
+	"parquet": WriteParquet,
+	"netcdf":  WriteNetCDF,
+}
+
+// FormatNames возвращает имена форматов в алфавитном порядке.
+func FormatNames() []string {
+	names := make([]string, 0, len(Formats))
+	for name := range Formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
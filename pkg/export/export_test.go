@@ -0,0 +1,93 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+var start = time.Date(2025, 8, 29, 3, 6, 48, 0, time.UTC)
+
+// testTable — три строки: у первой нет метки моста, у второй нет T2,
+// третья принята во время паузы; флаги качества есть только у P.
+func testTable() *Table {
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	m := &recording.Manifest{SchemaVersion: recording.SchemaVersion, Name: "Погружение", Start: start}
+	return &Table{
+		Manifest: m,
+		Columns: []recording.Column{
+			{Name: "P", Unit: "mbar", Description: "Давление", StandardName: "sea_water_pressure"},
+			{Name: "T2", Unit: "degC", Description: "Температура"},
+		},
+		Time:       []time.Time{at(1250), at(2250), at(3500)},
+		SourceTime: []time.Time{{}, at(2000), at(3000)},
+		Seq:        []int64{1, 2, 4},
+		Values:     [][]float64{{1013.25, 1020.5, 1100}, {20.05, math.NaN(), 19.5}},
+		Excluded:   []bool{false, false, true},
+		Flags:      [][]qc.Flag{{qc.Pass, qc.Suspect, 0}, nil},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "exp.csv")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := recording.NewWriter(f, sensor.Fields[:2])
+	w.WriteHeader()
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+	rows := []recording.Row{
+		{Time: at(1), Seq: 1, Raw: "Starting"},
+		{Time: at(2), Seq: 2, Raw: "P:1000, T1:20", Sample: sensor.Sample{Values: map[string]float64{"P": 1000, "T1": 20}}},
+		{Time: at(3), Event: recording.FormatEvent(recording.EventMark, "50 м")},
+		{Time: at(4), Seq: 3, Raw: "P:1001", Sample: sensor.Sample{Values: map[string]float64{"P": 1001}}},
+		{Time: at(6), Seq: 4, Raw: "P:1002, T1:21", Sample: sensor.Sample{Values: map[string]float64{"P": 1002, "T1": 21}}},
+	}
+	for _, r := range rows {
+		w.Write(r)
+	}
+	w.Flush()
+	f.Close()
+
+	end := at(5)
+	m := &recording.Manifest{Pauses: []recording.Interval{{Start: at(4), End: &end}}}
+	tests := []struct {
+		name string
+		opts Options
+		seq  []int64
+		t1   []float64
+	}{
+		{"без пауз", Options{}, []int64{2, 4}, []float64{20, 21}},
+		{"все строки", Options{All: true}, []int64{2, 3, 4}, []float64{20, math.NaN(), 21}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tab, err := Load(path, m, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tab.Seq, tt.seq) {
+				t.Errorf("seq = %v, ожидается %v", tab.Seq, tt.seq)
+			}
+			if got := tab.Values[1]; fmt.Sprint(got) != fmt.Sprint(tt.t1) { // NaN != NaN
+				t.Errorf("T1 = %v, ожидается %v", got, tt.t1)
+			}
+			if tab.Columns[0].Unit != "mbar" || tab.Flags != nil {
+				t.Errorf("столбцы %+v, флаги %v", tab.Columns, tab.Flags)
+			}
+			if len(tab.Marks) != 1 || tab.Marks[0].Text != "50 м" {
+				t.Errorf("отметки %+v", tab.Marks)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"math"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+)
+
+// Merge выравнивает таблицы нескольких источников по времени первой из
+// них: к каждой строке первой таблицы добавляются значения ближайшей по
+// времени приёма строки каждой другой таблицы, если та отстоит не более
+// чем на tolerance; иначе значения пропускаются. Столбцы получают имена
+// "<источник>.<поле>". Время, seq и excluded берутся из первой таблицы.
+// Флаги качества переносятся вместе со значениями; у источников без
+// флагов столбцы флагов пустые.
+func Merge(names []string, tables []*Table, tolerance time.Duration) *Table {
+	base := tables[0]
+	m := &Table{
+		Manifest:   base.Manifest,
+		Time:       base.Time,
+		SourceTime: base.SourceTime,
+		Seq:        base.Seq,
+		Excluded:   base.Excluded,
+		Marks:      base.Marks, // отметки пишутся во все файлы
+	}
+
+	var flagColumns [][]qc.Flag
+	withFlags := false
+	for k, t := range tables {
+		nearest := nearestRows(base.Time, t.Time, tolerance)
+		for j, c := range t.Columns {
+			c.Name = names[k] + "." + c.Name
+			values := make([]float64, base.Len())
+			var flags []qc.Flag
+			if t.Flags != nil {
+				flags = make([]qc.Flag, base.Len())
+			}
+			for i, row := range nearest {
+				if row < 0 {
+					values[i] = math.NaN()
+					continue
+				}
+				values[i] = t.Values[j][row]
+				if flags != nil {
+					flags[i] = t.Flags[j][row]
+				}
+			}
+			m.Columns = append(m.Columns, c)
+			m.Values = append(m.Values, values)
+			flagColumns = append(flagColumns, flags)
+			withFlags = withFlags || flags != nil
+		}
+	}
+	if withFlags {
+		m.Flags = flagColumns
+	}
+	return m
+}
+
+// nearestRows для каждого момента base находит индекс ближайшего момента
+// в times (оба упорядочены по возрастанию) или -1, если он дальше
+// tolerance.
+func nearestRows(base, times []time.Time, tolerance time.Duration) []int {
+	rows := make([]int, len(base))
+	j := 0
+	for i, t := range base {
+		for j+1 < len(times) && absDuration(times[j+1].Sub(t)) <= absDuration(times[j].Sub(t)) {
+			j++
+		}
+		rows[i] = -1
+		if j < len(times) && absDuration(times[j].Sub(t)) <= tolerance {
+			rows[i] = j
+		}
+	}
+	return rows
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
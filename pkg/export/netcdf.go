@@ -0,0 +1,326 @@
+package export
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+)
+
+// Запись NetCDF в классическом формате с 64-битными смещениями (CDF-2),
+// который читают netCDF4-python, xarray, ncdump, Panoply и MATLAB.
+// Все переменные — записи по неограниченному измерению time.
+
+// Типы NetCDF.
+const (
+	ncByte   = 1
+	ncChar   = 2
+	ncInt    = 4
+	ncDouble = 6
+)
+
+// Метки списков заголовка.
+const (
+	ncDimension = 0x0a
+	ncVariable  = 0x0b
+	ncAttribute = 0x0c
+)
+
+// ncFillDouble — значение по умолчанию для пропусков NC_DOUBLE.
+const ncFillDouble = 9.9692099683868690e+36
+
+// ncAttr — атрибут; Value — string, float64, int32 или []int8.
+type ncAttr struct {
+	Name  string
+	Value interface{}
+}
+
+// ncVar — переменная с измерением time.
+type ncVar struct {
+	Name  string
+	Type  int32
+	Attrs []ncAttr
+	// put дописывает значение i-й записи в кодировке NetCDF.
+	put func(buf []byte, i int) []byte
+}
+
+func (v *ncVar) size() int64 {
+	switch v.Type {
+	case ncDouble:
+		return 8
+	default:
+		return 4 // NC_INT; NC_BYTE дополняется до 4 байт
+	}
+}
+
+// WriteNetCDF пишет таблицу в NetCDF по соглашениям CF-1.8: координата
+// time в секундах от начала эксперимента, по переменной на поле с
+// единицами, описанием и стандартным именем из описания столбцов,
+// флаги качества <поле>_qc по QARTOD (нет флага — "не проверялось"),
+// флаг excluded для строк из пауз. Манифест сохраняется в глобальных
+// атрибутах.
+func WriteNetCDF(w io.Writer, t *Table) error {
+	epoch := tableEpoch(t)
+	since := "seconds since " + epoch.UTC().Format("2006-01-02T15:04:05Z")
+	seconds := func(tm time.Time) float64 { return float64(tm.Sub(epoch).Milliseconds()) / 1000 }
+	putDouble := func(buf []byte, v float64) []byte {
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+	}
+
+	vars := []*ncVar{{
+		Name: "time",
+		Type: ncDouble,
+		Attrs: []ncAttr{
+			{"standard_name", "time"},
+			{"long_name", "Время приёма строки оператором"},
+			{"units", since},
+			{"calendar", "standard"},
+			{"axis", "T"},
+		},
+		put: func(buf []byte, i int) []byte { return putDouble(buf, seconds(t.Time[i])) },
+	}, {
+		Name: "bridge_time",
+		Type: ncDouble,
+		Attrs: []ncAttr{
+			{"long_name", "Метка времени моста (секундная точность)"},
+			{"units", since},
+			{"calendar", "standard"},
+			{"_FillValue", ncFillDouble},
+		},
+		put: func(buf []byte, i int) []byte {
+			if t.SourceTime[i].IsZero() {
+				return putDouble(buf, ncFillDouble)
+			}
+			return putDouble(buf, seconds(t.SourceTime[i]))
+		},
+	}, {
+		Name:  "seq",
+		Type:  ncInt,
+		Attrs: []ncAttr{{"long_name", "Порядковый номер принятой строки"}},
+		put: func(buf []byte, i int) []byte {
+			return binary.BigEndian.AppendUint32(buf, uint32(int32(t.Seq[i])))
+		},
+	}}
+
+	for j, c := range t.Columns {
+		values := t.Values[j]
+		attrs := []ncAttr{{"long_name", c.Description}}
+		if c.StandardName != "" {
+			attrs = append(attrs, ncAttr{"standard_name", c.StandardName})
+		}
+		if c.StandardName == "depth" {
+			attrs = append(attrs, ncAttr{"positive", "down"})
+		}
+		if c.Unit != "" {
+			attrs = append(attrs, ncAttr{"units", c.Unit})
+		}
+		attrs = append(attrs, ncAttr{"_FillValue", ncFillDouble}, ncAttr{"coordinates", "time"})
+		if t.hasFlags(j) {
+			attrs = append(attrs, ncAttr{"ancillary_variables", c.Name + recording.QCSuffix})
+		}
+		vars = append(vars, &ncVar{
+			Name:  c.Name,
+			Type:  ncDouble,
+			Attrs: attrs,
+			put: func(buf []byte, i int) []byte {
+				if math.IsNaN(values[i]) {
+					return putDouble(buf, ncFillDouble)
+				}
+				return putDouble(buf, values[i])
+			},
+		})
+	}
+
+	for j, c := range t.Columns {
+		if !t.hasFlags(j) {
+			continue
+		}
+		flags := t.Flags[j]
+		vars = append(vars, &ncVar{
+			Name: c.Name + recording.QCSuffix,
+			Type: ncByte,
+			Attrs: []ncAttr{
+				{"long_name", "Флаг качества " + c.Name + " (QARTOD)"},
+				{"standard_name", "aggregate_quality_flag"},
+				{"flag_values", qcFlagValues()},
+				{"flag_meanings", qc.Meanings},
+			},
+			put: func(buf []byte, i int) []byte {
+				f := flags[i]
+				if f == 0 {
+					f = qc.NotEvaluated
+				}
+				return append(buf, byte(f), 0, 0, 0)
+			},
+		})
+	}
+
+	vars = append(vars, &ncVar{
+		Name: colExcluded,
+		Type: ncByte,
+		Attrs: []ncAttr{
+			{"long_name", "Строка принята во время паузы"},
+			{"flag_values", []int8{0, 1}},
+			{"flag_meanings", "included excluded_by_pause"},
+		},
+		put: func(buf []byte, i int) []byte {
+			var b byte
+			if t.Excluded[i] {
+				b = 1
+			}
+			return append(buf, b, 0, 0, 0)
+		},
+	})
+
+	// Заголовок пишется дважды: смещения переменных зависят от его
+	// длины, но сами имеют фиксированный размер.
+	attrs := globalAttrs(t)
+	header := ncHeader(t.Len(), attrs, vars, 0)
+	header = ncHeader(t.Len(), attrs, vars, int64(len(header)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	var buf []byte
+	for i := 0; i < t.Len(); i++ {
+		buf = buf[:0]
+		for _, v := range vars {
+			buf = v.put(buf, i)
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func qcFlagValues() []int8 {
+	values := make([]int8, len(qc.Flags))
+	for i, f := range qc.Flags {
+		values[i] = int8(f)
+	}
+	return values
+}
+
+// tableEpoch — начало отсчёта времени: начало эксперимента или первая
+// строка.
+func tableEpoch(t *Table) time.Time {
+	switch {
+	case t.Manifest != nil && !t.Manifest.Start.IsZero():
+		return t.Manifest.Start.Truncate(time.Second)
+	case t.Len() > 0:
+		return t.Time[0].Truncate(time.Second)
+	}
+	return time.Unix(0, 0)
+}
+
+func globalAttrs(t *Table) []ncAttr {
+	attrs := []ncAttr{
+		{"Conventions", "CF-1.8"},
+		{"history", time.Now().UTC().Format(time.RFC3339) + " выгружено goserialcomm export"},
+		{"schema_version", int32(recording.SchemaVersion)},
+	}
+	if t.Len() > 0 {
+		attrs = append(attrs,
+			ncAttr{"time_coverage_start", t.Time[0].UTC().Format(time.RFC3339Nano)},
+			ncAttr{"time_coverage_end", t.Time[t.Len()-1].UTC().Format(time.RFC3339Nano)})
+	}
+	if m := t.Manifest; m != nil {
+		attrs = append(attrs,
+			ncAttr{"title", m.Name},
+			ncAttr{"summary", m.Description},
+			ncAttr{"source", m.Address},
+			ncAttr{"operator", m.Operator},
+			ncAttr{"host", m.Host},
+			ncAttr{"date_created", m.Start.UTC().Format(time.RFC3339)})
+		if data, err := json.Marshal(m); err == nil {
+			attrs = append(attrs, ncAttr{"manifest", string(data)})
+		}
+	}
+	return attrs
+}
+
+// ncHeader кодирует заголовок; begin — смещение первой записи.
+func ncHeader(numrecs int, attrs []ncAttr, vars []*ncVar, begin int64) []byte {
+	b := []byte("CDF\x02")
+	b = binary.BigEndian.AppendUint32(b, uint32(numrecs))
+
+	// Единственное измерение — неограниченное time
+	b = binary.BigEndian.AppendUint32(b, ncDimension)
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = ncName(b, "time")
+	b = binary.BigEndian.AppendUint32(b, 0)
+
+	b = ncAttrList(b, attrs)
+
+	b = binary.BigEndian.AppendUint32(b, ncVariable)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(vars)))
+	for _, v := range vars {
+		b = ncName(b, v.Name)
+		b = binary.BigEndian.AppendUint32(b, 1) // одно измерение
+		b = binary.BigEndian.AppendUint32(b, 0) // time
+		b = ncAttrList(b, v.Attrs)
+		b = binary.BigEndian.AppendUint32(b, uint32(v.Type))
+		b = binary.BigEndian.AppendUint32(b, uint32(v.size()))
+		b = binary.BigEndian.AppendUint64(b, uint64(begin))
+		begin += v.size()
+	}
+	return b
+}
+
+// ncAttrList пишет список атрибутов; пустые строки пропускаются.
+func ncAttrList(b []byte, all []ncAttr) []byte {
+	var attrs []ncAttr
+	for _, a := range all {
+		if s, ok := a.Value.(string); !ok || s != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	if len(attrs) == 0 {
+		return append(b, make([]byte, 8)...)
+	}
+	b = binary.BigEndian.AppendUint32(b, ncAttribute)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(attrs)))
+	for _, a := range attrs {
+		b = ncName(b, a.Name)
+		switch v := a.Value.(type) {
+		case string:
+			b = binary.BigEndian.AppendUint32(b, ncChar)
+			b = ncName(b, v)
+		case float64:
+			b = binary.BigEndian.AppendUint32(b, ncDouble)
+			b = binary.BigEndian.AppendUint32(b, 1)
+			b = binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+		case int32:
+			b = binary.BigEndian.AppendUint32(b, ncInt)
+			b = binary.BigEndian.AppendUint32(b, 1)
+			b = binary.BigEndian.AppendUint32(b, uint32(v))
+		case []int8:
+			b = binary.BigEndian.AppendUint32(b, ncByte)
+			b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+			for _, x := range v {
+				b = append(b, byte(x))
+			}
+			b = ncPad(b, len(v))
+		}
+	}
+	return b
+}
+
+// ncName пишет строку: длина, байты и выравнивание до 4 байт.
+func ncName(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	b = append(b, s...)
+	return ncPad(b, len(s))
+}
+
+func ncPad(b []byte, n int) []byte {
+	for ; n%4 != 0; n++ {
+		b = append(b, 0)
+	}
+	return b
+}
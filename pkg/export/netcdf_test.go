@@ -0,0 +1,192 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNCHeader(t *testing.T) {
+	v := &ncVar{Name: "seq", Type: ncInt, Attrs: []ncAttr{{"units", "1"}}}
+	got := ncHeader(2, []ncAttr{{"title", ""}}, []*ncVar{v}, 0x60)
+	want := []byte{
+		'C', 'D', 'F', 2, 0, 0, 0, 2, // магия, numrecs
+		0, 0, 0, ncDimension, 0, 0, 0, 1, 0, 0, 0, 4, 't', 'i', 'm', 'e', 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, // пустой атрибут пропущен: ABSENT
+		0, 0, 0, ncVariable, 0, 0, 0, 1,
+		0, 0, 0, 3, 's', 'e', 'q', 0, 0, 0, 0, 1, 0, 0, 0, 0,
+		0, 0, 0, ncAttribute, 0, 0, 0, 1,
+		0, 0, 0, 5, 'u', 'n', 'i', 't', 's', 0, 0, 0, 0, 0, 0, ncChar, 0, 0, 0, 1, '1', 0, 0, 0,
+		0, 0, 0, ncInt, 0, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0x60,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("заголовок\n% x\nожидается\n% x", got, want)
+	}
+}
+
+// TestWriteNetCDF разбирает файл CDF-2 независимо от писателя и
+// сверяет переменные, атрибуты и записи.
+func TestWriteNetCDF(t *testing.T) {
+	tab := testTable()
+	var buf bytes.Buffer
+	if err := WriteNetCDF(&buf, tab); err != nil {
+		t.Fatal(err)
+	}
+	f, err := parseCDF(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.numrecs != 3 || f.attrs["Conventions"] != "CF-1.8" || f.attrs["title"] != "Погружение" {
+		t.Errorf("numrecs %d, атрибуты %v", f.numrecs, f.attrs)
+	}
+	var names []string
+	for _, v := range f.vars {
+		names = append(names, v.name)
+	}
+	if want := []string{"time", "bridge_time", "seq", "P", "T2", "P_qc", "excluded"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("переменные %v, ожидается %v", names, want)
+	}
+	if first := f.vars[0].begin; first != int64(f.headerLen) {
+		t.Errorf("первая запись со смещения %d, заголовок %d байт", first, f.headerLen)
+	}
+	if len(buf.Bytes()) != f.headerLen+3*f.recsize {
+		t.Errorf("размер файла %d, ожидается %d", len(buf.Bytes()), f.headerLen+3*f.recsize)
+	}
+
+	fill := fmt.Sprint(ncFillDouble)
+	tests := []struct {
+		name   string
+		attrs  map[string]interface{}
+		values string
+	}{
+		{"time", map[string]interface{}{"units": "seconds since 2025-08-29T03:06:48Z", "axis": "T"}, "[1.25 2.25 3.5]"},
+		{"bridge_time", map[string]interface{}{"_FillValue": ncFillDouble}, "[" + fill + " 2 3]"},
+		{"seq", nil, "[1 2 4]"},
+		{"P", map[string]interface{}{"units": "mbar", "standard_name": "sea_water_pressure", "ancillary_variables": "P_qc"},
+			"[1013.25 1020.5 1100]"},
+		{"T2", map[string]interface{}{"units": "degC", "long_name": "Температура", "ancillary_variables": nil},
+			"[20.05 " + fill + " 19.5]"},
+		{"P_qc", map[string]interface{}{"flag_values": []int8{1, 2, 3, 4, 9}, "flag_meanings": "pass not_evaluated suspect fail missing"},
+			"[1 3 2]"},
+		{"excluded", map[string]interface{}{"flag_values": []int8{0, 1}}, "[0 0 1]"},
+	}
+	for i, tt := range tests {
+		v := f.vars[i]
+		for name, want := range tt.attrs {
+			if got := v.attrs[name]; !reflect.DeepEqual(got, want) {
+				t.Errorf("%s:%s = %#v, ожидается %#v", v.name, name, got, want)
+			}
+		}
+		if got := fmt.Sprint(v.values); got != tt.values {
+			t.Errorf("%s = %s, ожидается %s", v.name, got, tt.values)
+		}
+	}
+}
+
+type cdfVar struct {
+	name   string
+	typ    uint32
+	attrs  map[string]interface{}
+	begin  int64
+	values []interface{}
+}
+
+type cdfFile struct {
+	numrecs   int
+	headerLen int
+	recsize   int
+	attrs     map[string]interface{}
+	vars      []*cdfVar
+}
+
+// parseCDF разбирает файл NetCDF CDF-2 с одним неограниченным
+// измерением, в котором все переменные — записи.
+func parseCDF(b []byte) (*cdfFile, error) {
+	if string(b[:4]) != "CDF\x02" {
+		return nil, fmt.Errorf("нет магических байтов CDF-2: % x", b[:4])
+	}
+	pos := 4
+	u32 := func() uint32 {
+		v := binary.BigEndian.Uint32(b[pos:])
+		pos += 4
+		return v
+	}
+	name := func() string {
+		n := int(u32())
+		s := string(b[pos : pos+n])
+		pos += (n + 3) / 4 * 4
+		return s
+	}
+	attrs := func() map[string]interface{} {
+		tag, n := u32(), int(u32())
+		m := map[string]interface{}{}
+		if tag == 0 {
+			return m
+		}
+		for i := 0; i < n; i++ {
+			key := name()
+			typ, count := u32(), int(u32())
+			switch typ {
+			case ncChar:
+				pos -= 4
+				m[key] = name()
+			case ncByte:
+				v := make([]int8, count)
+				for j := range v {
+					v[j] = int8(b[pos+j])
+				}
+				pos += (count + 3) / 4 * 4
+				m[key] = v
+			case ncInt:
+				m[key] = int32(u32())
+			case ncDouble:
+				m[key] = math.Float64frombits(binary.BigEndian.Uint64(b[pos:]))
+				pos += 8 * count
+			}
+		}
+		return m
+	}
+
+	f := &cdfFile{numrecs: int(u32())}
+	if tag, n := u32(), u32(); tag != ncDimension || n != 1 {
+		return nil, fmt.Errorf("измерения: метка %x, число %d", tag, n)
+	}
+	if dim, size := name(), u32(); dim != "time" || size != 0 {
+		return nil, fmt.Errorf("измерение %s размером %d", dim, size)
+	}
+	f.attrs = attrs()
+	if tag := u32(); tag != ncVariable {
+		return nil, fmt.Errorf("переменные: метка %x", tag)
+	}
+	for n := int(u32()); n > 0; n-- {
+		v := &cdfVar{name: name()}
+		if ndims, dim := u32(), u32(); ndims != 1 || dim != 0 {
+			return nil, fmt.Errorf("%s: измерения %d, %d", v.name, ndims, dim)
+		}
+		v.attrs = attrs()
+		v.typ = u32()
+		f.recsize += int(u32())
+		v.begin = int64(binary.BigEndian.Uint64(b[pos:]))
+		pos += 8
+		f.vars = append(f.vars, v)
+	}
+	f.headerLen = pos
+
+	for i := 0; i < f.numrecs; i++ {
+		for _, v := range f.vars {
+			p := int(v.begin) + i*f.recsize
+			switch v.typ {
+			case ncDouble:
+				v.values = append(v.values, math.Float64frombits(binary.BigEndian.Uint64(b[p:])))
+			case ncInt:
+				v.values = append(v.values, int32(binary.BigEndian.Uint32(b[p:])))
+			case ncByte:
+				v.values = append(v.values, int8(b[p]))
+			}
+		}
+	}
+	return f, nil
+}
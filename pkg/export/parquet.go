@@ -0,0 +1,360 @@
+package export
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+)
+
+// Минимальный писатель Parquet: одна группа строк, по одной странице
+// данных (DATA_PAGE v1) на столбец, кодирование PLAIN, без сжатия.
+// Этого достаточно для pandas/pyarrow, Spark и DuckDB и не требует
+// зависимостей.
+
+// Значения перечислений parquet.thrift.
+const (
+	pqBoolean = 0
+	pqInt64   = 2
+	pqDouble  = 5
+
+	pqRequired = 0
+	pqOptional = 1
+
+	pqPlain = 0
+	pqRLE   = 3
+
+	pqUncompressed = 0
+	pqDataPage     = 0
+
+	pqTimestampMillis = 9 // ConvertedType
+)
+
+// pqColumn — столбец Parquet: описание и закодированные значения.
+type pqColumn struct {
+	name      string
+	typ       int32
+	optional  bool
+	timestamp bool
+	values    []byte // значения в кодировке PLAIN, без пропусков
+	defined   []bool // для optional: есть ли значение в строке
+	count     int    // число значений в странице, включая пропуски
+	offset    int64  // смещение страницы в файле
+	size      int64  // заголовок страницы и данные
+}
+
+// WriteParquet пишет таблицу в Parquet с типизированными столбцами:
+// timestamp и source_ts — INT64 TIMESTAMP_MILLIS (UTC), seq — INT64,
+// поля — DOUBLE (пропуски как null), флаги качества <поле>_qc — INT64
+// (null — флага нет), excluded — BOOLEAN. Манифест и
+// описания столбцов сохраняются в метаданных файла (ключи "manifest" и
+// "columns").
+func WriteParquet(w io.Writer, t *Table) error {
+	n := t.Len()
+	cols := []*pqColumn{
+		{name: recording.ColTimestamp, typ: pqInt64, timestamp: true},
+		{name: recording.ColSourceTS, typ: pqInt64, timestamp: true, optional: true},
+		{name: recording.ColSeq, typ: pqInt64},
+	}
+	for i := range t.Time {
+		cols[0].values = binary.LittleEndian.AppendUint64(cols[0].values, uint64(t.Time[i].UnixMilli()))
+		st := t.SourceTime[i]
+		cols[1].defined = append(cols[1].defined, !st.IsZero())
+		if !st.IsZero() {
+			cols[1].values = binary.LittleEndian.AppendUint64(cols[1].values, uint64(st.UnixMilli()))
+		}
+		cols[2].values = binary.LittleEndian.AppendUint64(cols[2].values, uint64(t.Seq[i]))
+	}
+	for j, c := range t.Columns {
+		col := &pqColumn{name: c.Name, typ: pqDouble, optional: true}
+		for _, v := range t.Values[j] {
+			col.defined = append(col.defined, !math.IsNaN(v))
+			if !math.IsNaN(v) {
+				col.values = binary.LittleEndian.AppendUint64(col.values, math.Float64bits(v))
+			}
+		}
+		cols = append(cols, col)
+	}
+	for j, c := range t.Columns {
+		if !t.hasFlags(j) {
+			continue
+		}
+		col := &pqColumn{name: c.Name + recording.QCSuffix, typ: pqInt64, optional: true}
+		for _, f := range t.Flags[j] {
+			col.defined = append(col.defined, f != 0)
+			if f != 0 {
+				col.values = binary.LittleEndian.AppendUint64(col.values, uint64(f))
+			}
+		}
+		cols = append(cols, col)
+	}
+	excluded := &pqColumn{name: colExcluded, typ: pqBoolean, values: make([]byte, (n+7)/8)}
+	for i, e := range t.Excluded {
+		if e {
+			excluded.values[i/8] |= 1 << (i % 8)
+		}
+	}
+	cols = append(cols, excluded)
+
+	cw := &countingWriter{w: w}
+	if _, err := cw.Write([]byte("PAR1")); err != nil {
+		return err
+	}
+	for _, col := range cols {
+		col.count = n
+		if err := col.writePage(cw); err != nil {
+			return err
+		}
+	}
+
+	var kv [][2]string
+	if t.Manifest != nil {
+		data, err := json.Marshal(t.Manifest)
+		if err != nil {
+			return err
+		}
+		kv = append(kv, [2]string{"manifest", string(data)})
+	}
+	data, err := json.Marshal(t.Columns)
+	if err != nil {
+		return err
+	}
+	kv = append(kv, [2]string{"columns", string(data)})
+
+	footer := fileMetaData(cols, int64(n), kv)
+	if _, err := cw.Write(footer); err != nil {
+		return err
+	}
+	tail := binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))
+	_, err = cw.Write(append(tail, "PAR1"...))
+	return err
+}
+
+// writePage пишет единственную страницу данных столбца.
+func (col *pqColumn) writePage(cw *countingWriter) error {
+	var page []byte
+	if col.optional {
+		levels := rleBits(col.defined)
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+		page = append(page, levels...)
+	}
+	page = append(page, col.values...)
+
+	var h compact
+	h.begin()
+	h.i32(1, pqDataPage)
+	h.i32(2, int32(len(page)))
+	h.i32(3, int32(len(page)))
+	h.structBegin(5)
+	h.i32(1, int32(col.count))
+	h.i32(2, pqPlain)
+	h.i32(3, pqRLE)
+	h.i32(4, pqRLE)
+	h.end()
+	h.end()
+
+	col.offset = cw.n
+	col.size = int64(len(h.buf) + len(page))
+	if _, err := cw.Write(h.buf); err != nil {
+		return err
+	}
+	_, err := cw.Write(page)
+	return err
+}
+
+// rleBits кодирует уровни определения (0 или 1) гибридным RLE с
+// шириной 1 бит: только серии одинаковых значений.
+func rleBits(bits []bool) []byte {
+	var out []byte
+	for i := 0; i < len(bits); {
+		j := i
+		for j < len(bits) && bits[j] == bits[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if bits[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// fileMetaData кодирует FileMetaData.
+func fileMetaData(cols []*pqColumn, rows int64, kv [][2]string) []byte {
+	var m compact
+	m.begin()
+	m.i32(1, 1) // version
+
+	m.listBegin(2, ctStruct, len(cols)+1)
+	m.begin()
+	m.binary(4, "schema")
+	m.i32(5, int32(len(cols)))
+	m.end()
+	for _, col := range cols {
+		m.begin()
+		m.i32(1, col.typ)
+		if col.optional {
+			m.i32(3, pqOptional)
+		} else {
+			m.i32(3, pqRequired)
+		}
+		m.binary(4, col.name)
+		if col.timestamp {
+			m.i32(6, pqTimestampMillis)
+			m.structBegin(10) // LogicalType
+			m.structBegin(8)  // TIMESTAMP
+			m.boolean(1, true)
+			m.structBegin(2) // TimeUnit
+			m.structBegin(1) // MILLIS
+			m.end()
+			m.end()
+			m.end()
+			m.end()
+		}
+		m.end()
+	}
+
+	m.i64(3, rows)
+
+	m.listBegin(4, ctStruct, 1)
+	m.begin()
+	var total int64
+	m.listBegin(1, ctStruct, len(cols))
+	for _, col := range cols {
+		total += col.size
+		m.begin()
+		m.i64(2, col.offset)
+		m.structBegin(3)
+		m.i32(1, col.typ)
+		m.listBegin(2, ctI32, 2)
+		m.varint(zigzag(pqPlain))
+		m.varint(zigzag(pqRLE))
+		m.listBegin(3, ctBinary, 1)
+		m.bytes(col.name)
+		m.i32(4, pqUncompressed)
+		m.i64(5, int64(col.count))
+		m.i64(6, col.size)
+		m.i64(7, col.size)
+		m.i64(9, col.offset)
+		m.end()
+		m.end()
+	}
+	m.i64(2, total)
+	m.i64(3, rows)
+	m.end()
+
+	m.listBegin(5, ctStruct, len(kv))
+	for _, p := range kv {
+		m.begin()
+		m.binary(1, p[0])
+		m.binary(2, p[1])
+		m.end()
+	}
+	m.binary(6, "goserialcomm export")
+	m.end()
+	return m.buf
+}
+
+// Типы компактного протокола Thrift.
+const (
+	ctTrue   = 1
+	ctFalse  = 2
+	ctI32    = 5
+	ctI64    = 6
+	ctBinary = 8
+	ctList   = 9
+	ctStruct = 12
+)
+
+// compact — кодировщик компактного протокола Thrift, ровно в том объёме,
+// который нужен для метаданных Parquet.
+type compact struct {
+	buf  []byte
+	last []int16 // номер последнего поля во вложенных структурах
+}
+
+// begin начинает структуру (в том числе элемент списка).
+func (c *compact) begin() { c.last = append(c.last, 0) }
+
+// end заканчивает структуру.
+func (c *compact) end() {
+	c.buf = append(c.buf, 0)
+	c.last = c.last[:len(c.last)-1]
+}
+
+func (c *compact) field(id int16, typ byte) {
+	last := &c.last[len(c.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		c.buf = append(c.buf, byte(d)<<4|typ)
+	} else {
+		c.buf = append(c.buf, typ)
+		c.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (c *compact) i32(id int16, v int32) {
+	c.field(id, ctI32)
+	c.varint(zigzag(int64(v)))
+}
+
+func (c *compact) i64(id int16, v int64) {
+	c.field(id, ctI64)
+	c.varint(zigzag(v))
+}
+
+func (c *compact) boolean(id int16, v bool) {
+	if v {
+		c.field(id, ctTrue)
+	} else {
+		c.field(id, ctFalse)
+	}
+}
+
+func (c *compact) binary(id int16, s string) {
+	c.field(id, ctBinary)
+	c.bytes(s)
+}
+
+// structBegin начинает вложенную структуру в поле id; закрывается end.
+func (c *compact) structBegin(id int16) {
+	c.field(id, ctStruct)
+	c.begin()
+}
+
+// listBegin пишет заголовок списка; элементы пишутся следом.
+func (c *compact) listBegin(id int16, elem byte, n int) {
+	c.field(id, ctList)
+	if n < 15 {
+		c.buf = append(c.buf, byte(n)<<4|elem)
+	} else {
+		c.buf = append(c.buf, 0xf0|elem)
+		c.varint(uint64(n))
+	}
+}
+
+func (c *compact) bytes(s string) {
+	c.varint(uint64(len(s)))
+	c.buf = append(c.buf, s...)
+}
+
+func (c *compact) varint(v uint64) { c.buf = binary.AppendUvarint(c.buf, v) }
+
+func zigzag(v int64) uint64 { return uint64(v<<1) ^ uint64(v>>63) }
+
+// countingWriter считает записанные байты для смещений в метаданных.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
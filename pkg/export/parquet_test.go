@@ -0,0 +1,292 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(c *compact)
+		want  []byte
+	}{
+		{"короткий шаг номера поля", func(c *compact) {
+			c.begin()
+			c.i32(1, 2)
+			c.i64(3, -1)
+			c.end()
+		}, []byte{0x15, 0x04, 0x26, 0x01, 0x00}},
+		{"длинный шаг номера поля", func(c *compact) {
+			c.begin()
+			c.boolean(20, true)
+			c.end()
+		}, []byte{0x01, 0x28, 0x00}},
+		{"строка и вложенная структура", func(c *compact) {
+			c.begin()
+			c.binary(4, "ab")
+			c.structBegin(5)
+			c.boolean(1, false)
+			c.end()
+			c.end()
+		}, []byte{0x48, 0x02, 'a', 'b', 0x1c, 0x12, 0x00, 0x00}},
+		{"короткий список", func(c *compact) {
+			c.begin()
+			c.listBegin(2, ctI32, 2)
+			c.varint(zigzag(0))
+			c.varint(zigzag(3))
+			c.end()
+		}, []byte{0x29, 0x25, 0x00, 0x06, 0x00}},
+		{"длинный список", func(c *compact) {
+			c.begin()
+			c.listBegin(1, ctStruct, 20)
+		}, []byte{0x19, 0xfc, 0x14}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c compact
+			tt.write(&c)
+			if !bytes.Equal(c.buf, tt.want) {
+				t.Errorf("% x, ожидается % x", c.buf, tt.want)
+			}
+		})
+	}
+}
+
+func TestRLEBits(t *testing.T) {
+	tests := []struct {
+		bits []bool
+		want []byte
+	}{
+		{[]bool{true, true, true}, []byte{0x06, 1}},
+		{[]bool{false, true, true, false}, []byte{0x02, 0, 0x04, 1, 0x02, 0}},
+		{make([]bool, 100), []byte{0xc8, 0x01, 0}},
+	}
+	for _, tt := range tests {
+		if got := rleBits(tt.bits); !bytes.Equal(got, tt.want) {
+			t.Errorf("rleBits(%v) = % x, ожидается % x", tt.bits, got, tt.want)
+		}
+	}
+}
+
+// TestWriteParquet разбирает файл независимо от писателя: хвост,
+// FileMetaData, заголовки страниц по смещениям из метаданных и
+// значения в страницах.
+func TestWriteParquet(t *testing.T) {
+	tab := testTable()
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, tab); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if string(b[:4]) != "PAR1" || string(b[len(b)-4:]) != "PAR1" {
+		t.Fatalf("нет магических байтов PAR1")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	footer := b[len(b)-8-footerLen : len(b)-8]
+	r := &thriftReader{b: footer}
+	meta := r.structure()
+	if r.pos != len(footer) {
+		t.Fatalf("FileMetaData: разобрано %d байт из %d", r.pos, len(footer))
+	}
+	if meta[1] != int64(1) || meta[3] != int64(3) {
+		t.Errorf("version %v, num_rows %v", meta[1], meta[3])
+	}
+
+	type schemaElem struct {
+		name       string
+		typ, rep   int64
+		converted  int64
+		timeMillis bool
+	}
+	want := []schemaElem{
+		{name: "timestamp", typ: pqInt64, rep: pqRequired, converted: pqTimestampMillis, timeMillis: true},
+		{name: "source_ts", typ: pqInt64, rep: pqOptional, converted: pqTimestampMillis, timeMillis: true},
+		{name: "seq", typ: pqInt64, rep: pqRequired},
+		{name: "P", typ: pqDouble, rep: pqOptional},
+		{name: "T2", typ: pqDouble, rep: pqOptional},
+		{name: "P_qc", typ: pqInt64, rep: pqOptional},
+		{name: "excluded", typ: pqBoolean, rep: pqRequired},
+	}
+	schema := meta[2].([]interface{})
+	if root := schema[0].(fields); root[4] != "schema" || root[5] != int64(len(want)) {
+		t.Errorf("корень схемы %v", root)
+	}
+	var got []schemaElem
+	for _, e := range schema[1:] {
+		f := e.(fields)
+		el := schemaElem{name: f[4].(string), typ: f[1].(int64), rep: f[3].(int64)}
+		if c, ok := f[6]; ok {
+			el.converted = c.(int64)
+			ts := f[10].(fields)[8].(fields)
+			unit := ts[2].(fields)
+			_, el.timeMillis = unit[1]
+			if ts[1] != true {
+				t.Errorf("%s: isAdjustedToUTC = %v", el.name, ts[1])
+			}
+		}
+		got = append(got, el)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("схема\n%+v\nожидается\n%+v", got, want)
+	}
+
+	kv := map[string]string{}
+	for _, e := range meta[5].([]interface{}) {
+		kv[e.(fields)[1].(string)] = e.(fields)[2].(string)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(kv["manifest"]), &m); err != nil || m["name"] != "Погружение" {
+		t.Errorf("манифест в метаданных: %v, %v", m, err)
+	}
+
+	rowGroup := meta[4].([]interface{})[0].(fields)
+	chunks := rowGroup[1].([]interface{})
+	if len(chunks) != len(want) || rowGroup[3] != int64(3) {
+		t.Fatalf("столбцов %d, строк %v", len(chunks), rowGroup[3])
+	}
+	ms := func(i int) string { return fmt.Sprint(tab.Time[0].UnixMilli() + int64(i)) }
+	wantValues := []string{
+		fmt.Sprint([]string{ms(0), ms(1000), ms(2250)}),
+		fmt.Sprint([]string{"null", fmt.Sprint(tab.SourceTime[1].UnixMilli()), fmt.Sprint(tab.SourceTime[2].UnixMilli())}),
+		"[1 2 4]",
+		"[1013.25 1020.5 1100]",
+		"[20.05 null 19.5]",
+		"[1 3 null]",
+		"[false false true]",
+	}
+	for i, c := range chunks {
+		cm := c.(fields)[3].(fields)
+		offset := cm[9].(int64)
+		if c.(fields)[2] != offset || cm[5] != int64(3) {
+			t.Errorf("%s: file_offset %v, data_page_offset %v, num_values %v", want[i].name, c.(fields)[2], offset, cm[5])
+		}
+		pr := &thriftReader{b: b[offset:]}
+		page := pr.structure()
+		size := int(page[3].(int64))
+		if page[1] != int64(pqDataPage) || page[2] != page[3] || cm[6] != int64(pr.pos+size) {
+			t.Errorf("%s: заголовок страницы %v, размер %v", want[i].name, page, cm[6])
+		}
+		if dp := page[5].(fields); dp[1] != int64(3) || dp[2] != int64(pqPlain) {
+			t.Errorf("%s: DataPageHeader %v", want[i].name, dp)
+		}
+		data := b[int(offset)+pr.pos : int(offset)+pr.pos+size]
+		if got := decodePage(data, want[i].typ, want[i].rep == pqOptional, 3); got != wantValues[i] {
+			t.Errorf("%s: значения %s, ожидается %s", want[i].name, got, wantValues[i])
+		}
+	}
+}
+
+// decodePage декодирует страницу PLAIN с уровнями определения RLE.
+func decodePage(data []byte, typ int64, optional bool, n int) string {
+	defined := make([]bool, 0, n)
+	if optional {
+		size := int(binary.LittleEndian.Uint32(data))
+		levels := data[4 : 4+size]
+		for len(levels) > 0 {
+			h, k := binary.Uvarint(levels)
+			for j := 0; j < int(h>>1); j++ {
+				defined = append(defined, levels[k] == 1)
+			}
+			levels = levels[k+1:]
+		}
+		data = data[4+size:]
+	} else {
+		for i := 0; i < n; i++ {
+			defined = append(defined, true)
+		}
+	}
+	var out []string
+	for i, ok := range defined {
+		switch {
+		case !ok:
+			out = append(out, "null")
+		case typ == pqBoolean:
+			out = append(out, fmt.Sprint(data[i/8]&(1<<(i%8)) != 0))
+		case typ == pqDouble:
+			out = append(out, fmt.Sprint(math.Float64frombits(binary.LittleEndian.Uint64(data))))
+			data = data[8:]
+		default:
+			out = append(out, fmt.Sprint(int64(binary.LittleEndian.Uint64(data))))
+			data = data[8:]
+		}
+	}
+	return fmt.Sprint(out)
+}
+
+// fields — разобранная структура Thrift по номерам полей.
+type fields map[int16]interface{}
+
+// thriftReader разбирает компактный протокол Thrift независимо от compact.
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	u := r.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (r *thriftReader) structure() fields {
+	f := fields{}
+	var last int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return f
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.varint())
+		}
+		last = id
+		f[id] = r.value(h & 0x0f)
+	}
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case ctTrue:
+		return true
+	case ctFalse:
+		return false
+	case ctI32, ctI64:
+		return r.varint()
+	case ctBinary:
+		n := int(r.uvarint())
+		r.pos += n
+		return string(r.b[r.pos-n : r.pos])
+	case ctList:
+		h := r.byte()
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = r.value(h & 0x0f)
+		}
+		return list
+	case ctStruct:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("тип Thrift %d", typ))
+}
@@ -0,0 +1,147 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+)
+
+// WriteCSV пишет таблицу в CSV: время приёма, время моста и значения
+// полей, без строк-событий и исходных строк. Столбцы флагов качества
+// <поле>_qc идут после полей. Столбец excluded добавляется, только если
+// в таблице есть строки из пауз.
+func WriteCSV(w io.Writer, t *Table) error {
+	withExcluded := t.hasExcluded()
+	out := csv.NewWriter(w)
+	header := []string{recording.ColTimestamp, recording.ColSourceTS}
+	for _, c := range t.Columns {
+		header = append(header, c.Name)
+	}
+	for j, c := range t.Columns {
+		if t.hasFlags(j) {
+			header = append(header, c.Name+recording.QCSuffix)
+		}
+	}
+	if withExcluded {
+		header = append(header, colExcluded)
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	rec := make([]string, 0, len(header))
+	for i := range t.Time {
+		rec = append(rec[:0], t.Time[i].Format(recording.TimeLayout), formatTime(t, i))
+		for _, values := range t.Values {
+			if v := values[i]; !math.IsNaN(v) {
+				rec = append(rec, strconv.FormatFloat(v, 'f', -1, 64))
+			} else {
+				rec = append(rec, "")
+			}
+		}
+		for j := range t.Columns {
+			if !t.hasFlags(j) {
+				continue
+			}
+			if f := t.Flags[j][i]; f != 0 {
+				rec = append(rec, strconv.Itoa(int(f)))
+			} else {
+				rec = append(rec, "")
+			}
+		}
+		if withExcluded {
+			rec = append(rec, strconv.FormatBool(t.Excluded[i]))
+		}
+		if err := out.Write(rec); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteJSONL пишет таблицу в JSON Lines: один объект на строку данных
+// с полями в порядке столбцов, отсутствующие значения пропускаются.
+// Флаги качества пишутся ключами <поле>_qc.
+//
+//	{"timestamp":"2025-08-29T03:06:48.120+03:00","source_ts":"...","seq":7,"P":1013.25,...,"P_qc":1}
+func WriteJSONL(w io.Writer, t *Table) error {
+	var buf []byte
+	for i := range t.Time {
+		obj := jsonObject{buf: buf[:0]}
+		obj.add(recording.ColTimestamp, t.Time[i].Format(recording.TimeLayout))
+		if ts := formatTime(t, i); ts != "" {
+			obj.add(recording.ColSourceTS, ts)
+		}
+		obj.add(recording.ColSeq, t.Seq[i])
+		for j, c := range t.Columns {
+			if v := t.Values[j][i]; !math.IsNaN(v) {
+				obj.add(c.Name, v)
+			}
+		}
+		for j, c := range t.Columns {
+			if t.hasFlags(j) && t.Flags[j][i] != 0 {
+				obj.add(c.Name+recording.QCSuffix, int(t.Flags[j][i]))
+			}
+		}
+		if t.Excluded[i] {
+			obj.add(colExcluded, true)
+		}
+		if obj.err != nil {
+			return obj.err
+		}
+		buf = append(obj.buf, '}', '\n')
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonObject собирает JSON-объект с ключами в порядке добавления.
+type jsonObject struct {
+	buf []byte
+	err error
+}
+
+func (o *jsonObject) add(key string, value interface{}) {
+	if len(o.buf) == 0 {
+		o.buf = append(o.buf, '{')
+	} else {
+		o.buf = append(o.buf, ',')
+	}
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil && o.err == nil {
+		o.err = err
+	}
+	o.buf = append(append(append(o.buf, k...), ':'), v...)
+}
+
+// colExcluded — столбец с признаком строки, принятой во время паузы.
+const colExcluded = "excluded"
+
+func (t *Table) hasExcluded() bool {
+	for _, e := range t.Excluded {
+		if e {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFlags сообщает, есть ли у столбца j флаги качества.
+func (t *Table) hasFlags(j int) bool {
+	return t.Flags != nil && t.Flags[j] != nil
+}
+
+func formatTime(t *Table, i int) string {
+	if t.SourceTime[i].IsZero() {
+		return ""
+	}
+	return t.SourceTime[i].Format(recording.TimeLayout)
+}
@@ -0,0 +1,476 @@
+// Пакет plot рисует графики записей экспериментов в SVG и PNG без
+// внешних зависимостей: ряды по времени на одной или двух осях Y,
+// профили по глубине (ось Y направлена вниз), отметки оператора и
+// закрашенные интервалы (паузы, перерывы связи).
+//
+// График собирается из таблицы export.Table:
+//
+//	fig, err := plot.TimeSeries(t, []string{"Depth"}, []string{"T2"})
+//	fig.AddEvents(t)
+//	err = fig.WriteSVG(w)
+//
+// PNG рисуется встроенным растровым шрифтом 5×7 только с латиницей,
+// кириллица в подписях транслитерируется; для отчётов с русскими
+// подписями лучше SVG.
+package plot
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Размер графика по умолчанию, в точках (в PNG — PNGScale пикселей на
+// точку).
+const (
+	DefaultWidth  = 900
+	DefaultHeight = 500
+)
+
+// Figure — график.
+type Figure struct {
+	Title         string
+	Width, Height float64 // в точках; ноль — размер по умолчанию
+
+	X Axis
+	Y Axis
+	// Y2 — правая ось Y для рядов с Right.
+	Y2 Axis
+
+	Series []Series
+	Marks  []Mark
+	Spans  []Span
+}
+
+// Axis — ось графика. Пределы берутся по данным, если Min и Max равны.
+type Axis struct {
+	Label string
+	// Time — значения оси — Unix-время в секундах; подписи — время
+	// суток.
+	Time bool
+	// Invert направляет ось в обратную сторону (глубина вниз).
+	Invert   bool
+	Min, Max float64
+}
+
+// Series — ряд точек. NaN в X или Y разрывает линию.
+type Series struct {
+	Name  string
+	X, Y  []float64
+	Right bool // по правой оси Y
+}
+
+// Mark — вертикальная отметка с подписью, например отметка оператора.
+type Mark struct {
+	X    float64
+	Text string
+}
+
+// Span — закрашенный интервал по X, например пауза.
+type Span struct {
+	From, To float64
+	Text     string
+	Color    color.RGBA
+}
+
+// Цвета интервалов.
+var (
+	PauseColor = color.RGBA{0xbb, 0xbb, 0xbb, 0x50}
+	GapColor   = color.RGBA{0xe0, 0x40, 0x40, 0x40}
+)
+
+// palette — цвета рядов (Tableau 10).
+var palette = []color.RGBA{
+	{0x1f, 0x77, 0xb4, 0xff}, {0xff, 0x7f, 0x0e, 0xff}, {0x2c, 0xa0, 0x2c, 0xff},
+	{0xd6, 0x27, 0x28, 0xff}, {0x94, 0x67, 0xbd, 0xff}, {0x8c, 0x56, 0x4b, 0xff},
+	{0xe3, 0x77, 0xc2, 0xff}, {0x7f, 0x7f, 0x7f, 0xff}, {0xbc, 0xbd, 0x22, 0xff},
+	{0x17, 0xbe, 0xcf, 0xff},
+}
+
+var (
+	black = color.RGBA{0, 0, 0, 0xff}
+	grey  = color.RGBA{0x80, 0x80, 0x80, 0xff}
+	grid  = color.RGBA{0xe4, 0xe4, 0xe4, 0xff}
+	white = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// Выравнивание текста относительно точки.
+const (
+	alignLeft = iota
+	alignCenter
+	alignRight
+)
+
+// canvas — то, на чём рисуется график: SVG или растр. Координаты в
+// точках, y вниз.
+type canvas interface {
+	rect(x, y, w, h float64, c color.RGBA)
+	line(x1, y1, x2, y2 float64, c color.RGBA, width float64, dashed bool)
+	polyline(xs, ys []float64, c color.RGBA, width float64)
+	// text пишет строку с базовой линией в y; vertical — снизу вверх.
+	text(x, y float64, s string, align int, c color.RGBA, vertical bool)
+}
+
+// Отступы области построения, в точках.
+const (
+	marginTop    = 36
+	marginBottom = 48
+	marginSide   = 72
+	marginEmpty  = 24 // справа без правой оси
+	fontHeight   = 8  // высота строки текста
+	charWidth    = 6  // средняя ширина символа
+)
+
+// WriteSVG записывает график в SVG.
+func (f *Figure) WriteSVG(w io.Writer) error {
+	c := newSVG(f.size())
+	f.draw(c)
+	return c.writeTo(w)
+}
+
+// WritePNG записывает график в PNG с PNGScale пикселями на точку.
+func (f *Figure) WritePNG(w io.Writer) error {
+	c := newRaster(f.size())
+	f.draw(c)
+	return c.writeTo(w)
+}
+
+// Write записывает график в формате по расширению имени файла: .svg
+// или .png.
+func (f *Figure) Write(w io.Writer, name string) error {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".svg":
+		return f.WriteSVG(w)
+	case ".png":
+		return f.WritePNG(w)
+	}
+	return fmt.Errorf("plot: неизвестный формат %q (.svg, .png)", filepath.Ext(name))
+}
+
+func (f *Figure) size() (float64, float64) {
+	w, h := f.Width, f.Height
+	if w <= 0 {
+		w = DefaultWidth
+	}
+	if h <= 0 {
+		h = DefaultHeight
+	}
+	return w, h
+}
+
+// scale переводит значения оси в координаты холста.
+type scale struct {
+	axis     Axis
+	min, max float64
+	from, to float64 // координаты min и max
+}
+
+func (s scale) at(v float64) float64 {
+	if s.max == s.min {
+		return (s.from + s.to) / 2
+	}
+	return s.from + (v-s.min)/(s.max-s.min)*(s.to-s.from)
+}
+
+// newScale подбирает пределы оси по данным и округляет их до делений.
+func newScale(a Axis, values [][]float64, from, to float64) (scale, []float64) {
+	lo, hi := a.Min, a.Max
+	if lo == hi {
+		lo, hi = math.Inf(1), math.Inf(-1)
+		for _, vs := range values {
+			for _, v := range vs {
+				if !math.IsNaN(v) && !math.IsInf(v, 0) {
+					lo, hi = math.Min(lo, v), math.Max(hi, v)
+				}
+			}
+		}
+		if math.IsInf(lo, 1) {
+			lo, hi = 0, 1
+		}
+	}
+	if lo == hi {
+		d := math.Max(math.Abs(lo)*0.01, 0.5)
+		if a.Time {
+			d = 30
+		}
+		lo, hi = lo-d, hi+d
+	}
+
+	var ticks []float64
+	if a.Time {
+		ticks = timeTicks(lo, hi)
+	} else {
+		ticks = niceTicks(lo, hi)
+		// Для чисел пределы — по крайним делениям
+		lo, hi = math.Min(lo, ticks[0]), math.Max(hi, ticks[len(ticks)-1])
+	}
+	if a.Invert {
+		from, to = to, from
+	}
+	return scale{axis: a, min: lo, max: hi, from: from, to: to}, ticks
+}
+
+// niceTicks — деления 1, 2 или 5 × 10^n, от 4 до 10 штук.
+func niceTicks(lo, hi float64) []float64 {
+	raw := (hi - lo) / 6
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := mag
+	for _, m := range []float64{1, 2, 5, 10} {
+		if step = m * mag; step >= raw {
+			break
+		}
+	}
+	// Считаем в шагах: сумма шагов копит ошибку и теряет крайнее деление
+	var ticks []float64
+	for n := math.Floor(lo/step + 1e-9); n <= math.Floor(hi/step+0.5+1e-9); n++ {
+		ticks = append(ticks, n*step)
+	}
+	return ticks
+}
+
+// timeSteps — шаги делений оси времени, в секундах.
+var timeSteps = []float64{1, 2, 5, 10, 15, 30, 60, 120, 300, 600, 900, 1800,
+	3600, 2 * 3600, 3 * 3600, 6 * 3600, 12 * 3600, 24 * 3600}
+
+// timeTicks — деления оси времени на круглых моментах местного
+// времени, не больше 10.
+func timeTicks(lo, hi float64) []float64 {
+	step := timeSteps[len(timeSteps)-1]
+	for _, s := range timeSteps {
+		if (hi-lo)/s <= 10 {
+			step = s
+			break
+		}
+	}
+	_, offset := time.Unix(int64(lo), 0).Zone()
+	var ticks []float64
+	for v := math.Ceil((lo+float64(offset))/step)*step - float64(offset); v <= hi; v += step {
+		ticks = append(ticks, v)
+	}
+	return ticks
+}
+
+// tickLabel подписывает деление оси.
+func tickLabel(a Axis, v float64, ticks []float64) string {
+	if a.Time {
+		t := time.Unix(int64(math.Round(v)), 0)
+		if len(ticks) > 1 && ticks[1]-ticks[0] >= 60 {
+			return t.Format("15:04")
+		}
+		return t.Format("15:04:05")
+	}
+	return formatNumber(v, ticks)
+}
+
+// formatNumber печатает число с точностью шага делений.
+func formatNumber(v float64, ticks []float64) string {
+	digits := 0
+	if len(ticks) > 1 {
+		if step := ticks[1] - ticks[0]; step < 1 {
+			digits = int(math.Ceil(-math.Log10(step) - 1e-9))
+		}
+	}
+	return fmt.Sprintf("%.*f", digits, v)
+}
+
+// timeAxisLabel добавляет к подписи оси времени дату начала.
+func timeAxisLabel(a Axis, min float64) string {
+	date := time.Unix(int64(min), 0).Format("2006-01-02")
+	if a.Label == "" {
+		return "Время, " + date
+	}
+	return a.Label + ", " + date
+}
+
+// draw рисует график на холсте.
+func (f *Figure) draw(c canvas) {
+	width, height := f.size()
+	hasRight := false
+	var xs, left, right [][]float64
+	for _, s := range f.Series {
+		xs = append(xs, s.X)
+		if s.Right {
+			right, hasRight = append(right, s.Y), true
+		} else {
+			left = append(left, s.Y)
+		}
+	}
+	for _, m := range f.Marks {
+		xs = append(xs, []float64{m.X})
+	}
+
+	x0, x1 := float64(marginSide), width-marginEmpty
+	if hasRight {
+		x1 = width - marginSide
+	}
+	y0, y1 := height-marginBottom, float64(marginTop)
+	sx, xticks := newScale(f.X, xs, x0, x1)
+	sy, yticks := newScale(f.Y, left, y0, y1)
+	var sy2 scale
+	var y2ticks []float64
+	if hasRight {
+		sy2, y2ticks = newScale(f.Y2, right, y0, y1)
+	}
+	clip := func(v, a, b float64) float64 { return math.Max(math.Min(v, math.Max(a, b)), math.Min(a, b)) }
+
+	c.rect(0, 0, width, height, white)
+	if f.Title != "" {
+		c.text(width/2, marginTop/2+fontHeight/2, f.Title, alignCenter, black, false)
+	}
+
+	// Интервалы под всем остальным
+	for _, sp := range f.Spans {
+		a, b := clip(sx.at(sp.From), x0, x1), clip(sx.at(sp.To), x0, x1)
+		if b-a < 1 {
+			b = a + 1
+		}
+		c.rect(math.Min(a, b), y1, math.Abs(b-a), y0-y1, sp.Color)
+		if sp.Text != "" {
+			c.text(math.Min(a, b)+2, y1+fontHeight+2, sp.Text, alignLeft, grey, false)
+		}
+	}
+
+	// Сетка, деления и подписи
+	for _, v := range xticks {
+		x := sx.at(v)
+		if x < x0-0.5 || x > x1+0.5 {
+			continue
+		}
+		c.line(x, y0, x, y1, grid, 1, false)
+		c.line(x, y0, x, y0+4, black, 1, false)
+		c.text(x, y0+6+fontHeight, tickLabel(f.X, v, xticks), alignCenter, black, false)
+	}
+	for _, v := range yticks {
+		y := sy.at(v)
+		c.line(x0, y, x1, y, grid, 1, false)
+		c.line(x0-4, y, x0, y, black, 1, false)
+		c.text(x0-6, y+fontHeight/2, tickLabel(f.Y, v, yticks), alignRight, black, false)
+	}
+	for _, v := range y2ticks {
+		y := sy2.at(v)
+		c.line(x1, y, x1+4, y, black, 1, false)
+		c.text(x1+6, y+fontHeight/2, tickLabel(f.Y2, v, y2ticks), alignLeft, black, false)
+	}
+	c.line(x0, y0, x1, y0, black, 1, false)
+	c.line(x0, y0, x0, y1, black, 1, false)
+	if hasRight {
+		c.line(x1, y0, x1, y1, black, 1, false)
+	}
+
+	xlabel := f.X.Label
+	if f.X.Time {
+		xlabel = timeAxisLabel(f.X, sx.min)
+	}
+	c.text((x0+x1)/2, height-8, xlabel, alignCenter, black, false)
+	c.text(14, (y0+y1)/2, f.Y.Label, alignCenter, black, true)
+	if hasRight {
+		c.text(width-6, (y0+y1)/2, f.Y2.Label, alignCenter, black, true)
+	}
+
+	// Ряды
+	for i, s := range f.Series {
+		ys := sy
+		if s.Right {
+			ys = sy2
+		}
+		px := make([]float64, len(s.X))
+		py := make([]float64, len(s.X))
+		for j := range s.X {
+			px[j], py[j] = sx.at(s.X[j]), math.NaN()
+			if j < len(s.Y) {
+				py[j] = ys.at(s.Y[j])
+			}
+		}
+		c.polyline(px, py, palette[i%len(palette)], 1.5)
+	}
+
+	// Отметки поверх рядов
+	for _, m := range f.Marks {
+		x := sx.at(m.X)
+		if x < x0 || x > x1 {
+			continue
+		}
+		c.line(x, y0, x, y1, grey, 1, true)
+		if m.Text != "" {
+			c.text(x-3, y0-4, m.Text, alignLeft, grey, true)
+		}
+	}
+
+	// Легенда в левом верхнем углу области построения
+	for i, s := range f.Series {
+		y := y1 + 10 + float64(i)*(fontHeight+6)
+		col := palette[i%len(palette)]
+		c.line(x0+8, y-3, x0+28, y-3, col, 2, false)
+		name := s.Name
+		if s.Right && len(f.Series) > 1 {
+			name += " (правая ось)"
+		}
+		c.text(x0+32, y+1, name, alignLeft, black, false)
+	}
+}
+
+// Reduce прореживает ряды до не более чем points точек: в каждой
+// группе соседних точек остаются наименьшая и наибольшая, так что
+// выбросы не теряются. Разрывы линии сохраняются.
+func (f *Figure) Reduce(points int) {
+	for i := range f.Series {
+		f.Series[i].reduce(points)
+	}
+}
+
+func (s *Series) reduce(points int) {
+	if points < 2 || len(s.X) <= points || len(s.Y) != len(s.X) {
+		return
+	}
+	size := (len(s.X) + points/2 - 1) / (points / 2)
+	var xs, ys []float64
+	for start := 0; start < len(s.X); start += size {
+		end := start + size
+		if end > len(s.X) {
+			end = len(s.X)
+		}
+		// Отрезки группы между разрывами сводятся отдельно, чтобы
+		// разрыв остался между теми же точками
+		run := start
+		for j := start; j <= end; j++ {
+			if j < end && !math.IsNaN(s.X[j]) && !math.IsNaN(s.Y[j]) {
+				continue
+			}
+			xs, ys = s.extremes(xs, ys, run, j)
+			if j < end && len(ys) > 0 && !math.IsNaN(ys[len(ys)-1]) {
+				xs, ys = append(xs, math.NaN()), append(ys, math.NaN())
+			}
+			run = j + 1
+		}
+	}
+	s.X, s.Y = xs, ys
+}
+
+// extremes дописывает наименьшую и наибольшую точки s[from:to] в
+// порядке следования.
+func (s *Series) extremes(xs, ys []float64, from, to int) ([]float64, []float64) {
+	if from >= to {
+		return xs, ys
+	}
+	lo, hi := from, from
+	for j := from + 1; j < to; j++ {
+		if s.Y[j] < s.Y[lo] {
+			lo = j
+		}
+		if s.Y[j] > s.Y[hi] {
+			hi = j
+		}
+	}
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	xs, ys = append(xs, s.X[lo]), append(ys, s.Y[lo])
+	if hi != lo {
+		xs, ys = append(xs, s.X[hi]), append(ys, s.Y[hi])
+	}
+	return xs, ys
+}
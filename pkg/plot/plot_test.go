@@ -0,0 +1,126 @@
+package plot
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNiceTicks(t *testing.T) {
+	tests := []struct {
+		lo, hi float64
+		want   string
+	}{
+		{0, 10, "[0 2 4 6 8 10]"},
+		{0, 1, "[0.0 0.2 0.4 0.6 0.8 1.0]"},
+		{1013.2, 1013.9, "[1013.2 1013.4 1013.6 1013.8 1014.0]"},
+		{-3, 27, "[-5 0 5 10 15 20 25]"},
+		{0, 6000, "[0 1000 2000 3000 4000 5000 6000]"},
+	}
+	for _, tt := range tests {
+		ticks := niceTicks(tt.lo, tt.hi)
+		var labels []string
+		for _, v := range ticks {
+			labels = append(labels, formatNumber(v, ticks))
+		}
+		if got := fmt.Sprint(labels); got != tt.want {
+			t.Errorf("niceTicks(%g, %g) = %s, ожидается %s", tt.lo, tt.hi, got, tt.want)
+		}
+	}
+}
+
+func TestTimeTicks(t *testing.T) {
+	start := time.Date(2025, 8, 29, 3, 6, 48, 0, time.Local)
+	tests := []struct {
+		d    time.Duration
+		step time.Duration
+	}{
+		{8 * time.Second, time.Second},
+		{time.Minute, 10 * time.Second},
+		{40 * time.Minute, 5 * time.Minute},
+		{5 * time.Hour, 30 * time.Minute},
+		{30 * 24 * time.Hour, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		lo := float64(start.Unix())
+		ticks := timeTicks(lo, lo+tt.d.Seconds())
+		if len(ticks) == 0 || len(ticks) > 10 && tt.step < 24*time.Hour {
+			t.Fatalf("%v: деления %v", tt.d, ticks)
+		}
+		for i, v := range ticks {
+			tick := time.Unix(int64(v), 0)
+			if v < lo || v > lo+tt.d.Seconds() {
+				t.Errorf("%v: деление %v вне пределов", tt.d, tick)
+			}
+			// Деления — на круглых моментах местного времени
+			if _, offset := tick.Zone(); int64(v+float64(offset))%int64(tt.step.Seconds()) != 0 {
+				t.Errorf("%v: деление %v не кратно %v", tt.d, tick, tt.step)
+			}
+			if i > 0 && v-ticks[i-1] != tt.step.Seconds() {
+				t.Errorf("%v: шаг %v, ожидается %v", tt.d, v-ticks[i-1], tt.step)
+			}
+		}
+	}
+}
+
+func TestNewScale(t *testing.T) {
+	tests := []struct {
+		name         string
+		axis         Axis
+		values       [][]float64
+		min, max     float64
+		atMin, atMax float64
+	}{
+		{"по данным", Axis{}, [][]float64{{1.5, math.NaN(), 9.2}, {3}}, 0, 10, 100, 0},
+		{"заданные пределы", Axis{Min: -1, Max: 1}, [][]float64{{5}}, -1, 1, 100, 0},
+		{"постоянное значение", Axis{}, [][]float64{{20, 20}}, 19.4, 20.6, 100, 0},
+		{"нет данных", Axis{}, [][]float64{{math.NaN()}}, 0, 1, 100, 0},
+		{"глубина вниз", Axis{Invert: true}, [][]float64{{0, 50}}, 0, 50, 0, 100},
+		{"время без разброса", Axis{Time: true}, [][]float64{{1000}}, 970, 1030, 100, 0},
+	}
+	for _, tt := range tests {
+		s, ticks := newScale(tt.axis, tt.values, 100, 0)
+		if math.Abs(s.min-tt.min) > 1e-9 || math.Abs(s.max-tt.max) > 1e-9 {
+			t.Errorf("%s: пределы %g..%g, ожидается %g..%g", tt.name, s.min, s.max, tt.min, tt.max)
+		}
+		if math.Abs(s.at(s.min)-tt.atMin) > 1e-9 || math.Abs(s.at(s.max)-tt.atMax) > 1e-9 {
+			t.Errorf("%s: координаты %g..%g, ожидается %g..%g", tt.name, s.at(s.min), s.at(s.max), tt.atMin, tt.atMax)
+		}
+		if len(ticks) == 0 || ticks[0] < s.min-1e-9 || ticks[len(ticks)-1] > s.max+1e-9 {
+			t.Errorf("%s: деления %v вне %g..%g", tt.name, ticks, s.min, s.max)
+		}
+	}
+}
+
+func TestReduce(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name   string
+		y      []float64
+		points int
+		want   string
+	}{
+		{"мало точек", []float64{1, 2, 3}, 4, "[1 2 3]"},
+		{"наименьшая и наибольшая", []float64{5, 1, 9, 4, 3, 8, 2, 6}, 4, "[1 9 8 2]"},
+		{"разрыв в начале группы", []float64{1, 2, 3, 4, nan, 6, 7, 8}, 4, "[1 4 NaN 6 8]"},
+		{"разрыв в середине группы", []float64{1, 2, nan, 4, 5, 6, 7, 8}, 4, "[1 2 NaN 4 5 8]"},
+		{"несколько разрывов подряд", []float64{1, 2, 3, nan, nan, 6, 7, 8}, 4, "[1 3 NaN 6 8]"},
+	}
+	for _, tt := range tests {
+		s := Series{Y: tt.y}
+		for i := range tt.y {
+			s.X = append(s.X, float64(i))
+		}
+		s.reduce(tt.points)
+		if got := fmt.Sprint(s.Y); got != tt.want {
+			t.Errorf("%s: %s, ожидается %s", tt.name, got, tt.want)
+		}
+		// X сохраняет порядок, разрывы совпадают
+		for i := 1; i < len(s.X); i++ {
+			if math.IsNaN(s.X[i]) != math.IsNaN(s.Y[i]) || !math.IsNaN(s.X[i]) && !math.IsNaN(s.X[i-1]) && s.X[i] <= s.X[i-1] {
+				t.Errorf("%s: X %v", tt.name, s.X)
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package plot
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// PNGScale — пикселей PNG на точку графика.
+const PNGScale = 2
+
+// rasterCanvas рисует со сглаживанием на image.RGBA.
+type rasterCanvas struct {
+	img  *image.RGBA
+	k    float64   // пикселей на точку
+	mask []float32 // покрытие пикселей текущей линией
+}
+
+func newRaster(width, height float64) *rasterCanvas {
+	w, h := int(math.Ceil(width*PNGScale)), int(math.Ceil(height*PNGScale))
+	return &rasterCanvas{
+		img:  image.NewRGBA(image.Rect(0, 0, w, h)),
+		k:    PNGScale,
+		mask: make([]float32, w*h),
+	}
+}
+
+func (c *rasterCanvas) writeTo(w io.Writer) error {
+	return png.Encode(w, c.img)
+}
+
+// blend накладывает цвет с долей покрытия cover.
+func (c *rasterCanvas) blend(x, y int, col color.RGBA, cover float64) {
+	if !(image.Point{x, y}.In(c.img.Rect)) {
+		return
+	}
+	a := float64(col.A) / 255 * cover
+	i := c.img.PixOffset(x, y)
+	p := c.img.Pix[i : i+4 : i+4]
+	p[0] = uint8(float64(p[0])*(1-a) + float64(col.R)*a + 0.5)
+	p[1] = uint8(float64(p[1])*(1-a) + float64(col.G)*a + 0.5)
+	p[2] = uint8(float64(p[2])*(1-a) + float64(col.B)*a + 0.5)
+	p[3] = 0xff
+}
+
+func (c *rasterCanvas) rect(x, y, w, h float64, col color.RGBA) {
+	x0, y0 := int(math.Round(x*c.k)), int(math.Round(y*c.k))
+	x1, y1 := int(math.Round((x+w)*c.k)), int(math.Round((y+h)*c.k))
+	for py := y0; py < y1; py++ {
+		for px := x0; px < x1; px++ {
+			c.blend(px, py, col, 1)
+		}
+	}
+}
+
+func (c *rasterCanvas) line(x1, y1, x2, y2 float64, col color.RGBA, width float64, dashed bool) {
+	if !dashed {
+		c.stroke([][4]float64{{x1, y1, x2, y2}}, col, width)
+		return
+	}
+	// Штрих 4, пробел 3 точки
+	length := math.Hypot(x2-x1, y2-y1)
+	var segs [][4]float64
+	for d := 0.0; d < length; d += 7 {
+		e := math.Min(d+4, length)
+		segs = append(segs, [4]float64{
+			x1 + (x2-x1)*d/length, y1 + (y2-y1)*d/length,
+			x1 + (x2-x1)*e/length, y1 + (y2-y1)*e/length,
+		})
+	}
+	c.stroke(segs, col, width)
+}
+
+func (c *rasterCanvas) polyline(xs, ys []float64, col color.RGBA, width float64) {
+	var segs [][4]float64
+	for i := 1; i < len(xs); i++ {
+		if math.IsNaN(xs[i-1]) || math.IsNaN(ys[i-1]) || math.IsNaN(xs[i]) || math.IsNaN(ys[i]) {
+			continue
+		}
+		segs = append(segs, [4]float64{xs[i-1], ys[i-1], xs[i], ys[i]})
+	}
+	// Одиночная точка между разрывами — короткий штрих
+	for i := range xs {
+		prev := i > 0 && !math.IsNaN(xs[i-1]) && !math.IsNaN(ys[i-1])
+		next := i+1 < len(xs) && !math.IsNaN(xs[i+1]) && !math.IsNaN(ys[i+1])
+		if !prev && !next && !math.IsNaN(xs[i]) && !math.IsNaN(ys[i]) {
+			segs = append(segs, [4]float64{xs[i] - 0.5, ys[i], xs[i] + 0.5, ys[i]})
+		}
+	}
+	c.stroke(segs, col, width)
+}
+
+// stroke рисует отрезки толщиной width: сначала набирает покрытие
+// пикселей, затем накладывает цвет один раз, чтобы стыки отрезков не
+// темнели.
+func (c *rasterCanvas) stroke(segs [][4]float64, col color.RGBA, width float64) {
+	b := c.img.Rect
+	hw := width * c.k / 2
+	box := image.Rectangle{}
+	for _, s := range segs {
+		ax, ay, bx, by := s[0]*c.k, s[1]*c.k, s[2]*c.k, s[3]*c.k
+		r := image.Rect(
+			int(math.Floor(math.Min(ax, bx)-hw-1)), int(math.Floor(math.Min(ay, by)-hw-1)),
+			int(math.Ceil(math.Max(ax, bx)+hw+1)), int(math.Ceil(math.Max(ay, by)+hw+1)),
+		).Intersect(b)
+		box = box.Union(r)
+		for py := r.Min.Y; py < r.Max.Y; py++ {
+			for px := r.Min.X; px < r.Max.X; px++ {
+				d := segmentDistance(float64(px)+0.5, float64(py)+0.5, ax, ay, bx, by)
+				cover := float32(math.Min(math.Max(hw+0.5-d, 0), 1))
+				if i := py*b.Dx() + px; cover > c.mask[i] {
+					c.mask[i] = cover
+				}
+			}
+		}
+	}
+	for py := box.Min.Y; py < box.Max.Y; py++ {
+		for px := box.Min.X; px < box.Max.X; px++ {
+			i := py*b.Dx() + px
+			if c.mask[i] > 0 {
+				c.blend(px, py, col, float64(c.mask[i]))
+				c.mask[i] = 0
+			}
+		}
+	}
+}
+
+// segmentDistance — расстояние от точки (px, py) до отрезка a–b.
+func segmentDistance(px, py, ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, ((px-ax)*dx+(py-ay)*dy)/l))
+	}
+	return math.Hypot(px-ax-t*dx, py-ay-t*dy)
+}
+
+// text рисует строку растровым шрифтом: пиксель шрифта — точка
+// графика, символ занимает 6 точек.
+func (c *rasterCanvas) text(x, y float64, s string, align int, col color.RGBA, vertical bool) {
+	s = latin(s)
+	width := float64(len(s)*charWidth - 1)
+	start := 0.0
+	switch align {
+	case alignCenter:
+		start = -width / 2
+	case alignRight:
+		start = -width
+	}
+	for i := 0; i < len(s); i++ {
+		glyph := font5x7[s[i]-' ']
+		for col5, bits := range glyph {
+			for row := 0; row < 8; row++ {
+				if bits&(1<<row) == 0 {
+					continue
+				}
+				// u — вдоль строки, v — от базовой линии вниз
+				u := start + float64(i*charWidth+col5)
+				v := float64(row - 7)
+				px, py := x+u, y+v
+				if vertical {
+					px, py = x+v, y-u-1
+				}
+				c.rect(px, py, 1, 1, col)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package plot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+)
+
+// svgCanvas собирает элементы SVG.
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func newSVG(width, height float64) *svgCanvas {
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family="sans-serif" font-size="11">`+"\n",
+		width, height, width, height)
+	return c
+}
+
+func (c *svgCanvas) writeTo(w io.Writer) error {
+	c.buf.WriteString("</svg>\n")
+	_, err := w.Write(c.buf.Bytes())
+	return err
+}
+
+// svgColor возвращает цвет и непрозрачность для атрибутов SVG.
+func svgColor(col color.RGBA) (string, string) {
+	return fmt.Sprintf("#%02x%02x%02x", col.R, col.G, col.B), fmt.Sprintf("%.3g", float64(col.A)/255)
+}
+
+func (c *svgCanvas) rect(x, y, w, h float64, col color.RGBA) {
+	fill, opacity := svgColor(col)
+	fmt.Fprintf(&c.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" fill-opacity="%s"/>`+"\n",
+		x, y, w, h, fill, opacity)
+}
+
+func (c *svgCanvas) line(x1, y1, x2, y2 float64, col color.RGBA, width float64, dashed bool) {
+	stroke, opacity := svgColor(col)
+	dash := ""
+	if dashed {
+		dash = ` stroke-dasharray="4 3"`
+	}
+	fmt.Fprintf(&c.buf, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-opacity="%s" stroke-width="%g"%s/>`+"\n",
+		x1, y1, x2, y2, stroke, opacity, width, dash)
+}
+
+func (c *svgCanvas) polyline(xs, ys []float64, col color.RGBA, width float64) {
+	stroke, _ := svgColor(col)
+	fmt.Fprintf(&c.buf, `<path fill="none" stroke="%s" stroke-width="%g" stroke-linejoin="round" d="`, stroke, width)
+	pen := false
+	for i := range xs {
+		if math.IsNaN(xs[i]) || math.IsNaN(ys[i]) {
+			pen = false
+			continue
+		}
+		cmd := "L"
+		if !pen {
+			cmd = "M"
+		}
+		fmt.Fprintf(&c.buf, "%s%.1f %.1f", cmd, xs[i], ys[i])
+		pen = true
+	}
+	c.buf.WriteString(`"/>` + "\n")
+}
+
+func (c *svgCanvas) text(x, y float64, s string, align int, col color.RGBA, vertical bool) {
+	if s == "" {
+		return
+	}
+	fill, _ := svgColor(col)
+	anchor := [...]string{alignLeft: "start", alignCenter: "middle", alignRight: "end"}[align]
+	rotate := ""
+	if vertical {
+		rotate = fmt.Sprintf(` transform="rotate(-90 %.1f %.1f)"`, x, y)
+	}
+	fmt.Fprintf(&c.buf, `<text x="%.1f" y="%.1f" fill="%s" text-anchor="%s"%s>`, x, y, fill, anchor, rotate)
+	xml.EscapeText(&c.buf, []byte(s))
+	c.buf.WriteString("</text>\n")
+}
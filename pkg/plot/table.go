@@ -0,0 +1,195 @@
+package plot
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/export"
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+)
+
+// TimeSeries строит график полей таблицы по времени: left — по левой
+// оси Y, right — по правой.
+func TimeSeries(t *export.Table, left, right []string) (*Figure, error) {
+	if len(left) == 0 && len(right) == 0 {
+		return nil, fmt.Errorf("plot: не заданы поля")
+	}
+	if len(left) == 0 {
+		left, right = right, nil
+	}
+	f := &Figure{Title: title(t), X: Axis{Time: true}}
+	xs := make([]float64, t.Len())
+	for i, tm := range t.Time {
+		xs[i] = unixSeconds(tm)
+	}
+	breaks := gaps(xs)
+	xs = withBreaks(xs, breaks)
+	for _, side := range []struct {
+		names []string
+		axis  *Axis
+		right bool
+	}{{left, &f.Y, false}, {right, &f.Y2, true}} {
+		for _, name := range side.names {
+			k, err := column(t, name)
+			if err != nil {
+				return nil, err
+			}
+			f.Series = append(f.Series, Series{
+				Name:  columnLabel(t.Columns[k]),
+				X:     xs,
+				Y:     withBreaks(t.Values[k], breaks),
+				Right: side.right,
+			})
+		}
+		if len(side.names) == 1 {
+			k, _ := column(t, side.names[0])
+			side.axis.Label = columnLabel(t.Columns[k])
+		}
+	}
+	return f, nil
+}
+
+// Profile строит профиль по глубине: значения полей fields по оси X
+// против поля depth по оси Y, направленной вниз.
+func Profile(t *export.Table, depth string, fields []string) (*Figure, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("plot: не заданы поля")
+	}
+	d, err := column(t, depth)
+	if err != nil {
+		return nil, err
+	}
+	f := &Figure{
+		Title:  title(t),
+		Height: DefaultWidth,
+		Width:  DefaultHeight + 100,
+		Y:      Axis{Label: columnLabel(t.Columns[d]), Invert: true},
+	}
+	for _, name := range fields {
+		k, err := column(t, name)
+		if err != nil {
+			return nil, err
+		}
+		f.Series = append(f.Series, Series{
+			Name: columnLabel(t.Columns[k]),
+			X:    t.Values[k],
+			Y:    t.Values[d],
+		})
+	}
+	if len(fields) == 1 {
+		f.X.Label = f.Series[0].Name
+	}
+	return f, nil
+}
+
+// AddEvents наносит на график по времени отметки и заметки оператора,
+// паузы и перерывы связи из таблицы и её манифеста.
+func (f *Figure) AddEvents(t *export.Table) {
+	if !f.X.Time || t.Len() == 0 {
+		return
+	}
+	last := t.Time[t.Len()-1]
+	for _, n := range t.Marks {
+		f.Marks = append(f.Marks, Mark{X: unixSeconds(n.Time), Text: n.Text})
+	}
+	m := t.Manifest
+	if m == nil {
+		return
+	}
+	for _, n := range m.Notes {
+		f.Marks = append(f.Marks, Mark{X: unixSeconds(n.Time), Text: n.Text})
+	}
+	for _, p := range m.Pauses {
+		f.Spans = append(f.Spans, span(p.Start, p.End, last, "пауза", PauseColor))
+	}
+	for _, src := range m.AllSources() {
+		text := "нет связи"
+		if src.Name != "" {
+			text += " " + src.Name
+		}
+		for _, g := range src.Gaps {
+			f.Spans = append(f.Spans, span(g.Start, g.End, last, text, GapColor))
+		}
+	}
+}
+
+// gaps находит строки, перед которыми линия рвётся: шаг по времени
+// до них больше пяти обычных (пауза, перерыв связи).
+func gaps(xs []float64) []int {
+	if len(xs) < 3 {
+		return nil
+	}
+	steps := make([]float64, len(xs)-1)
+	for i := range steps {
+		steps[i] = xs[i+1] - xs[i]
+	}
+	sorted := append([]float64(nil), steps...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	if median <= 0 {
+		return nil
+	}
+	var at []int
+	for i, s := range steps {
+		if s > 5*median {
+			at = append(at, i+1)
+		}
+	}
+	return at
+}
+
+// withBreaks вставляет NaN перед строками at.
+func withBreaks(v []float64, at []int) []float64 {
+	if len(at) == 0 {
+		return v
+	}
+	out := make([]float64, 0, len(v)+len(at))
+	prev := 0
+	for _, i := range at {
+		out = append(append(out, v[prev:i]...), math.NaN())
+		prev = i
+	}
+	return append(out, v[prev:]...)
+}
+
+// span — интервал от start до end; незакрытый тянется до last.
+func span(start time.Time, end *time.Time, last time.Time, text string, c color.RGBA) Span {
+	to := last
+	if end != nil {
+		to = *end
+	}
+	return Span{From: unixSeconds(start), To: unixSeconds(to), Text: text, Color: c}
+}
+
+// title — заголовок графика: имя эксперимента.
+func title(t *export.Table) string {
+	if t.Manifest == nil {
+		return ""
+	}
+	return t.Manifest.Name
+}
+
+// column ищет столбец таблицы по имени.
+func column(t *export.Table, name string) (int, error) {
+	for k, c := range t.Columns {
+		if c.Name == name {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("plot: нет поля %q", name)
+}
+
+// columnLabel — подпись столбца с единицами.
+func columnLabel(c recording.Column) string {
+	if c.Unit == "" {
+		return c.Name
+	}
+	return c.Name + ", " + c.Unit
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
@@ -0,0 +1,160 @@
+package qc
+
+import (
+	"math"
+	"time"
+)
+
+// Checker проверяет строки по порядку: скорость изменения, залипание и
+// промежуток зависят от предыдущих строк. Не для одновременного
+// использования из нескольких горутин.
+type Checker struct {
+	rules  Rules
+	fields map[string]bool
+	last   time.Time
+	prev   map[string]previous
+	stuck  map[string]*run
+}
+
+// previous — предыдущее значение поля.
+type previous struct {
+	value float64
+	time  time.Time
+}
+
+// run — серия одинаковых значений.
+type run struct {
+	value float64
+	count int
+}
+
+// NewChecker создаёт проверку по правилам r.
+func NewChecker(r *Rules) *Checker {
+	return &Checker{
+		rules:  *r,
+		fields: r.Fields(),
+		prev:   make(map[string]previous),
+		stuck:  make(map[string]*run),
+	}
+}
+
+// Rules возвращает правила проверки.
+func (c *Checker) Rules() *Rules { return &c.rules }
+
+// Check проверяет значения строки, принятой в момент t, и возвращает
+// флаги всех полей строки и полей из правил, которых в строке нет.
+func (c *Checker) Check(t time.Time, values map[string]float64) map[string]Flag {
+	flags := make(map[string]Flag, len(values))
+	for name := range c.fields {
+		if v, ok := values[name]; !ok || math.IsNaN(v) {
+			flags[name] = Missing
+		}
+	}
+	for name, v := range values {
+		if math.IsNaN(v) {
+			flags[name] = Missing
+			continue
+		}
+		flags[name] = 0 // результат проверок ниже
+	}
+	set := func(name string, f Flag) {
+		if flags[name] != Missing {
+			flags[name] = Worse(flags[name], f)
+		}
+	}
+
+	for name, rule := range c.rules.Range {
+		if v, ok := present(values, name); ok {
+			set(name, rule.check(v))
+		}
+	}
+	for name, limits := range c.rules.Rate {
+		v, ok := present(values, name)
+		if !ok {
+			continue
+		}
+		if p, seen := c.prev[name]; seen {
+			if dt := t.Sub(p.time).Seconds(); dt > 0 {
+				set(name, limits.exceeds(math.Abs(v-p.value)/dt))
+			}
+		}
+	}
+	for name, rule := range c.rules.Stuck {
+		v, ok := present(values, name)
+		if !ok {
+			continue
+		}
+		r := c.stuck[name]
+		if r == nil || math.Abs(v-r.value) > rule.Tolerance {
+			r = &run{value: v}
+			c.stuck[name] = r
+		}
+		r.count++
+		set(name, rule.check(r.count))
+	}
+	for _, p := range c.rules.Consistency {
+		a, okA := present(values, p.Fields[0])
+		b, okB := present(values, p.Fields[1])
+		if okA && okB {
+			f := p.exceeds(math.Abs(a - b))
+			set(p.Fields[0], f)
+			set(p.Fields[1], f)
+		}
+	}
+	if g := c.rules.Gap; g != nil && !c.last.IsZero() {
+		f := g.check(t.Sub(c.last))
+		for name := range flags {
+			set(name, f)
+		}
+	}
+
+	for name, f := range flags {
+		if f == 0 {
+			flags[name] = NotEvaluated
+		}
+	}
+
+	c.last = t
+	for name, v := range values {
+		if !math.IsNaN(v) {
+			c.prev[name] = previous{value: v, time: t}
+		}
+	}
+	return flags
+}
+
+func present(values map[string]float64, name string) (float64, bool) {
+	v, ok := values[name]
+	return v, ok && !math.IsNaN(v)
+}
+
+func (r RangeRule) check(v float64) Flag {
+	outside := func(b []float64) bool { return len(b) == 2 && (v < b[0] || v > b[1]) }
+	switch {
+	case outside(r.Fail):
+		return Fail
+	case outside(r.Suspect):
+		return Suspect
+	}
+	return Pass
+}
+
+func (s StuckRule) check(count int) Flag {
+	switch {
+	case s.Fail > 0 && count >= s.Fail:
+		return Fail
+	case s.Suspect > 0 && count >= s.Suspect:
+		return Suspect
+	}
+	return Pass
+}
+
+func (g GapRule) check(d time.Duration) Flag {
+	switch {
+	case g.Fail > 0 && d > g.Fail:
+		return Fail
+	case g.Suspect > 0 && d > g.Suspect:
+		return Suspect
+	}
+	return Pass
+}
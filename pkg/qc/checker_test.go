@@ -0,0 +1,63 @@
+package qc
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	t0 := time.Date(2025, 8, 29, 3, 6, 48, 0, time.UTC)
+	type row struct {
+		at     time.Duration
+		values map[string]float64
+		want   map[string]Flag
+	}
+	tests := []struct {
+		name  string
+		rules Rules
+		rows  []row
+	}{
+		{"диапазон", Rules{Range: map[string]RangeRule{"P": {Fail: []float64{500, 6000}, Suspect: []float64{900, 3000}}}}, []row{
+			{0, map[string]float64{"P": 1013, "T1": 20}, map[string]Flag{"P": Pass, "T1": NotEvaluated}},
+			{time.Second, map[string]float64{"P": 800}, map[string]Flag{"P": Suspect}},
+			{2 * time.Second, map[string]float64{"P": 7000}, map[string]Flag{"P": Fail}},
+			{3 * time.Second, map[string]float64{"T1": 20}, map[string]Flag{"P": Missing, "T1": NotEvaluated}},
+			{4 * time.Second, map[string]float64{"P": math.NaN()}, map[string]Flag{"P": Missing}},
+		}},
+		{"скорость", Rules{Rate: map[string]Limits{"T2": {Suspect: 0.1, Fail: 1}}}, []row{
+			{0, map[string]float64{"T2": 20}, map[string]Flag{"T2": NotEvaluated}},
+			{time.Second, map[string]float64{"T2": 20.05}, map[string]Flag{"T2": Pass}},
+			{2 * time.Second, map[string]float64{"T2": 20.55}, map[string]Flag{"T2": Suspect}},
+			{4 * time.Second, map[string]float64{"T2": 23}, map[string]Flag{"T2": Fail}},
+		}},
+		{"залипание", Rules{Stuck: map[string]StuckRule{"P": {Suspect: 2, Fail: 3, Tolerance: 0.1}}}, []row{
+			{0, map[string]float64{"P": 1000}, map[string]Flag{"P": Pass}},
+			{time.Second, map[string]float64{"P": 1000.05}, map[string]Flag{"P": Suspect}},
+			{2 * time.Second, map[string]float64{"P": 1000}, map[string]Flag{"P": Fail}},
+			{3 * time.Second, map[string]float64{"P": 1001}, map[string]Flag{"P": Pass}},
+		}},
+		{"расхождение", Rules{Consistency: []PairRule{{Fields: []string{"T1", "T2"}, Limits: Limits{Suspect: 0.5, Fail: 2}}}}, []row{
+			{0, map[string]float64{"T1": 20, "T2": 20.3}, map[string]Flag{"T1": Pass, "T2": Pass}},
+			{time.Second, map[string]float64{"T1": 20, "T2": 21}, map[string]Flag{"T1": Suspect, "T2": Suspect}},
+			{2 * time.Second, map[string]float64{"T1": 20}, map[string]Flag{"T1": NotEvaluated, "T2": Missing}},
+		}},
+		{"промежуток", Rules{Gap: &GapRule{Suspect: 5 * time.Second, Fail: 30 * time.Second}}, []row{
+			{0, map[string]float64{"P": 1000}, map[string]Flag{"P": NotEvaluated}},
+			{time.Second, map[string]float64{"P": 1000}, map[string]Flag{"P": Pass}},
+			{10 * time.Second, map[string]float64{"P": 1000}, map[string]Flag{"P": Suspect}},
+			{time.Minute, map[string]float64{"P": 1000}, map[string]Flag{"P": Fail}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(&tt.rules)
+			for i, r := range tt.rows {
+				if got := c.Check(t0.Add(r.at), r.values); !reflect.DeepEqual(got, r.want) {
+					t.Errorf("строка %d: %v, ожидается %v", i+1, got, r.want)
+				}
+			}
+		})
+	}
+}
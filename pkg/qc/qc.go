@@ -0,0 +1,134 @@
+// Пакет qc проверяет качество данных по правилам и ставит значениям
+// флаги по соглашениям IOOS QARTOD: 1 — проверка пройдена, 2 — не
+// проверялось, 3 — сомнительно, 4 — брак, 9 — значения нет.
+//
+// Проверки (см. Rules): диапазон, скорость изменения (выбросы),
+// залипание значения, расхождение двух датчиков и промежуток между
+// строками. Флаг поля — худший из результатов проверок этого поля.
+//
+// Одни и те же правила использует мост (флаги передаются клиентам
+// вместе со строкой) и оператор (флаги пишутся в файл эксперимента
+// столбцами <поле>_qc).
+package qc
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flag — флаг качества значения по QARTOD.
+type Flag uint8
+
+const (
+	Pass         Flag = 1
+	NotEvaluated Flag = 2
+	Suspect      Flag = 3
+	Fail         Flag = 4
+	Missing      Flag = 9
+)
+
+// Flags — значения флагов в порядке flag_values NetCDF.
+var Flags = []Flag{Pass, NotEvaluated, Suspect, Fail, Missing}
+
+// Meanings — названия флагов для атрибута flag_meanings, в порядке Flags.
+const Meanings = "pass not_evaluated suspect fail missing"
+
+func (f Flag) String() string {
+	switch f {
+	case Pass:
+		return "норма"
+	case NotEvaluated:
+		return "не проверено"
+	case Suspect:
+		return "сомнительно"
+	case Fail:
+		return "брак"
+	case Missing:
+		return "нет значения"
+	}
+	return "флаг " + strconv.Itoa(int(f))
+}
+
+// severity упорядочивает флаги от лучшего к худшему.
+func (f Flag) severity() int {
+	switch f {
+	case Pass:
+		return 1
+	case Suspect:
+		return 2
+	case Fail:
+		return 3
+	case Missing:
+		return 4
+	}
+	return 0
+}
+
+// Worse возвращает худший из двух флагов; нулевой флаг считается
+// отсутствием результата.
+func Worse(a, b Flag) Flag {
+	if a == 0 || b != 0 && b.severity() > a.severity() {
+		return b
+	}
+	return a
+}
+
+// Bad сообщает, что значение сомнительно или забраковано.
+func (f Flag) Bad() bool {
+	return f == Suspect || f == Fail
+}
+
+// Prefix отделяет флаги от строки прошивки в строке моста:
+//
+//	20250829030648\tP:1013.25, T1:20.10\tqc:P=1,T1=3
+const Prefix = "qc:"
+
+// Format записывает флаги строкой "P=1,T1=3" с полями по алфавиту.
+func Format(flags map[string]Flag) string {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Itoa(int(flags[name])))
+	}
+	return b.String()
+}
+
+// Parse разбирает строку, записанную Format.
+func Parse(s string) (map[string]Flag, error) {
+	flags := make(map[string]Flag)
+	if s == "" {
+		return flags, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("qc: флаги %q: ожидается поле=флаг", s)
+		}
+		f, err := ParseFlag(value)
+		if err != nil {
+			return nil, err
+		}
+		flags[name] = f
+	}
+	return flags, nil
+}
+
+// ParseFlag разбирает число флага.
+func ParseFlag(s string) (Flag, error) {
+	v, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || v < 1 || v > 9 {
+		return 0, fmt.Errorf("qc: неверный флаг %q", s)
+	}
+	return Flag(v), nil
+}
@@ -0,0 +1,44 @@
+package qc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWorse(t *testing.T) {
+	tests := []struct {
+		a, b, want Flag
+	}{
+		{0, Pass, Pass},
+		{Pass, 0, Pass},
+		{Pass, Suspect, Suspect},
+		{Fail, Suspect, Fail},
+		{NotEvaluated, Pass, Pass},
+		{Fail, Missing, Missing},
+	}
+	for _, tt := range tests {
+		if got := Worse(tt.a, tt.b); got != tt.want {
+			t.Errorf("Worse(%v, %v) = %v, ожидается %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFormatParse(t *testing.T) {
+	flags := map[string]Flag{"T1": Suspect, "P": Pass, "T2": Missing}
+	s := Format(flags)
+	if s != "P=1,T1=3,T2=9" {
+		t.Errorf("Format = %s", s)
+	}
+	got, err := Parse(s)
+	if err != nil || !reflect.DeepEqual(got, flags) {
+		t.Errorf("Parse(%s) = %v, %v", s, got, err)
+	}
+	if got, err := Parse(""); err != nil || len(got) != 0 {
+		t.Errorf("Parse(\"\") = %v, %v", got, err)
+	}
+	for _, bad := range []string{"P", "=1", "P=0", "P=10", "P=x", "P=1,"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q): ожидается ошибка", bad)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package qc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rules — правила проверки. Пример файла:
+//
+//	range:                 # вне fail — брак, вне suspect — сомнительно
+//	  P: {fail: [500, 6000], suspect: [900, 3000]}
+//	  T2: {fail: [-3, 100], suspect: [0, 35]}
+//	rate:                  # скорость изменения, единиц поля в секунду
+//	  T2: {suspect: 0.1, fail: 1}
+//	stuck:                 # одно значение (с допуском) N строк подряд
+//	  P: {suspect: 10, fail: 60, tolerance: 0}
+//	consistency:           # расхождение двух датчиков
+//	  - {fields: [T1, T2], suspect: 0.5, fail: 2}
+//	gap: {suspect: 5s, fail: 30s}   # промежуток перед строкой
+//
+// Нулевой порог не проверяется.
+type Rules struct {
+	Range       map[string]RangeRule `yaml:"range" json:"range,omitempty"`
+	Rate        map[string]Limits    `yaml:"rate" json:"rate,omitempty"`
+	Stuck       map[string]StuckRule `yaml:"stuck" json:"stuck,omitempty"`
+	Consistency []PairRule           `yaml:"consistency" json:"consistency,omitempty"`
+	Gap         *GapRule             `yaml:"gap" json:"gap,omitempty"`
+}
+
+// RangeRule — допустимые диапазоны [мин, макс].
+type RangeRule struct {
+	Fail    []float64 `yaml:"fail" json:"fail,omitempty"`
+	Suspect []float64 `yaml:"suspect" json:"suspect,omitempty"`
+}
+
+// Limits — пороги отклонения.
+type Limits struct {
+	Suspect float64 `yaml:"suspect" json:"suspect,omitempty"`
+	Fail    float64 `yaml:"fail" json:"fail,omitempty"`
+}
+
+// StuckRule — сколько строк подряд значение может не меняться больше
+// чем на Tolerance.
+type StuckRule struct {
+	Suspect   int     `yaml:"suspect" json:"suspect,omitempty"`
+	Fail      int     `yaml:"fail" json:"fail,omitempty"`
+	Tolerance float64 `yaml:"tolerance" json:"tolerance,omitempty"`
+}
+
+// PairRule — наибольшее расхождение двух полей; флаг ставится обоим.
+type PairRule struct {
+	Fields []string `yaml:"fields" json:"fields"`
+	Limits `yaml:",inline"`
+}
+
+// GapRule — наибольший промежуток между строками; флаг ставится всем
+// полям строки после промежутка.
+type GapRule struct {
+	Suspect time.Duration `yaml:"suspect" json:"suspect,omitempty"`
+	Fail    time.Duration `yaml:"fail" json:"fail,omitempty"`
+}
+
+// Firmware — пределы, за которыми прошивка sketch_sep02a.ino сама
+// отбрасывает строки (P от 500 до 6000 мбар, температуры от -3 до
+// 100 °C).
+var Firmware = Rules{
+	Range: map[string]RangeRule{
+		"P":  {Fail: []float64{500, 6000}},
+		"T1": {Fail: []float64{-3, 100}},
+		"T2": {Fail: []float64{-3, 100}},
+	},
+}
+
+// Load читает правила из YAML-файла и проверяет их. Имя "firmware"
+// означает правила Firmware.
+func Load(path string) (*Rules, error) {
+	if path == "firmware" {
+		r := Firmware
+		return &r, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Rules
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Validate проверяет правила.
+func (r *Rules) Validate() error {
+	for name, rule := range r.Range {
+		for _, b := range [][]float64{rule.Fail, rule.Suspect} {
+			if b != nil && (len(b) != 2 || b[0] > b[1]) {
+				return fmt.Errorf("range %s: ожидается [мин, макс], получено %v", name, b)
+			}
+		}
+	}
+	for name, l := range r.Rate {
+		if err := l.validate(); err != nil {
+			return fmt.Errorf("rate %s: %w", name, err)
+		}
+	}
+	for name, s := range r.Stuck {
+		if s.Suspect < 0 || s.Fail < 0 || s.Tolerance < 0 {
+			return fmt.Errorf("stuck %s: пороги не могут быть отрицательными", name)
+		}
+		if s.Suspect == 1 || s.Fail == 1 {
+			return fmt.Errorf("stuck %s: порог должен быть не меньше 2 строк", name)
+		}
+	}
+	for i, p := range r.Consistency {
+		if len(p.Fields) != 2 || p.Fields[0] == "" || p.Fields[1] == "" {
+			return fmt.Errorf("consistency %d: ожидается два поля", i+1)
+		}
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("consistency %d: %w", i+1, err)
+		}
+	}
+	if g := r.Gap; g != nil && (g.Suspect < 0 || g.Fail < 0) {
+		return fmt.Errorf("gap: промежуток не может быть отрицательным")
+	}
+	return nil
+}
+
+func (l Limits) validate() error {
+	if l.Suspect < 0 || l.Fail < 0 {
+		return fmt.Errorf("пороги не могут быть отрицательными")
+	}
+	return nil
+}
+
+// exceeds сравнивает отклонение d с порогами.
+func (l Limits) exceeds(d float64) Flag {
+	switch {
+	case l.Fail > 0 && d > l.Fail:
+		return Fail
+	case l.Suspect > 0 && d > l.Suspect:
+		return Suspect
+	}
+	return Pass
+}
+
+// Fields возвращает поля, для которых есть правила.
+func (r *Rules) Fields() map[string]bool {
+	fields := make(map[string]bool)
+	for name := range r.Range {
+		fields[name] = true
+	}
+	for name := range r.Rate {
+		fields[name] = true
+	}
+	for name := range r.Stuck {
+		fields[name] = true
+	}
+	for _, p := range r.Consistency {
+		for _, name := range p.Fields {
+			fields[name] = true
+		}
+	}
+	return fields
+}
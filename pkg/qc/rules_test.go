@@ -0,0 +1,62 @@
+package qc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	r, err := Load(write("ok.yaml", `
+range:
+  P: {fail: [500, 6000], suspect: [900, 3000]}
+rate:
+  T2: {suspect: 0.1, fail: 1}
+stuck:
+  P: {suspect: 10}
+consistency:
+  - {fields: [T1, T2], suspect: 0.5, fail: 2}
+gap: {suspect: 5s, fail: 30s}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Consistency[0].Fail != 2 || r.Gap.Fail != 30*time.Second || r.Stuck["P"].Suspect != 10 {
+		t.Errorf("правила %+v", r)
+	}
+	if f := r.Fields(); len(f) != 3 || !f["P"] || !f["T1"] || !f["T2"] {
+		t.Errorf("Fields = %v", f)
+	}
+	if r, err := Load("firmware"); err != nil || r.Range["T1"].Fail[0] != -3 {
+		t.Errorf("firmware: %+v, %v", r, err)
+	}
+
+	tests := []struct {
+		text, err string
+	}{
+		{"range:\n  P: {fail: [6000, 500]}\n", "range P"},
+		{"range:\n  P: {fail: [1]}\n", "range P"},
+		{"rate:\n  P: {suspect: -1}\n", "rate P"},
+		{"stuck:\n  P: {fail: 1}\n", "не меньше 2"},
+		{"consistency:\n  - {fields: [T1], fail: 1}\n", "два поля"},
+		{"gap: {fail: -1s}\n", "gap"},
+		{"ranges: {}\n", "ranges"},
+	}
+	for _, tt := range tests {
+		_, err := Load(write("bad.yaml", tt.text))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: ошибка %v, ожидается %q", tt.text, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package recording
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveDir — подкаталог каталога экспериментов для архивных записей.
+const ArchiveDir = "archive"
+
+// IDTimeLayout — формат времени начала в конце ID эксперимента
+// ("<название>_20250829_030648").
+const IDTimeLayout = "20060102_150405"
+
+// Entry — эксперимент в каталоге: файлы данных и манифест.
+type Entry struct {
+	// ID — имя манифеста (или файла данных) без расширения, например
+	// "Погружение_20250829_030648".
+	ID string
+	// DataPath — файл данных; при нескольких источниках и сегментах —
+	// первый файл первого источника, все файлы см. Source и OpenSource.
+	DataPath     string
+	ManifestPath string
+	// Manifest равен nil у файлов без манифеста (записанных до появления
+	// манифестов) и у файлов с повреждённым манифестом.
+	Manifest *Manifest
+}
+
+// Catalog — каталог с файлами экспериментов.
+type Catalog struct {
+	Dir string
+}
+
+// List возвращает эксперименты каталога, упорядоченные по времени начала:
+// по манифестам и по файлам CSV без манифеста (старые записи).
+// Подкаталоги (в том числе архив) не просматриваются.
+func (c Catalog) List() ([]Entry, error) {
+	files, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	referenced := map[string]bool{}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(c.Dir, f.Name())
+		m, err := ReadManifest(path)
+		if err != nil || m.SchemaVersion == 0 {
+			continue
+		}
+		e := Entry{
+			ID:           strings.TrimSuffix(f.Name(), ".json"),
+			ManifestPath: path,
+			Manifest:     m,
+		}
+		for _, s := range m.AllSources() {
+			for _, f := range s.Files() {
+				referenced[f] = true
+			}
+		}
+		referenced[m.MergedFile] = true
+		e.DataPath = filepath.Join(c.Dir, m.AllSources()[0].Files()[0])
+		entries = append(entries, e)
+	}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".csv" || referenced[f.Name()] {
+			continue
+		}
+		entries = append(entries, c.entry(f.Name()))
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Start().Before(entries[j].Start())
+	})
+	return entries, nil
+}
+
+// entry описывает файл CSV без манифеста.
+func (c Catalog) entry(file string) Entry {
+	e := Entry{
+		ID:       strings.TrimSuffix(file, filepath.Ext(file)),
+		DataPath: filepath.Join(c.Dir, file),
+	}
+	e.ManifestPath = ManifestPath(e.DataPath)
+	return e
+}
+
+// Dir возвращает каталог файлов эксперимента.
+func (e Entry) Dir() string { return filepath.Dir(e.ManifestPath) }
+
+// Source возвращает источник по имени; пустое имя — первый источник.
+// У файлов без манифеста источник один — сам файл.
+func (e Entry) Source(name string) (*Source, error) {
+	if e.Manifest == nil {
+		if name != "" {
+			return nil, fmt.Errorf("у эксперимента %s нет манифеста и источников", e.ID)
+		}
+		return &Source{DataFile: filepath.Base(e.DataPath)}, nil
+	}
+	return e.Manifest.FindSource(name)
+}
+
+// Start — время начала из манифеста, для старых файлов — из имени
+// файла или, если его там нет, время изменения файла.
+func (e Entry) Start() time.Time {
+	if e.Manifest != nil {
+		return e.Manifest.Start
+	}
+	if n := len(e.ID) - len(IDTimeLayout); n > 0 && e.ID[n-1] == '_' {
+		if t, err := time.ParseInLocation(IDTimeLayout, e.ID[n:], time.Local); err == nil {
+			return t
+		}
+	}
+	if info, err := os.Stat(e.DataPath); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// Find ищет эксперимент по ID или по однозначному началу ID.
+func (c Catalog) Find(id string) (Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return Entry{}, err
+	}
+	var found []Entry
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+		if strings.HasPrefix(e.ID, id) {
+			found = append(found, e)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Entry{}, fmt.Errorf("эксперимент %q не найден в %s", id, c.Dir)
+	case 1:
+		return found[0], nil
+	}
+	ids := make([]string, len(found))
+	for i, e := range found {
+		ids[i] = e.ID
+	}
+	return Entry{}, fmt.Errorf("эксперимент %q неоднозначен: %s", id, strings.Join(ids, ", "))
+}
+
+// Files возвращает существующие файлы эксперимента.
+func (e Entry) Files() []string {
+	files := []string{e.DataPath}
+	if m := e.Manifest; m != nil {
+		dir := filepath.Dir(e.ManifestPath)
+		files = files[:0]
+		for _, s := range m.AllSources() {
+			for _, f := range s.Files() {
+				files = append(files, filepath.Join(dir, f))
+			}
+		}
+		if m.MergedFile != "" {
+			files = append(files, filepath.Join(dir, m.MergedFile))
+		}
+		if m.ReportFile != "" {
+			files = append(files, filepath.Join(dir, m.ReportFile))
+		}
+	}
+	var existing []string
+	for _, f := range append(files, e.ManifestPath, JournalPath(e.ManifestPath)) {
+		if _, err := os.Stat(f); err == nil {
+			existing = append(existing, f)
+		}
+	}
+	return existing
+}
+
+// Running сообщает, что эксперимент не завершён: идёт запись или
+// программа была прервана.
+func (e Entry) Running() bool {
+	return e.Manifest != nil && e.Manifest.Status == StatusRunning
+}
+
+// Archive переносит файлы эксперимента в подкаталог ArchiveDir.
+func (c Catalog) Archive(e Entry) error {
+	dir := filepath.Join(c.Dir, ArchiveDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, f := range e.Files() {
+		if err := os.Rename(f, filepath.Join(dir, filepath.Base(f))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete удаляет файлы эксперимента.
+func (c Catalog) Delete(e Entry) error {
+	for _, f := range e.Files() {
+		if err := os.Remove(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
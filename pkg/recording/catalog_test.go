@@ -0,0 +1,69 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCatalogList(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Date(2025, 8, 29, 3, 6, 48, 0, time.UTC)
+	m := &Manifest{SchemaVersion: SchemaVersion, Name: "Погружение", Start: start}
+	m.DataFile = "Погружение_20250829_030648.csv"
+	if err := WriteManifest(filepath.Join(dir, "Погружение_20250829_030648.json"), m); err != nil {
+		t.Fatal(err)
+	}
+	write(m.DataFile, "timestamp,source_ts,seq,raw,event\n")
+	// Старые файлы без манифеста: время начала — из имени или время
+	// изменения файла.
+	write("test_20250829_030616.csv", "timestamp,parameter,value,unit\n")
+	write("без_времени.csv", "timestamp,parameter,value,unit\n")
+	mtime := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "без_времени.csv"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Catalog{Dir: dir}.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id    string
+		start time.Time
+		man   bool
+	}{
+		{"test_20250829_030616", time.Date(2025, 8, 29, 3, 6, 16, 0, time.Local), false},
+		{"Погружение_20250829_030648", start, true},
+		{"без_времени", mtime, false},
+	}
+	if len(entries) != len(tests) {
+		t.Fatalf("экспериментов %d, ожидается %d", len(entries), len(tests))
+	}
+	// Порядок зависит от часового пояса: сверяем по ID.
+	for _, tt := range tests {
+		e, err := Catalog{Dir: dir}.Find(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !e.Start().Equal(tt.start) || (e.Manifest != nil) != tt.man {
+			t.Errorf("%s: начало %v, манифест %v", tt.id, e.Start(), e.Manifest != nil)
+		}
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Start().Before(entries[i-1].Start()) {
+			t.Errorf("список не упорядочен по началу: %s раньше %s", entries[i-1].ID, entries[i].ID)
+		}
+	}
+
+	if _, err := (Catalog{Dir: dir}).Find("нет"); err == nil {
+		t.Error("найден несуществующий эксперимент")
+	}
+}
@@ -0,0 +1,173 @@
+// Пакет recording описывает формат файлов экспериментов cmd/operator.
+//
+// Схема CSV, версия 3 (SchemaVersion). Разделитель — запятая, значения с
+// запятыми, кавычками и переводами строк заключаются в кавычки (RFC 4180).
+// Первая строка данных — заголовок:
+//
+//	timestamp  время приёма строки оператором, RFC 3339 с миллисекундами
+//	source_ts  метка времени моста (секундная точность), пусто если нет
+//	seq        порядковый номер принятой строки, с 1; пусто у строк-событий
+//	P ... T2   значения полей прошивки (sensor.Fields), пусто если строка
+//	           не разобрана
+//	P_qc ...   флаги качества полей по QARTOD (см. пакет qc), только если
+//	           при записи проверялось качество (с версии 3)
+//	raw        строка в том виде, в каком она пришла от моста
+//	event      событие эксперимента, пусто у обычных строк (с версии 2)
+//
+// Строки, которые не удалось разобрать (баннер прошивки, сообщения
+// моста, мусор в линии), записываются с пустыми полями и заполненным raw.
+//
+// Строки-события (маркеры) не содержат данных, в event записывается
+// "<вид>" или "<вид>: <текст>":
+//
+//	gap_start: <причина>  потеряна связь с источником
+//	gap_end               связь восстановлена
+//	pause                 оператор приостановил запись: следующие строки
+//	                      пишутся, но исключаются из обработки
+//	resume                запись продолжена
+//	mark: <текст>         отметка оператора ("остановка лебёдки на 50 м")
+//	alarm: <правило>=<состояние> <условие>
+//	                      смена состояния тревоги моста (см. пакет alarm)
+//
+// CSV не содержит ничего, кроме заголовка и строк данных; метаданные
+// эксперимента лежат рядом в JSON-манифесте (см. Manifest).
+package recording
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// SchemaVersion — версия схемы CSV. Увеличивается при любом изменении
+// состава или смысла столбцов.
+const SchemaVersion = 3
+
+// TimeLayout — формат столбцов timestamp и source_ts.
+const TimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// Столбцы, не зависящие от полей прошивки.
+const (
+	ColTimestamp = "timestamp"
+	ColSourceTS  = "source_ts"
+	ColSeq       = "seq"
+	ColRaw       = "raw"
+	ColEvent     = "event"
+)
+
+// QCSuffix — окончание имени столбца флагов качества поля.
+const QCSuffix = "_qc"
+
+// Виды событий в столбце event.
+const (
+	EventGapStart = "gap_start"
+	EventGapEnd   = "gap_end"
+	EventPause    = "pause"
+	EventResume   = "resume"
+	EventMark     = "mark"
+	EventAlarm    = "alarm"
+)
+
+// FormatEvent собирает значение столбца event.
+func FormatEvent(kind, text string) string {
+	if text == "" {
+		return kind
+	}
+	return kind + ": " + text
+}
+
+// ParseEvent разделяет значение столбца event на вид и текст.
+func ParseEvent(event string) (kind, text string) {
+	kind, text, _ = strings.Cut(event, ": ")
+	return kind, text
+}
+
+// Header строит заголовок для заданного набора полей; withQC добавляет
+// столбцы флагов качества.
+func Header(fields []sensor.Field, withQC bool) []string {
+	h := []string{ColTimestamp, ColSourceTS, ColSeq}
+	for _, f := range fields {
+		h = append(h, f.Name)
+	}
+	if withQC {
+		for _, f := range fields {
+			h = append(h, f.Name+QCSuffix)
+		}
+	}
+	return append(h, ColRaw, ColEvent)
+}
+
+// Row — одна строка записи: принятая строка или событие.
+type Row struct {
+	Time   time.Time
+	Seq    int64
+	Sample sensor.Sample
+	Raw    string
+	Event  string
+}
+
+// Writer пишет строки в CSV по схеме SchemaVersion.
+type Writer struct {
+	// QC — писать столбцы флагов качества (Sample.Flags); задаётся до
+	// WriteHeader.
+	QC bool
+
+	csv    *csv.Writer
+	fields []sensor.Field
+	record []string
+}
+
+func NewWriter(w io.Writer, fields []sensor.Field) *Writer {
+	return &Writer{csv: csv.NewWriter(w), fields: fields}
+}
+
+// WriteHeader пишет строку заголовка.
+func (w *Writer) WriteHeader() error {
+	return w.csv.Write(Header(w.fields, w.QC))
+}
+
+func (w *Writer) Write(r Row) error {
+	rec := w.record[:0]
+	seq := ""
+	if r.Seq > 0 {
+		seq = strconv.FormatInt(r.Seq, 10)
+	}
+	rec = append(rec, r.Time.Format(TimeLayout), formatTime(r.Sample.SourceTime), seq)
+	for _, f := range w.fields {
+		v, ok := r.Sample.Value(f.Name)
+		if ok {
+			rec = append(rec, strconv.FormatFloat(v, 'f', -1, 64))
+		} else {
+			rec = append(rec, "")
+		}
+	}
+	if w.QC {
+		for _, f := range w.fields {
+			if flag, ok := r.Sample.Flags[f.Name]; ok {
+				rec = append(rec, strconv.Itoa(int(flag)))
+			} else {
+				rec = append(rec, "")
+			}
+		}
+	}
+	rec = append(rec, r.Raw, r.Event)
+	w.record = rec
+	return w.csv.Write(rec)
+}
+
+// Flush сбрасывает буфер в нижележащий io.Writer.
+func (w *Writer) Flush() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(TimeLayout)
+}
@@ -0,0 +1,133 @@
+package recording
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+var (
+	t0    = time.Date(2025, 8, 29, 3, 6, 48, 120e6, time.UTC)
+	stamp = time.Date(2025, 8, 29, 3, 6, 48, 0, time.UTC)
+)
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		qc   bool
+		rows []Row
+	}{
+		{"строка данных", false, []Row{{
+			Time: t0, Seq: 1, Raw: "P:1013.25, T1:20.1, Depth:0, Alt:0.5, T2:20.05",
+			Sample: sensor.Sample{
+				Text:   "P:1013.25, T1:20.1, Depth:0, Alt:0.5, T2:20.05",
+				Values: map[string]float64{"P": 1013.25, "T1": 20.1, "Depth": 0, "Alt": 0.5, "T2": 20.05},
+			},
+		}}},
+		{"метка моста", false, []Row{{
+			Time: t0, Seq: 7, Raw: "20250829030648\tP:1000.5",
+			Sample: sensor.Sample{SourceTime: stamp, Text: "P:1000.5", Values: map[string]float64{"P": 1000.5}},
+		}}},
+		{"неразобранная строка с кавычками и запятыми", false, []Row{{
+			Time: t0, Seq: 2, Raw: `MS5837 Init failed, retry in "1 sec"`,
+			Sample: sensor.Sample{Text: `MS5837 Init failed, retry in "1 sec"`},
+		}}},
+		{"события", false, []Row{
+			{Time: t0, Event: FormatEvent(EventGapStart, "EOF, переподключение")},
+			{Time: t0.Add(time.Second), Event: EventGapEnd},
+			{Time: t0.Add(2 * time.Second), Event: FormatEvent(EventMark, "остановка лебёдки\nна 50 м")},
+		}},
+		{"флаги качества", true, []Row{{
+			Time: t0, Seq: 3, Raw: "P:1013.25, T2:99",
+			Sample: sensor.Sample{
+				Text:   "P:1013.25, T2:99",
+				Values: map[string]float64{"P": 1013.25, "T2": 99},
+				Flags:  map[string]qc.Flag{"P": qc.Pass, "T2": qc.Fail},
+			},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, sensor.Fields)
+			w.QC = tt.qc
+			if err := w.WriteHeader(); err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range tt.rows {
+				if err := w.Write(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := NewReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(r.Header(), Header(sensor.Fields, tt.qc)) {
+				t.Errorf("заголовок %q", r.Header())
+			}
+			if r.HasQC() != tt.qc {
+				t.Errorf("HasQC() = %v", r.HasQC())
+			}
+			for i, want := range tt.rows {
+				got, err := r.Read()
+				if err != nil {
+					t.Fatalf("строка %d: %v", i+1, err)
+				}
+				if !got.Time.Equal(want.Time) || !got.Sample.SourceTime.Equal(want.Sample.SourceTime) {
+					t.Errorf("строка %d: время %v, %v", i+1, got.Time, got.Sample.SourceTime)
+				}
+				got.Time, got.Sample.SourceTime = want.Time, want.Sample.SourceTime
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("строка %d:\n%+v\nожидается\n%+v", i+1, got, want)
+				}
+			}
+			if _, err := r.Read(); err != io.EOF {
+				t.Errorf("после последней строки: %v", err)
+			}
+		})
+	}
+}
+
+func TestReaderPreamble(t *testing.T) {
+	const data = "# Эксперимент: бассейн\n\n# Начало: 2025-08-29\n" +
+		"timestamp,source_ts,seq,P,raw,event\n" +
+		"2025-08-29T03:06:48.120Z,,1,1013.25,P:1013.25,\n" +
+		"Строк: 1\n"
+	r, err := NewReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"# Эксперимент: бассейн", "# Начало: 2025-08-29"}; !reflect.DeepEqual(r.Preamble, want) {
+		t.Errorf("Preamble = %q", r.Preamble)
+	}
+	if want := []string{"P"}; !reflect.DeepEqual(r.Fields(), want) {
+		t.Errorf("Fields() = %q", r.Fields())
+	}
+	if _, err := r.Read(); err != nil {
+		t.Fatal(err)
+	}
+	// Текстовый хвост с другим числом столбцов завершает чтение.
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("хвост: %v, ожидается io.EOF", err)
+	}
+
+	// Недописанная последняя строка идущей записи — тоже конец данных.
+	r = mustReader(t, "timestamp,seq,P,raw,event\n2025-08-29T03:06:48.120Z,1,1013.25,x,\n2025-08-29T03:06:4")
+	if _, err := r.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("недописанная строка: %v, ожидается io.EOF", err)
+	}
+}
@@ -0,0 +1,92 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+)
+
+// Журнал записи — файл "<имя>.journal" рядом с манифестом. Пока идёт
+// эксперимент, оператор дописывает в него строки JSON: начало записи и
+// контрольные точки после сброса данных на диск. По журналу видно, жив
+// ли записывающий процесс и докуда данные точно сохранены. После
+// нормального завершения журнал удаляется.
+
+// События журнала.
+const (
+	JournalStart = "start"
+	JournalSync  = "sync"
+)
+
+// JournalEntry — строка журнала.
+type JournalEntry struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	PID   int       `json:"pid,omitempty"`
+	Host  string    `json:"host,omitempty"`
+	// Lines — строк каждого источника, сброшенных на диск.
+	Lines []int64 `json:"lines,omitempty"`
+}
+
+// Journal — открытый на дозапись журнал.
+type Journal struct {
+	f *os.File
+}
+
+// JournalPath возвращает путь журнала для манифеста.
+func JournalPath(manifestPath string) string {
+	return strings.TrimSuffix(manifestPath, ".json") + ".journal"
+}
+
+// CreateJournal открывает журнал на дозапись, создавая его при
+// необходимости.
+func CreateJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{f: f}, nil
+}
+
+// Append дописывает строку и сбрасывает журнал на диск.
+func (j *Journal) Append(e JournalEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// Close закрывает журнал.
+func (j *Journal) Close() error { return j.f.Close() }
+
+// Remove закрывает и удаляет журнал.
+func (j *Journal) Remove() error {
+	j.f.Close()
+	return os.Remove(j.f.Name())
+}
+
+// ReadJournal читает журнал. Недописанная при сбое последняя строка
+// пропускается.
+func ReadJournal(path string) ([]JournalEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e JournalEntry
+		if json.Unmarshal(sc.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, sc.Err()
+}
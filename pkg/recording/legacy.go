@@ -0,0 +1,85 @@
+package recording
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// Файлы, записанные оператором до появления схемы CSV, начинаются с
+// метаданных, за которыми идёт заголовок "timestamp,parameter,value,unit"
+// (или "timestamp\tvalue") и строки
+//
+//	2025-08-29 03:05:28,raw_data,<строка без запятых>,
+//	20250829030540\t<строка с запятыми как есть>
+//
+// а после пустой строки — время окончания и длительность.
+
+// legacyHeaders — заголовки старых файлов.
+var legacyHeaders = []string{"timestamp,parameter,value,unit", "timestamp\tvalue"}
+
+// LegacyTimeLayout — формат времени в метаданных и строках старых файлов
+// (местное время).
+const LegacyTimeLayout = "2006-01-02 15:04:05"
+
+func isLegacyHeader(line string) bool {
+	for _, h := range legacyHeaders {
+		if line == h {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLegacyLine разбирает строку данных старого файла. Time — время
+// оператора, а если его нет, метка моста; false — строка не похожа на
+// строку данных (например, текстовый хвост файла).
+func ParseLegacyLine(line string) (Row, bool) {
+	var row Row
+	if ts, rest, ok := strings.Cut(line, ",raw_data,"); ok {
+		t, err := time.ParseInLocation(LegacyTimeLayout, strings.TrimSpace(ts), time.Local)
+		if err != nil {
+			return row, false
+		}
+		row.Time, line = t, strings.TrimSuffix(rest, ",")
+	}
+	row.Raw = line
+	row.Sample, _ = sensor.ParseLine(line)
+	if row.Time.IsZero() {
+		row.Time = row.Sample.SourceTime
+	}
+	return row, !row.Time.IsZero()
+}
+
+// setLegacy готовит чтение строк старого файла: поля — поля прошивки.
+func (r *Reader) setLegacy(br *bufio.Reader) {
+	r.setHeader(Header(sensor.Fields, false))
+	r.legacy = br
+}
+
+// Legacy сообщает, что файл записан до появления схемы CSV.
+func (r *Reader) Legacy() bool { return r.legacy != nil }
+
+// readLegacy читает строку старого файла. Пустые строки пропускаются,
+// первая строка не в формате данных (время окончания) завершает чтение.
+func (r *Reader) readLegacy() (Row, error) {
+	for {
+		line, err := r.legacy.ReadString('\n')
+		if line == "" && err != nil {
+			return Row{}, err
+		}
+		r.line++
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		row, ok := ParseLegacyLine(line)
+		if !ok {
+			return Row{}, io.EOF
+		}
+		return row, nil
+	}
+}
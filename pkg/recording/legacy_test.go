@@ -0,0 +1,102 @@
+package recording
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReaderLegacy(t *testing.T) {
+	const data = "Название эксперимента: test\n" +
+		"Описание: бассейн\n" +
+		"Время начала: 2025-08-29 03:06:16\n" +
+		"\n" +
+		"timestamp,parameter,value,unit\n" +
+		"2025-08-29 03:06:20,raw_data,Подключение к COM-порту /dev/ttyUSB0 установлено. Ожидание данных...,\n" +
+		"20250829030621\tP:1013.25, T1:20.10, Depth:0.00, Alt:0.50, T2:20.05\n" +
+		"2025-08-29 03:06:22,raw_data,20250829030622\tStarting,\n" +
+		"\n" +
+		"Время окончания: 2025-08-29 03:06:30\n" +
+		"Длительность эксперимента: 14.2s\n"
+	at := func(s int) time.Time { return time.Date(2025, 8, 29, 3, 6, s, 0, time.Local) }
+
+	r, err := NewReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Legacy() {
+		t.Fatal("старый файл не распознан")
+	}
+	if len(r.Preamble) != 3 || len(r.Fields()) != 5 {
+		t.Errorf("Preamble = %q, Fields() = %q", r.Preamble, r.Fields())
+	}
+	tests := []struct {
+		time, source time.Time
+		text         string
+		values       int
+	}{
+		{at(20), time.Time{}, "Подключение к COM-порту /dev/ttyUSB0 установлено. Ожидание данных...", 0},
+		{at(21), at(21), "P:1013.25, T1:20.10, Depth:0.00, Alt:0.50, T2:20.05", 5},
+		{at(22), at(22), "Starting", 0},
+	}
+	for i, want := range tests {
+		row, err := r.Read()
+		if err != nil {
+			t.Fatalf("строка %d: %v", i+1, err)
+		}
+		if !row.Time.Equal(want.time) || !row.Sample.SourceTime.Equal(want.source) ||
+			row.Sample.Text != want.text || len(row.Sample.Values) != want.values {
+			t.Errorf("строка %d: %v, %v, %q, %v", i+1, row.Time, row.Sample.SourceTime, row.Sample.Text, row.Sample.Values)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("хвост файла: %v, ожидается io.EOF", err)
+	}
+
+	sum, err := Summarize(mustReader(t, data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Rows != 3 || sum.Unparsed != 2 || sum.Malformed != 0 || sum.Fields[0].Mean != 1013.25 {
+		t.Errorf("сводка %+v", sum)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"нет заголовка", "P:1013.25\n", "заголовок CSV не найден"},
+		{"время", "timestamp,seq,raw,event\n03:06:48,1,x,\n", "строка 2"},
+		{"номер", "# Эксперимент\n\ntimestamp,seq,raw,event\n2025-08-29T03:06:48.120Z,один,x,\n", "строка 4"},
+		{"значение", "timestamp,seq,P,raw,event\n2025-08-29T03:06:48.120Z,1,1013.25,x,\n" +
+			"2025-08-29T03:06:49.120Z,2,1013..25,x,\n", "строка 3: поле P"},
+		{"после многострочного поля", "timestamp,seq,P,raw,event\n" +
+			"2025-08-29T03:06:48.120Z,,,,\"mark: две\nстроки\"\n" +
+			"2025-08-29T03:06:49.120Z,2,?,x,\n", "строка 4: поле P"},
+		{"повреждённая строка в середине", "timestamp,seq,P,raw,event\n" +
+			"2025-08-29T03:06:48.120Z,1,1013.25,x,\n2025-08-29T03:06:4\n" +
+			"2025-08-29T03:06:50.120Z,3,1013.25,x,\n", "строка 3: 1 столбцов вместо 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(strings.NewReader(tt.data))
+			for err == nil {
+				_, err = r.Read()
+			}
+			if err == io.EOF || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ошибка %v, ожидается %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func mustReader(t *testing.T, data string) *Reader {
+	t.Helper()
+	r, err := NewReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
@@ -0,0 +1,278 @@
+package recording
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/derive"
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// Состояния эксперимента в манифесте.
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	// StatusRecovered — см. Recover.
+)
+
+// Причины остановки эксперимента (Manifest.StopReason).
+const (
+	StopCommand   = "command"   // команда stop
+	StopSignal    = "signal"    // SIGINT/SIGTERM
+	StopDuration  = "duration"  // истекла длительность
+	StopUntil     = "until"     // наступило время остановки
+	StopSamples   = "samples"   // записано заданное число строк
+	StopCondition = "condition" // выполнено условие по полям
+)
+
+// Manifest — машиночитаемое описание эксперимента. Лежит рядом с CSV
+// под тем же именем с расширением .json.
+//
+// У эксперимента с одним источником его описание (Source) встроено в
+// манифест. При нескольких источниках каждый пишет свой файл данных
+// "<имя>.<источник>.csv" и описан в Sources, а встроенный Source пуст.
+type Manifest struct {
+	SchemaVersion int        `json:"schema_version"`
+	Status        string     `json:"status"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	Operator      string     `json:"operator,omitempty"`
+	Host          string     `json:"host,omitempty"`
+	Start         time.Time  `json:"start"`
+	End           *time.Time `json:"end,omitempty"`
+	DurationSec   float64    `json:"duration_s"`
+	StopReason    string     `json:"stop_reason,omitempty"`
+	Source
+	Sources []Source   `json:"sources,omitempty"`
+	Pauses  []Interval `json:"pauses,omitempty"`
+	Notes   []Note     `json:"notes,omitempty"`
+	// MergedFile — таблица всех источников, выровненная по времени
+	// (см. export.Merge), если её просили построить.
+	MergedFile string `json:"merged_file,omitempty"`
+	// ReportFile — HTML-отчёт об эксперименте, если его строили.
+	ReportFile string `json:"report_file,omitempty"`
+	// QC — как проверялось качество данных; nil, если не проверялось
+	// (тогда в файлах нет столбцов флагов).
+	QC *QCInfo `json:"qc,omitempty"`
+	// Derived — параметры расчёта производных полей (DepthCalc и др.);
+	// nil, если они не рассчитывались.
+	Derived *derive.Config `json:"derived,omitempty"`
+}
+
+// Кто ставил флаги качества (QCInfo.By).
+const (
+	QCByOperator = "operator"
+	QCByBridge   = "bridge"
+)
+
+// QCInfo — проверка качества данных эксперимента.
+type QCInfo struct {
+	By string `json:"by"`
+	// Rules — правила оператора; у флагов моста не известны.
+	Rules *qc.Rules `json:"rules,omitempty"`
+}
+
+// String описывает, кто ставил флаги качества.
+func (q *QCInfo) String() string {
+	if q.By == QCByBridge {
+		return "флаги QARTOD от моста"
+	}
+	return "флаги QARTOD по правилам оператора"
+}
+
+// Source — источник данных эксперимента и его файл.
+type Source struct {
+	// Name — имя источника; пусто у единственного источника.
+	Name        string   `json:"name,omitempty"`
+	Address     string   `json:"source"`
+	DataFile    string   `json:"data_file"`
+	Lines       int64    `json:"lines"`
+	ParseErrors int64    `json:"parse_errors"`
+	SHA256      string   `json:"sha256,omitempty"`
+	Fields      []Column `json:"fields"`
+	// Segments — сегменты файла данных при ротации; тогда DataFile —
+	// общее начало их имён, а SHA256 считается по каждому сегменту.
+	Segments []Segment `json:"segments,omitempty"`
+	Gaps     []Gap     `json:"gaps,omitempty"`
+	// Stats — сводка по данным, считается при завершении эксперимента.
+	Stats *Summary `json:"stats,omitempty"`
+}
+
+// AllSources возвращает источники эксперимента: Sources или встроенный
+// Source, если источник один.
+func (m *Manifest) AllSources() []*Source {
+	if len(m.Sources) == 0 {
+		return []*Source{&m.Source}
+	}
+	sources := make([]*Source, len(m.Sources))
+	for i := range m.Sources {
+		sources[i] = &m.Sources[i]
+	}
+	return sources
+}
+
+// FindSource ищет источник по имени; пустое имя — первый источник.
+func (m *Manifest) FindSource(name string) (*Source, error) {
+	sources := m.AllSources()
+	if name == "" {
+		return sources[0], nil
+	}
+	names := make([]string, len(sources))
+	for i, s := range sources {
+		if s.Name == name {
+			return s, nil
+		}
+		names[i] = s.Name
+	}
+	return nil, fmt.Errorf("источник %q не найден (есть: %s)", name, strings.Join(names, ", "))
+}
+
+// CountTotals при нескольких источниках сводит их счётчики строк во
+// встроенный Source, который показывает список экспериментов.
+func (m *Manifest) CountTotals() {
+	if len(m.Sources) == 0 {
+		return
+	}
+	m.Lines, m.ParseErrors = 0, 0
+	for _, s := range m.Sources {
+		m.Lines += s.Lines
+		m.ParseErrors += s.ParseErrors
+	}
+}
+
+// Gap — перерыв в записи из-за потери связи с источником.
+type Gap struct {
+	Start  time.Time  `json:"start"`
+	End    *time.Time `json:"end,omitempty"`
+	Reason string     `json:"reason"`
+}
+
+// OpenGap возвращает незакрытый перерыв или nil.
+func (s *Source) OpenGap() *Gap {
+	if n := len(s.Gaps); n > 0 && s.Gaps[n-1].End == nil {
+		return &s.Gaps[n-1]
+	}
+	return nil
+}
+
+// Interval — промежуток времени; End пуст, пока промежуток не закрыт.
+type Interval struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// Contains сообщает, попадает ли t в промежуток.
+func (i Interval) Contains(t time.Time) bool {
+	return !t.Before(i.Start) && (i.End == nil || t.Before(*i.End))
+}
+
+// OpenPause возвращает незакрытую паузу или nil.
+func (m *Manifest) OpenPause() *Interval {
+	if n := len(m.Pauses); n > 0 && m.Pauses[n-1].End == nil {
+		return &m.Pauses[n-1]
+	}
+	return nil
+}
+
+// Excluded сообщает, принята ли строка со временем t во время паузы.
+func (m *Manifest) Excluded(t time.Time) bool {
+	for _, p := range m.Pauses {
+		if p.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Note — заметка оператора, сделанная во время эксперимента.
+type Note struct {
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+}
+
+// Column описывает числовой столбец CSV.
+type Column struct {
+	Name         string `json:"name"`
+	Unit         string `json:"unit"`
+	Description  string `json:"description,omitempty"`
+	StandardName string `json:"standard_name,omitempty"`
+}
+
+// Columns описывает поля прошивки для манифеста.
+func Columns(fields []sensor.Field) []Column {
+	cols := make([]Column, len(fields))
+	for i, f := range fields {
+		cols[i] = Column{Name: f.Name, Unit: f.Unit, Description: f.Description, StandardName: f.StandardName}
+	}
+	return cols
+}
+
+// ManifestPath возвращает путь манифеста для файла данных.
+func ManifestPath(dataFile string) string {
+	return strings.TrimSuffix(dataFile, filepath.Ext(dataFile)) + ".json"
+}
+
+// Finish отмечает окончание эксперимента. Незакрытые перерывы и пауза
+// заканчиваются вместе с экспериментом.
+func (m *Manifest) Finish(end time.Time) {
+	for _, s := range m.AllSources() {
+		if g := s.OpenGap(); g != nil {
+			g.End = &end
+		}
+	}
+	if p := m.OpenPause(); p != nil {
+		p.End = &end
+	}
+	m.End = &end
+	m.DurationSec = end.Sub(m.Start).Seconds()
+	m.Status = StatusCompleted
+}
+
+// WriteManifest атомарно записывает манифест: сначала во временный файл,
+// затем переименованием, чтобы читатель не увидел половину JSON.
+func WriteManifest(path string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadManifest читает манифест из файла.
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FileSHA256 считает SHA-256 файла в шестнадцатеричном виде.
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
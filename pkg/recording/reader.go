@@ -0,0 +1,194 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// Reader читает CSV-файл эксперимента. Строки до заголовка (метаданные
+// старых файлов) доступны через Preamble. Файлы, записанные до
+// появления схемы, читаются как файлы с полями прошивки (см. Legacy).
+type Reader struct {
+	csv    *csv.Reader
+	legacy *bufio.Reader
+	line   int // строк файла прочитано до csv (или legacy)
+	header []string
+	fields []string
+	qc     []string // поля со столбцами флагов качества
+	index  map[string]int
+
+	// Открытый файл и следующие сегменты (см. OpenSource).
+	closer io.Closer
+	next   []string
+
+	// Preamble — непустые строки перед заголовком.
+	Preamble []string
+}
+
+// NewReader ищет строку заголовка и готовит чтение строк данных.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	rd := &Reader{}
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			rd.line++
+		}
+		trimmed := strings.TrimSpace(line)
+		if isLegacyHeader(trimmed) {
+			rd.setLegacy(br)
+			return rd, nil
+		}
+		if strings.HasPrefix(trimmed, ColTimestamp+",") {
+			header, herr := csv.NewReader(strings.NewReader(trimmed)).Read()
+			if herr != nil {
+				return nil, fmt.Errorf("recording: заголовок: %w", herr)
+			}
+			rd.setHeader(header)
+			break
+		}
+		if trimmed != "" {
+			rd.Preamble = append(rd.Preamble, trimmed)
+		}
+		if err == io.EOF {
+			return nil, fmt.Errorf("recording: заголовок CSV не найден")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	rd.csv = csv.NewReader(br)
+	rd.csv.FieldsPerRecord = -1
+	return rd, nil
+}
+
+func (r *Reader) setHeader(header []string) {
+	r.header = header
+	r.index = make(map[string]int, len(header))
+	for i, h := range header {
+		r.index[h] = i
+		switch h {
+		case ColTimestamp, ColSourceTS, ColSeq, ColRaw, ColEvent:
+		default:
+			if name, ok := strings.CutSuffix(h, QCSuffix); ok {
+				r.qc = append(r.qc, name)
+			} else {
+				r.fields = append(r.fields, h)
+			}
+		}
+	}
+}
+
+// shortRecord решает, чем считать только что прочитанную строку из n
+// столбцов: концом данных или ошибкой.
+func (r *Reader) shortRecord(n int) error {
+	if len(r.Preamble) > 0 {
+		return io.EOF
+	}
+	line, _ := r.csv.FieldPos(0)
+	line += r.line
+	if _, err := r.csv.Read(); err == io.EOF {
+		return io.EOF
+	}
+	return fmt.Errorf("recording: строка %d: %d столбцов вместо %d", line, n, len(r.header))
+}
+
+// Header возвращает заголовок файла.
+func (r *Reader) Header() []string { return r.header }
+
+// Fields возвращает имена числовых полей в порядке столбцов.
+func (r *Reader) Fields() []string { return r.fields }
+
+// HasQC сообщает, что в файле есть столбцы флагов качества.
+func (r *Reader) HasQC() bool { return len(r.qc) > 0 }
+
+// Read читает следующую строку. В конце данных возвращает io.EOF.
+// Строка с другим числом столбцов завершает чтение, только если это
+// текстовый хвост файла с метаданными перед заголовком (схема 1) или
+// недописанная последняя строка идущей записи; в середине файла это
+// ошибка с номером строки.
+func (r *Reader) Read() (Row, error) {
+	if r.legacy != nil {
+		return r.readLegacy()
+	}
+	rec, err := r.csv.Read()
+	if err == nil && len(rec) != len(r.header) {
+		err = r.shortRecord(len(rec))
+	}
+	if err == io.EOF {
+		ok, nerr := r.nextSegment()
+		if nerr != nil {
+			return Row{}, nerr
+		}
+		if ok {
+			return r.Read()
+		}
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	var row Row
+	line, _ := r.csv.FieldPos(0)
+	line += r.line // номер строки файла, а не записи CSV
+	get := func(name string) string {
+		if i, ok := r.index[name]; ok {
+			return rec[i]
+		}
+		return ""
+	}
+	if row.Time, err = time.Parse(TimeLayout, get(ColTimestamp)); err != nil {
+		return row, fmt.Errorf("recording: строка %d: %w", line, err)
+	}
+	if ts := get(ColSourceTS); ts != "" {
+		if row.Sample.SourceTime, err = time.Parse(TimeLayout, ts); err != nil {
+			return row, fmt.Errorf("recording: строка %d: %w", line, err)
+		}
+	}
+	if seq := get(ColSeq); seq != "" {
+		if row.Seq, err = strconv.ParseInt(seq, 10, 64); err != nil {
+			return row, fmt.Errorf("recording: строка %d: %w", line, err)
+		}
+	}
+	row.Raw = get(ColRaw)
+	row.Event = get(ColEvent)
+	_, row.Sample.Text = sensor.SplitBridgeStamp(row.Raw)
+	row.Sample.Text, _ = sensor.SplitFlags(row.Sample.Text)
+	for _, f := range r.fields {
+		s := get(f)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return row, fmt.Errorf("recording: строка %d: поле %s: %w", line, f, err)
+		}
+		if row.Sample.Values == nil {
+			row.Sample.Values = make(map[string]float64, len(r.fields))
+		}
+		row.Sample.Values[f] = v
+	}
+	for _, f := range r.qc {
+		s := get(f + QCSuffix)
+		if s == "" {
+			continue
+		}
+		flag, err := qc.ParseFlag(s)
+		if err != nil {
+			return row, fmt.Errorf("recording: строка %d: %w", line, err)
+		}
+		if row.Sample.Flags == nil {
+			row.Sample.Flags = make(map[string]qc.Flag, len(r.qc))
+		}
+		row.Sample.Flags[f] = flag
+	}
+	return row, nil
+}
@@ -0,0 +1,182 @@
+package recording
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StatusRecovered — состояние эксперимента, который не был завершён
+// (сбой, потеря питания) и закрыт восстановлением по файлам данных.
+const StatusRecovered = "recovered"
+
+// StopCrash — причина остановки восстановленного эксперимента.
+const StopCrash = "crash"
+
+// LastActivity возвращает время последней записи эксперимента: самое
+// позднее из последней строки журнала и времени изменения файлов.
+func (e Entry) LastActivity() time.Time {
+	var last time.Time
+	if entries, err := ReadJournal(JournalPath(e.ManifestPath)); err == nil && len(entries) > 0 {
+		last = entries[len(entries)-1].Time
+	}
+	for _, f := range e.Files() {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last
+}
+
+// Abandoned сообщает, что эксперимент не завершён, а запись в него не
+// ведётся дольше staleAfter: программа, скорее всего, аварийно
+// прекратила работу.
+func (e Entry) Abandoned(now time.Time, staleAfter time.Duration) bool {
+	return e.Running() && now.Sub(e.LastActivity()) > staleAfter
+}
+
+// Recover завершает прерванный эксперимент по его файлам: обрезает
+// недописанную последнюю строку, пересчитывает строки, сводку и
+// контрольные суммы, закрывает перерывы и паузу и помечает манифест как
+// восстановленный. Окончание — последняя сохранённая строка данных или
+// контрольная точка журнала. Журнал удаляется.
+func Recover(e Entry) error {
+	m := e.Manifest
+	dir := filepath.Dir(e.ManifestPath)
+	journal := JournalPath(e.ManifestPath)
+
+	end := m.Start
+	if entries, err := ReadJournal(journal); err == nil && len(entries) > 0 {
+		end = entries[len(entries)-1].Time
+	}
+	for _, s := range m.AllSources() {
+		findCompressed(dir, s)
+		files := s.Files()
+		last, err := repairTail(filepath.Join(dir, files[len(files)-1]))
+		if err != nil {
+			return err
+		}
+		if last.After(end) {
+			end = last
+		}
+		sum, err := SummarizeSource(dir, s, m.Excluded)
+		if err != nil {
+			return err
+		}
+		s.Lines, s.ParseErrors, s.Stats = sum.Rows, sum.Malformed, sum
+		if err := checksum(dir, s); err != nil {
+			return err
+		}
+	}
+	for _, s := range m.AllSources() {
+		if n := len(s.Segments); n > 0 && s.Segments[n-1].End == nil {
+			seg := &s.Segments[n-1]
+			seg.End = &end
+			seg.Lines = s.Lines
+			for _, prev := range s.Segments[:n-1] {
+				seg.Lines -= prev.Lines
+			}
+		}
+	}
+
+	m.CountTotals()
+	m.Finish(end)
+	m.Status = StatusRecovered
+	m.StopReason = StopCrash
+	if err := WriteManifest(e.ManifestPath, m); err != nil {
+		return err
+	}
+	if err := os.Remove(journal); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// findCompressed исправляет имена сегментов, сжатых перед сбоем, но не
+// успевших попасть в манифест.
+func findCompressed(dir string, s *Source) {
+	for i := range s.Segments {
+		seg := &s.Segments[i]
+		if _, err := os.Stat(filepath.Join(dir, seg.File)); err == nil {
+			continue
+		}
+		for _, ext := range compressedExt {
+			if _, err := os.Stat(filepath.Join(dir, seg.File+ext)); err == nil {
+				seg.File += ext
+				seg.SHA256 = ""
+			}
+		}
+	}
+}
+
+// checksum считает контрольные суммы файлов источника, которых ещё нет.
+func checksum(dir string, s *Source) error {
+	var err error
+	if len(s.Segments) == 0 {
+		s.SHA256, err = FileSHA256(filepath.Join(dir, s.DataFile))
+		return err
+	}
+	for i := range s.Segments {
+		seg := &s.Segments[i]
+		if seg.SHA256 == "" {
+			if seg.SHA256, err = FileSHA256(filepath.Join(dir, seg.File)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// repairTail обрезает файл данных после последнего перевода строки и
+// возвращает время последней полной строки (нулевое, если строк
+// данных нет).
+func repairTail(path string) (time.Time, error) {
+	if filepath.Ext(path) != ".csv" {
+		return time.Time{}, nil // сжатый сегмент записан целиком
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	const tail = 64 << 10
+	offset := info.Size() - tail
+	if offset < 0 {
+		offset = 0
+	}
+	buf := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil {
+		return time.Time{}, err
+	}
+
+	end := bytes.LastIndexByte(buf, '\n') + 1
+	if end == 0 && offset > 0 {
+		return time.Time{}, nil // строка длиннее хвоста — не трогаем
+	}
+	if end < len(buf) {
+		if err := f.Truncate(offset + int64(end)); err != nil {
+			return time.Time{}, err
+		}
+		if err := f.Sync(); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	// Время — первый столбец последней полной строки; у заголовка
+	// разбор не удастся
+	buf = buf[:end]
+	line := bytes.TrimRight(buf, "\r\n")
+	line = line[bytes.LastIndexByte(line, '\n')+1:]
+	if i := bytes.IndexByte(line, ','); i > 0 {
+		if t, err := time.Parse(TimeLayout, string(line[:i])); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, nil
+}
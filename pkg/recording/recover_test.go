@@ -0,0 +1,137 @@
+package recording
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+func TestJournal(t *testing.T) {
+	path := JournalPath(filepath.Join(t.TempDir(), "exp.json"))
+	if !strings.HasSuffix(path, "exp.journal") {
+		t.Fatalf("путь журнала %s", path)
+	}
+	j, err := CreateJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []JournalEntry{
+		{Time: t0, Event: JournalStart, PID: 42, Host: "lab"},
+		{Time: t0.Add(10 * time.Second), Event: JournalSync, Lines: []int64{10, 3}},
+	}
+	for _, e := range want {
+		if err := j.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Сбой посреди записи строки
+	j.f.WriteString(`{"time":"2025-08-29T03:07:08Z","ev`)
+	j.Close()
+
+	got, err := ReadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) || !got[1].Time.Equal(want[1].Time) || got[0].PID != 42 || got[1].Lines[1] != 3 {
+		t.Errorf("журнал %+v", got)
+	}
+
+	j, err = CreateJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("журнал не удалён: %v", err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+	tests := []struct {
+		name    string
+		journal time.Time // последняя контрольная точка; нулевая — журнала нет
+		torn    string    // недописанный хвост файла данных
+		end     time.Time
+	}{
+		{"обрыв строки", at(2), "2025-08-29T03:06:5", at(3)},
+		{"контрольная точка позже строк", at(9), "", at(9)},
+		{"без журнала", time.Time{}, "2025-08-29", at(3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			m := &Manifest{SchemaVersion: SchemaVersion, Status: StatusRunning, Name: "exp", Start: t0}
+			m.DataFile = "exp.csv"
+			m.Gaps = []Gap{{Start: at(1), Reason: "EOF"}}
+			m.Pauses = []Interval{{Start: at(2)}}
+			manifest := filepath.Join(dir, "exp.json")
+			if err := WriteManifest(manifest, m); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			w := NewWriter(&buf, sensor.Fields[:1])
+			w.WriteHeader()
+			w.Write(Row{Time: at(0), Seq: 1, Raw: "Starting"})
+			w.Write(Row{Time: at(1), Event: FormatEvent(EventGapStart, "EOF")})
+			w.Write(Row{Time: at(3), Seq: 2, Raw: "P:1000", Sample: sensor.Sample{Values: map[string]float64{"P": 1000}}})
+			w.Flush()
+			complete := buf.Len()
+			buf.WriteString(tt.torn)
+			data := filepath.Join(dir, m.DataFile)
+			if err := os.WriteFile(data, buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.journal.IsZero() {
+				j, _ := CreateJournal(JournalPath(manifest))
+				j.Append(JournalEntry{Time: t0, Event: JournalStart})
+				j.Append(JournalEntry{Time: tt.journal, Event: JournalSync})
+				j.Close()
+			}
+
+			entries, err := Catalog{Dir: dir}.List()
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("каталог: %v, %v", entries, err)
+			}
+			e := entries[0]
+			if !e.Running() || !e.Abandoned(time.Now().Add(time.Hour), time.Minute) || e.Abandoned(time.Now(), time.Hour) {
+				t.Errorf("Running %v, Abandoned %v", e.Running(), e.Abandoned(time.Now().Add(time.Hour), time.Minute))
+			}
+			if err := Recover(e); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadManifest(manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != StatusRecovered || got.StopReason != StopCrash || !got.End.Equal(tt.end) {
+				t.Errorf("состояние %s, причина %s, окончание %v, ожидается %v", got.Status, got.StopReason, got.End, tt.end)
+			}
+			// Строка после начала паузы исключается из сводки.
+			if got.Lines != 2 || got.ParseErrors != 0 || got.Stats == nil || got.Stats.Excluded != 1 || got.Stats.Fields[0].Count != 0 {
+				t.Errorf("строк %d, ошибок %d, сводка %+v", got.Lines, got.ParseErrors, got.Stats)
+			}
+			if got.Gaps[0].End == nil || got.Pauses[0].End == nil {
+				t.Errorf("перерыв и пауза не закрыты: %+v, %+v", got.Gaps, got.Pauses)
+			}
+			if info, _ := os.Stat(data); info.Size() != int64(complete) {
+				t.Errorf("размер файла %d, ожидается %d", info.Size(), complete)
+			}
+			if sum, _ := FileSHA256(data); got.SHA256 != sum {
+				t.Errorf("sha256 %s, ожидается %s", got.SHA256, sum)
+			}
+			if _, err := os.Stat(JournalPath(manifest)); !os.IsNotExist(err) {
+				t.Errorf("журнал не удалён: %v", err)
+			}
+		})
+	}
+}
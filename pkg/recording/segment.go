@@ -0,0 +1,270 @@
+package recording
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// При ротации данные источника пишутся в нумерованные сегменты
+// "<имя>.0001.csv", "<имя>.0002.csv", ... — у каждого свой заголовок.
+// Закрытые сегменты могут сжиматься: "<имя>.0001.csv.gz" (gzip) или
+// "<имя>.0001.csv.zst" (zstd, через программу zstd). Читать сегменты
+// подряд, как один файл, позволяет OpenSource.
+
+// Способы сжатия закрытых сегментов.
+const (
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// Segment — часть файла данных источника.
+type Segment struct {
+	File   string     `json:"file"`
+	Start  time.Time  `json:"start"`
+	End    *time.Time `json:"end,omitempty"`
+	Lines  int64      `json:"lines"`
+	SHA256 string     `json:"sha256,omitempty"`
+}
+
+// SegmentName возвращает имя сегмента n (с 1) файла данных dataFile.
+func SegmentName(dataFile string, n int) string {
+	return fmt.Sprintf("%s.%04d.csv", strings.TrimSuffix(dataFile, ".csv"), n)
+}
+
+// Files возвращает файлы данных источника по порядку: сегменты или
+// DataFile, если ротации не было.
+func (s *Source) Files() []string {
+	if len(s.Segments) == 0 {
+		return []string{s.DataFile}
+	}
+	files := make([]string, len(s.Segments))
+	for i, seg := range s.Segments {
+		files[i] = seg.File
+	}
+	return files
+}
+
+// compressedExt — расширения сжатых файлов по способу сжатия.
+var compressedExt = map[string]string{
+	CompressGzip: ".gz",
+	CompressZstd: ".zst",
+}
+
+// CheckCompression проверяет способ сжатия; пустая строка — без сжатия.
+// Для zstd программа zstd запускается, чтобы её отсутствие обнаружилось
+// до начала записи, а не при первой ротации.
+func CheckCompression(method string) error {
+	switch method {
+	case "", CompressGzip:
+		return nil
+	case CompressZstd:
+		if out, err := exec.Command("zstd", "-V").CombinedOutput(); err != nil {
+			return fmt.Errorf("recording: для сжатия zstd нужна программа zstd: %v %s", err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+	return fmt.Errorf("recording: неизвестный способ сжатия %q (gzip, zstd)", method)
+}
+
+// CompressFile сжимает файл и удаляет исходный. Возвращает путь сжатого
+// файла. Сжатый файл появляется под своим именем только целиком.
+func CompressFile(path, method string) (string, error) {
+	ext, ok := compressedExt[method]
+	if !ok {
+		return "", fmt.Errorf("recording: неизвестный способ сжатия %q", method)
+	}
+	out := path + ext
+	tmp := out + ".tmp"
+
+	var err error
+	switch method {
+	case CompressGzip:
+		err = gzipFile(path, tmp)
+	case CompressZstd:
+		var msg []byte
+		if msg, err = exec.Command("zstd", "-q", "-f", "-o", tmp, path).CombinedOutput(); err != nil {
+			err = fmt.Errorf("zstd: %v: %s", err, strings.TrimSpace(string(msg)))
+		}
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, out); err != nil {
+		return "", err
+	}
+	return out, os.Remove(path)
+}
+
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(src)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// openData открывает файл данных, распаковывая его по расширению.
+func openData(path string) (io.ReadCloser, error) {
+	switch filepath.Ext(path) {
+	case ".gz":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return readCloser{zr, func() error { zr.Close(); return f.Close() }}, nil
+	case ".zst":
+		z := &zstdReader{cmd: exec.Command("zstd", "-q", "-d", "-c", path)}
+		z.cmd.Stderr = &z.stderr
+		out, err := z.cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := z.cmd.Start(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		z.out = out
+		return z, nil
+	}
+	return os.Open(path)
+}
+
+// zstdReader читает вывод zstd -d. Ошибка распаковки (повреждённый или
+// обрезанный файл) возвращается в конце данных вместо io.EOF и из
+// Close.
+type zstdReader struct {
+	cmd    *exec.Cmd
+	out    io.ReadCloser
+	stderr bytes.Buffer
+	done   bool
+	err    error
+}
+
+func (z *zstdReader) Read(p []byte) (int, error) {
+	n, err := z.out.Read(p)
+	if err == io.EOF {
+		if werr := z.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (z *zstdReader) wait() error {
+	if !z.done {
+		z.done = true
+		if err := z.cmd.Wait(); err != nil {
+			z.err = fmt.Errorf("zstd: %v: %s", err, bytes.TrimSpace(z.stderr.Bytes()))
+		}
+	}
+	return z.err
+}
+
+// Close завершает zstd. Если данные дочитаны не до конца, процесс
+// снимается, и его код завершения ошибкой не считается.
+func (z *zstdReader) Close() error {
+	if !z.done {
+		z.done = true
+		z.cmd.Process.Kill()
+		z.cmd.Wait()
+	}
+	return z.err
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error { return r.close() }
+
+// OpenFile открывает один файл данных, в том числе сжатый сегмент.
+// Reader нужно закрыть.
+func OpenFile(path string) (*Reader, error) {
+	return openFiles([]string{path})
+}
+
+// OpenSource открывает все файлы данных источника из каталога dir и
+// читает их подряд, как один файл. Reader нужно закрыть.
+func OpenSource(dir string, s *Source) (*Reader, error) {
+	files := s.Files()
+	for i, f := range files {
+		files[i] = filepath.Join(dir, f)
+	}
+	return openFiles(files)
+}
+
+func openFiles(paths []string) (*Reader, error) {
+	f, err := openData(paths[0])
+	if err != nil {
+		return nil, err
+	}
+	rd, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", paths[0], err)
+	}
+	rd.closer, rd.next = f, paths[1:]
+	return rd, nil
+}
+
+// nextSegment переходит к следующему файлу; false — файлов больше нет.
+func (r *Reader) nextSegment() (bool, error) {
+	if len(r.next) == 0 {
+		return false, nil
+	}
+	path := r.next[0]
+	next, err := openFiles([]string{path})
+	if err != nil {
+		return false, err
+	}
+	if strings.Join(next.header, ",") != strings.Join(r.header, ",") {
+		next.Close()
+		return false, fmt.Errorf("recording: %s: заголовок отличается от предыдущего сегмента", path)
+	}
+	if err := r.closer.Close(); err != nil {
+		next.Close()
+		return false, err
+	}
+	r.csv, r.closer, r.next, r.line = next.csv, next.closer, r.next[1:], next.line
+	return true, nil
+}
+
+// Close закрывает открытые OpenFile и OpenSource файлы.
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
@@ -0,0 +1,173 @@
+package recording
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// writeSegments пишет в dir сегменты файла "exp.csv" по n строк и
+// сжимает все, кроме последнего.
+func writeSegments(t *testing.T, dir, method string, segments, n int) *Source {
+	t.Helper()
+	s := &Source{DataFile: "exp.csv"}
+	seq := int64(0)
+	for i := 1; i <= segments; i++ {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, sensor.Fields[:1])
+		w.WriteHeader()
+		for j := 0; j < n; j++ {
+			seq++
+			v := float64(1000 + seq)
+			w.Write(Row{Time: t0.Add(time.Duration(seq) * time.Second), Seq: seq, Raw: "P",
+				Sample: sensor.Sample{Values: map[string]float64{"P": v}}})
+		}
+		w.Flush()
+		path := filepath.Join(dir, SegmentName(s.DataFile, i))
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if method != "" && i < segments {
+			var err error
+			if path, err = CompressFile(path, method); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.Segments = append(s.Segments, Segment{File: filepath.Base(path), Lines: int64(n)})
+	}
+	return s
+}
+
+func TestOpenSource(t *testing.T) {
+	if name := SegmentName("exp.csv", 12); name != "exp.0012.csv" {
+		t.Errorf("SegmentName = %s", name)
+	}
+	tests := []struct {
+		method string
+		files  []string
+	}{
+		{"", []string{"exp.0001.csv", "exp.0002.csv", "exp.0003.csv"}},
+		{CompressGzip, []string{"exp.0001.csv.gz", "exp.0002.csv.gz", "exp.0003.csv"}},
+		{CompressZstd, []string{"exp.0001.csv.zst", "exp.0002.csv.zst", "exp.0003.csv"}},
+	}
+	for _, tt := range tests {
+		t.Run("сжатие "+tt.method, func(t *testing.T) {
+			if tt.method == CompressZstd {
+				needZstd(t)
+			}
+			dir := t.TempDir()
+			s := writeSegments(t, dir, tt.method, 3, 4)
+			if got := strings.Join(s.Files(), " "); got != strings.Join(tt.files, " ") {
+				t.Errorf("файлы %s", got)
+			}
+			for _, f := range tt.files[:2] {
+				if _, err := os.Stat(filepath.Join(dir, strings.TrimSuffix(strings.TrimSuffix(f, ".gz"), ".zst"))); tt.method != "" && !os.IsNotExist(err) {
+					t.Errorf("несжатый %s не удалён: %v", f, err)
+				}
+			}
+
+			r, err := OpenSource(dir, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for want := int64(1); want <= 12; want++ {
+				row, err := r.Read()
+				if err != nil {
+					t.Fatalf("строка %d: %v", want, err)
+				}
+				if row.Seq != want || row.Sample.Values["P"] != float64(1000+want) {
+					t.Errorf("строка %d: %+v", want, row)
+				}
+			}
+			if _, err := r.Read(); err != io.EOF {
+				t.Errorf("после последнего сегмента: %v", err)
+			}
+			if err := r.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenSourceErrors(t *testing.T) {
+	t.Run("заголовок сегмента", func(t *testing.T) {
+		dir := t.TempDir()
+		s := writeSegments(t, dir, "", 2, 1)
+		os.WriteFile(filepath.Join(dir, s.Segments[1].File), []byte("timestamp,seq,T2,raw,event\n"), 0o644)
+		r, err := OpenSource(dir, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		r.Read()
+		if _, err := r.Read(); err == nil || !strings.Contains(err.Error(), "заголовок отличается") {
+			t.Errorf("ошибка %v", err)
+		}
+	})
+
+	t.Run("обрезанный zstd", func(t *testing.T) {
+		needZstd(t)
+		dir := t.TempDir()
+		s := writeSegments(t, dir, CompressZstd, 2, 500)
+		path := filepath.Join(dir, s.Segments[0].File)
+		data, _ := os.ReadFile(path)
+		os.WriteFile(path, data[:len(data)/2], 0o644)
+
+		// Ошибка появляется при открытии или при чтении, смотря
+		// сколько данных zstd успеет выдать, но не как io.EOF.
+		r, err := OpenFile(path)
+		for err == nil {
+			_, err = r.Read()
+		}
+		if err == io.EOF || !strings.Contains(err.Error(), "zstd: exit status") {
+			t.Errorf("чтение: %v, ожидается ошибка zstd", err)
+		}
+		if r != nil && r.Close() == nil {
+			t.Error("Close не вернул ошибку zstd")
+		}
+	})
+
+	t.Run("закрытие до конца данных", func(t *testing.T) {
+		needZstd(t)
+		dir := t.TempDir()
+		s := writeSegments(t, dir, CompressZstd, 2, 5000)
+		r, err := OpenFile(filepath.Join(dir, s.Segments[0].File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Read(); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+}
+
+func TestCheckCompression(t *testing.T) {
+	for _, m := range []string{"", CompressGzip} {
+		if err := CheckCompression(m); err != nil {
+			t.Errorf("%q: %v", m, err)
+		}
+	}
+	if err := CheckCompression("lz4"); err == nil {
+		t.Error("принят неизвестный способ сжатия")
+	}
+	t.Setenv("PATH", t.TempDir())
+	if err := CheckCompression(CompressZstd); err == nil || !strings.Contains(err.Error(), "программа zstd") {
+		t.Errorf("zstd без программы: %v", err)
+	}
+}
+
+func needZstd(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("нет программы zstd")
+	}
+}
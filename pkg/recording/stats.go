@@ -0,0 +1,150 @@
+package recording
+
+import (
+	"errors"
+	"io"
+	"math"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+// FieldStats — сводка по одному числовому полю.
+type FieldStats struct {
+	Name  string  `json:"name"`
+	Count int64   `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	Std   float64 `json:"std"`
+	First float64 `json:"first"`
+	Last  float64 `json:"last"`
+	// Значения с флагами качества «сомнительно» и «брак»; в сводку
+	// входят все значения.
+	Suspect int64 `json:"qc_suspect,omitempty"`
+	Fail    int64 `json:"qc_fail,omitempty"`
+
+	m2 float64 // сумма квадратов отклонений (алгоритм Уэлфорда)
+}
+
+func (f *FieldStats) add(v float64) {
+	if f.Count == 0 {
+		f.Min, f.Max, f.First = v, v, v
+	}
+	f.Min = math.Min(f.Min, v)
+	f.Max = math.Max(f.Max, v)
+	f.Last = v
+	f.Count++
+	d := v - f.Mean
+	f.Mean += d / float64(f.Count)
+	f.m2 += d * (v - f.Mean)
+	if f.Count > 1 {
+		f.Std = math.Sqrt(f.m2 / float64(f.Count-1))
+	}
+}
+
+// Summary — сводка по записи эксперимента.
+type Summary struct {
+	Rows     int64 `json:"rows"`               // строк данных (без строк-событий)
+	Excluded int64 `json:"excluded,omitempty"` // из них принятых во время паузы
+	Unparsed int64 `json:"unparsed"`           // строк, не содержащих данных
+	// Malformed — из них искажённых строк данных (см. sensor.ErrBadData).
+	Malformed int64 `json:"malformed,omitempty"`
+	// SampleRate — оценка частоты строк данных по времени приёма, Гц.
+	SampleRate float64 `json:"sample_rate_hz"`
+	// MaxGap — наибольший промежуток между соседними строками данных.
+	MaxGapSec   float64    `json:"max_gap_s"`
+	MaxGapStart *time.Time `json:"max_gap_start,omitempty"`
+	// QC — в файле есть флаги качества (счётчики Suspect и Fail).
+	QC     bool         `json:"qc,omitempty"`
+	Fields []FieldStats `json:"fields"`
+}
+
+// MaxGap возвращает наибольший промежуток между строками данных.
+func (s *Summary) MaxGap() time.Duration {
+	return time.Duration(s.MaxGapSec * float64(time.Second))
+}
+
+// Summarize читает строки до конца и считает сводку по полям. Строки,
+// для которых excluded возвращает true, в сводку полей не входят, но
+// учитываются в частоте и промежутках; excluded может быть nil.
+func Summarize(r *Reader, excluded func(time.Time) bool) (*Summary, error) {
+	s := &Summary{Fields: make([]FieldStats, len(r.Fields())), QC: r.HasQC()}
+	for i, name := range r.Fields() {
+		s.Fields[i].Name = name
+	}
+
+	var first, prev time.Time
+	var samples int64
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return s, err
+		}
+		if row.Event != "" {
+			continue
+		}
+		s.Rows++
+		if row.Sample.Values == nil {
+			s.Unparsed++
+			if _, err := sensor.ParseLine(row.Raw); errors.Is(err, sensor.ErrBadData) {
+				s.Malformed++
+			}
+			continue
+		}
+
+		samples++
+		if first.IsZero() {
+			first = row.Time
+		} else if gap := row.Time.Sub(prev); gap.Seconds() > s.MaxGapSec {
+			start := prev
+			s.MaxGapSec, s.MaxGapStart = gap.Seconds(), &start
+		}
+		prev = row.Time
+
+		if excluded != nil && excluded(row.Time) {
+			s.Excluded++
+			continue
+		}
+		for i := range s.Fields {
+			f := &s.Fields[i]
+			if v, ok := row.Sample.Value(f.Name); ok {
+				f.add(v)
+			}
+			switch row.Sample.Flags[f.Name] {
+			case qc.Suspect:
+				f.Suspect++
+			case qc.Fail:
+				f.Fail++
+			}
+		}
+	}
+	if d := prev.Sub(first).Seconds(); samples > 1 && d > 0 {
+		s.SampleRate = float64(samples-1) / d
+	}
+	return s, nil
+}
+
+// SummarizeFile считает сводку по файлу данных.
+func SummarizeFile(path string, excluded func(time.Time) bool) (*Summary, error) {
+	rd, err := OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	return Summarize(rd, excluded)
+}
+
+// SummarizeSource считает сводку по всем файлам данных источника.
+func SummarizeSource(dir string, s *Source, excluded func(time.Time) bool) (*Summary, error) {
+	rd, err := OpenSource(dir, s)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	return Summarize(rd, excluded)
+}
@@ -0,0 +1,71 @@
+package recording
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+func TestSummarize(t *testing.T) {
+	fields := []sensor.Field{{Name: "P"}, {Name: "T2"}}
+	var buf bytes.Buffer
+	w := NewWriter(&buf, fields)
+	w.QC = true
+	w.WriteHeader()
+	at := func(s float64) time.Time { return t0.Add(time.Duration(s * float64(time.Second))) }
+	data := func(s, p, t2 float64, f qc.Flag) Row {
+		return Row{Time: at(s), Seq: 1, Raw: "P:..., T2:...", Sample: sensor.Sample{
+			Values: map[string]float64{"P": p, "T2": t2},
+			Flags:  map[string]qc.Flag{"P": qc.Pass, "T2": f},
+		}}
+	}
+	rows := []Row{
+		{Time: at(0), Seq: 1, Raw: "Starting"},
+		{Time: at(0.5), Seq: 2, Raw: "P:10\x0013.25, T2:20"},
+		data(1, 1000, 20, qc.Pass),
+		data(2, 1002, 21, qc.Suspect),
+		{Time: at(2.5), Event: EventPause},
+		data(3, 5000, 99, qc.Fail), // исключается паузой
+		{Time: at(3.5), Event: EventResume},
+		data(7, 1004, 22, qc.Pass),
+	}
+	for _, r := range rows {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Flush()
+
+	pause := Interval{Start: at(2.5), End: ptr(at(3.5))}
+	sum, err := Summarize(mustReader(t, buf.String()), pause.Contains)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Rows != 6 || sum.Unparsed != 2 || sum.Malformed != 1 || sum.Excluded != 1 || !sum.QC {
+		t.Errorf("строк %d, без данных %d, искажённых %d, исключено %d, QC %v",
+			sum.Rows, sum.Unparsed, sum.Malformed, sum.Excluded, sum.QC)
+	}
+	if sum.SampleRate != 0.5 || sum.MaxGap() != 4*time.Second || !sum.MaxGapStart.Equal(at(3)) {
+		t.Errorf("частота %g Гц, промежуток %v с %v", sum.SampleRate, sum.MaxGap(), sum.MaxGapStart)
+	}
+	tests := []FieldStats{
+		{Name: "P", Count: 3, Min: 1000, Max: 1004, Mean: 1002, Std: 2, First: 1000, Last: 1004},
+		{Name: "T2", Count: 3, Min: 20, Max: 22, Mean: 21, Std: 1, First: 20, Last: 22, Suspect: 1},
+	}
+	for i, want := range tests {
+		got := sum.Fields[i]
+		got.m2 = 0
+		if math.Abs(got.Std-want.Std) < 1e-12 {
+			got.Std = want.Std
+		}
+		if got != want {
+			t.Errorf("%s: %+v, ожидается %+v", want.Name, got, want)
+		}
+	}
+}
+
+func ptr(t time.Time) *time.Time { return &t }
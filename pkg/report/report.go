@@ -0,0 +1,307 @@
+// Пакет report строит отчёт об эксперименте — один HTML-файл без
+// внешних ссылок: манифест, сводка по полям, графики, журнал отметок,
+// пауз и перерывов связи, замечания о качестве данных.
+//
+// Отчёт строится только по файлам записи (манифесту и файлам данных),
+// поэтому его можно построить заново в любой момент.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/export"
+	"github.com/physicist2018/goserialcomm/pkg/plot"
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+)
+
+// plotPoints — наибольшее число точек ряда на графике отчёта.
+const plotPoints = 2000
+
+// plotHeight — высота графика одного поля, в точках.
+const plotHeight = 260
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// page — данные шаблона отчёта.
+type page struct {
+	M         *recording.Manifest
+	Status    string
+	Duration  string
+	Generated string
+	Sources   []*sourcePage
+	Events    []event
+	Pauses    []event
+	Gaps      []event
+	Flags     []Flag
+}
+
+// sourcePage — раздел отчёта об одном источнике.
+type sourcePage struct {
+	Name    string
+	Address string
+	Files   []file
+	Stats   *recording.Summary
+	MaxGap  string
+	Fields  []field
+	Plots   []figure
+}
+
+type file struct {
+	Name, Lines, SHA256 string
+}
+
+type field struct {
+	Name, Unit          string
+	Count, Missing      int64
+	Suspect, Fail       int64 // флаги QARTOD
+	Min, Max, Mean, Std string
+	First, Last         string
+}
+
+type figure struct {
+	Title string
+	SVG   template.HTML
+}
+
+// event — строка журнала: время, длительность и текст.
+type event struct {
+	Time     string
+	Duration string
+	Source   string
+	Text     string
+
+	at time.Time
+}
+
+// Flag — замечание о качестве данных.
+type Flag struct {
+	Source string
+	Text   string
+	// Warning — замечание требует внимания (иначе — для сведения).
+	Warning bool
+}
+
+// Write строит отчёт по эксперименту с манифестом m и файлами данных
+// в каталоге dir.
+func Write(w io.Writer, dir string, m *recording.Manifest) error {
+	p := &page{
+		M:         m,
+		Status:    statusText(m),
+		Generated: time.Now().Format(timeLayout),
+	}
+	if m.End != nil {
+		p.Duration = m.End.Sub(m.Start).Round(time.Second).String()
+	}
+	for _, pause := range m.Pauses {
+		p.Pauses = append(p.Pauses, interval(pause.Start, pause.End, "", "пауза"))
+	}
+	for _, n := range m.Notes {
+		p.Events = append(p.Events, event{Time: n.Time.Local().Format(timeLayout), Text: n.Text, at: n.Time})
+	}
+	switch m.Status {
+	case recording.StatusRunning:
+		p.Flags = append(p.Flags, Flag{Text: "запись не завершена, данные неполные", Warning: true})
+	case recording.StatusRecovered:
+		p.Flags = append(p.Flags, Flag{Text: "эксперимент восстановлен после сбоя, конец записи мог быть потерян", Warning: true})
+	}
+
+	for i, src := range m.AllSources() {
+		sp, t, err := source(dir, m, src)
+		if err != nil {
+			return err
+		}
+		p.Sources = append(p.Sources, sp)
+		// Отметки пишутся в файлы всех источников
+		if i == 0 {
+			for _, n := range t.Marks {
+				p.Events = append(p.Events, event{Time: n.Time.Local().Format(timeLayout), Text: "отметка: " + n.Text, at: n.Time})
+			}
+		}
+		for _, g := range src.Gaps {
+			p.Gaps = append(p.Gaps, interval(g.Start, g.End, src.Name, g.Reason))
+		}
+		p.Flags = append(p.Flags, QualityNotes(dir, src, sp.Stats)...)
+	}
+	sort.SliceStable(p.Events, func(i, j int) bool { return p.Events[i].at.Before(p.Events[j].at) })
+
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, p); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// source собирает раздел источника: файлы, сводку и графики полей.
+func source(dir string, m *recording.Manifest, src *recording.Source) (*sourcePage, *export.Table, error) {
+	sp := &sourcePage{Name: src.Name, Address: src.Address}
+	if len(src.Segments) == 0 {
+		sp.Files = append(sp.Files, file{Name: src.DataFile, Lines: fmt.Sprint(src.Lines), SHA256: src.SHA256})
+	}
+	for _, seg := range src.Segments {
+		sp.Files = append(sp.Files, file{Name: seg.File, Lines: fmt.Sprint(seg.Lines), SHA256: seg.SHA256})
+	}
+
+	sum := src.Stats
+	if sum == nil {
+		var err error
+		if sum, err = recording.SummarizeSource(dir, src, m.Excluded); err != nil {
+			return nil, nil, err
+		}
+	}
+	sp.Stats = sum
+	sp.MaxGap = sum.MaxGap().Round(time.Millisecond).String()
+	for _, st := range sum.Fields {
+		sp.Fields = append(sp.Fields, field{
+			Name:    st.Name,
+			Unit:    unit(src.Fields, st.Name),
+			Count:   st.Count,
+			Missing: missing(sum, st),
+			Suspect: st.Suspect,
+			Fail:    st.Fail,
+			Min:     number(st.Min),
+			Max:     number(st.Max),
+			Mean:    number(st.Mean),
+			Std:     number(st.Std),
+			First:   number(st.First),
+			Last:    number(st.Last),
+		})
+	}
+
+	t, err := export.LoadSource(dir, m, src, export.Options{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, st := range sum.Fields {
+		if st.Count == 0 {
+			continue
+		}
+		fig, err := plot.TimeSeries(t, []string{st.Name}, nil)
+		if err != nil {
+			continue // поле есть в сводке, но не в таблице
+		}
+		fig.Title = ""
+		fig.Height = plotHeight
+		fig.AddEvents(t)
+		fig.Reduce(plotPoints)
+		var buf bytes.Buffer
+		if err := fig.WriteSVG(&buf); err != nil {
+			return nil, nil, err
+		}
+		sp.Plots = append(sp.Plots, figure{Title: fig.Y.Label, SVG: template.HTML(buf.String())})
+	}
+	return sp, t, nil
+}
+
+// QualityNotes проверяет файлы и сводку источника и возвращает
+// замечания о качестве данных.
+func QualityNotes(dir string, src *recording.Source, sum *recording.Summary) []Flag {
+	var flags []Flag
+	add := func(warning bool, format string, args ...interface{}) {
+		flags = append(flags, Flag{Source: src.Name, Text: fmt.Sprintf(format, args...), Warning: warning})
+	}
+
+	for _, f := range checksums(src) {
+		got, err := recording.FileSHA256(filepath.Join(dir, f.Name))
+		switch {
+		case err != nil:
+			add(true, "файл %s не читается: %v", f.Name, err)
+		case got != f.SHA256:
+			add(true, "контрольная сумма %s не совпадает с манифестом: файл изменён после записи", f.Name)
+		}
+	}
+	if sum.Unparsed > 0 {
+		add(false, "строк без данных (служебные сообщения прибора или ошибки разбора): %d", sum.Unparsed)
+	}
+	if sum.Excluded > 0 {
+		add(false, "строк, принятых во время пауз и исключённых из сводки: %d", sum.Excluded)
+	}
+	if n := len(src.Gaps); n > 0 {
+		var total time.Duration
+		for _, g := range src.Gaps {
+			if g.End != nil {
+				total += g.End.Sub(g.Start)
+			}
+		}
+		add(true, "перерывов связи: %d, всего %v", n, total.Round(time.Second))
+	}
+	if sum.SampleRate > 0 && sum.MaxGapSec > 5/sum.SampleRate {
+		add(true, "наибольший промежуток между строками %v при средней частоте %.3f Гц",
+			sum.MaxGap().Round(time.Millisecond), sum.SampleRate)
+	}
+	for _, st := range sum.Fields {
+		if n := missing(sum, st); n > 0 {
+			add(true, "поле %s: нет значения в %d строках", st.Name, n)
+		}
+		if st.Count > 1 && st.Std == 0 {
+			add(true, "поле %s: значение не меняется (%s) — датчик мог зависнуть", st.Name, number(st.Min))
+		}
+		if sum.QC && st.Suspect+st.Fail > 0 {
+			add(true, "поле %s: сомнительных значений %d, забракованных %d (QARTOD)", st.Name, st.Suspect, st.Fail)
+		}
+	}
+	return flags
+}
+
+// checksums возвращает файлы источника с известной контрольной суммой.
+func checksums(src *recording.Source) []file {
+	if len(src.Segments) == 0 {
+		if src.SHA256 == "" {
+			return nil
+		}
+		return []file{{Name: src.DataFile, SHA256: src.SHA256}}
+	}
+	var files []file
+	for _, seg := range src.Segments {
+		if seg.SHA256 != "" {
+			files = append(files, file{Name: seg.File, SHA256: seg.SHA256})
+		}
+	}
+	return files
+}
+
+// missing — число строк с данными, где у поля нет значения.
+func missing(sum *recording.Summary, st recording.FieldStats) int64 {
+	return sum.Rows - sum.Unparsed - sum.Excluded - st.Count
+}
+
+func interval(start time.Time, end *time.Time, source, text string) event {
+	e := event{Time: start.Local().Format(timeLayout), Source: source, Text: text, at: start}
+	if end != nil {
+		e.Duration = end.Sub(start).Round(time.Second).String()
+	} else {
+		e.Duration = "не закрыт"
+	}
+	return e
+}
+
+func statusText(m *recording.Manifest) string {
+	switch {
+	case m.Status == recording.StatusRunning:
+		return "не завершён"
+	case m.Status == recording.StatusRecovered:
+		return "восстановлен после сбоя"
+	case m.StopReason != "":
+		return "завершён (" + m.StopReason + ")"
+	}
+	return "завершён"
+}
+
+func unit(fields []recording.Column, name string) string {
+	for _, c := range fields {
+		if c.Name == name {
+			return c.Unit
+		}
+	}
+	return ""
+}
+
+func number(v float64) string {
+	return fmt.Sprintf("%.3f", v)
+}
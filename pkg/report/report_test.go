@@ -0,0 +1,131 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/recording"
+	"github.com/physicist2018/goserialcomm/pkg/sensor"
+)
+
+var start = time.Date(2025, 8, 29, 3, 6, 48, 0, time.UTC)
+
+// record пишет в dir запись: баннер, 20 строк раз в секунду с
+// зависшим T1, одна из них без T1, и промежуток 30 с перед последней
+// строкой.
+func record(t *testing.T, dir string) *recording.Manifest {
+	t.Helper()
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+	var buf bytes.Buffer
+	w := recording.NewWriter(&buf, sensor.Fields[:2])
+	w.WriteHeader()
+	w.Write(recording.Row{Time: at(0), Seq: 1, Raw: "Starting"})
+	for i := 1; i <= 21; i++ {
+		s, v := i, map[string]float64{"P": float64(1000 + i), "T1": 20}
+		if i == 3 {
+			delete(v, "T1")
+		}
+		if i == 21 {
+			s = 50
+		}
+		w.Write(recording.Row{Time: at(s), Seq: int64(i + 1), Raw: "P", Sample: sensor.Sample{Values: v}})
+	}
+	w.Flush()
+	path := filepath.Join(dir, "exp.csv")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := recording.FileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := at(50)
+	gapEnd := at(45)
+	m := &recording.Manifest{SchemaVersion: recording.SchemaVersion, Status: recording.StatusCompleted,
+		Name: "Погружение", Start: start, End: &end}
+	m.Source = recording.Source{Address: "tcp://localhost:8081", DataFile: "exp.csv", Lines: 22, SHA256: sum,
+		Fields: []recording.Column{{Name: "P", Unit: "mbar"}, {Name: "T1", Unit: "degC"}},
+		Gaps:   []recording.Gap{{Start: at(21), End: &gapEnd, Reason: "EOF"}}}
+	return m
+}
+
+func TestQualityNotes(t *testing.T) {
+	want := []string{
+		"строк без данных (служебные сообщения прибора или ошибки разбора): 1",
+		"!перерывов связи: 1, всего 24s",
+		"!наибольший промежуток между строками 30s",
+		"!поле T1: нет значения в 1 строках",
+		"!поле T1: значение не меняется (20.000)",
+	}
+	tests := []struct {
+		name   string
+		damage func(path string)
+		want   []string
+	}{
+		{"целый файл", func(string) {}, want},
+		{"файл изменён", func(path string) {
+			f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			f.WriteString("\n")
+			f.Close()
+		}, append([]string{"!контрольная сумма exp.csv не совпадает с манифестом"}, want...)},
+		{"файла нет", func(path string) { os.Remove(path) }, append([]string{"!файл exp.csv не читается"}, want...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			m := record(t, dir)
+			sum, err := recording.SummarizeSource(dir, &m.Source, m.Excluded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.damage(filepath.Join(dir, "exp.csv"))
+
+			flags := QualityNotes(dir, &m.Source, sum)
+			if len(flags) != len(tt.want) {
+				t.Fatalf("замечания %+v", flags)
+			}
+			for i, f := range flags {
+				text := tt.want[i]
+				warning := strings.HasPrefix(text, "!")
+				if !strings.HasPrefix(f.Text, strings.TrimPrefix(text, "!")) || f.Warning != warning {
+					t.Errorf("замечание %d: %q (внимание %v), ожидается %q", i+1, f.Text, f.Warning, text)
+				}
+			}
+		})
+	}
+
+	t.Run("ровная запись", func(t *testing.T) {
+		sum := &recording.Summary{Rows: 10, SampleRate: 1, MaxGapSec: 1,
+			Fields: []recording.FieldStats{{Name: "P", Count: 10, Std: 0.5}}}
+		if flags := QualityNotes(t.TempDir(), &recording.Source{}, sum); len(flags) != 0 {
+			t.Errorf("замечания %+v", flags)
+		}
+	})
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	m := record(t, dir)
+	m.Status = recording.StatusRecovered
+	m.Notes = []recording.Note{{Time: start.Add(2 * time.Second), Text: "сменили кабель"}}
+	var buf bytes.Buffer
+	if err := Write(&buf, dir, m); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, want := range []string{
+		"<title>", "Погружение", "восстановлен после сбоя", "сменили кабель",
+		"exp.csv", m.Source.SHA256, "mbar", "<svg", "значение не меняется",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("в отчёте нет %q", want)
+		}
+	}
+	if strings.Contains(html, `src="http`) || strings.Contains(html, `href="http`) {
+		t.Error("отчёт ссылается на внешние ресурсы")
+	}
+}
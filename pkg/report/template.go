@@ -0,0 +1,98 @@
+package report
+
+import "html/template"
+
+// pageTemplate — разметка отчёта; стили встроены, чтобы файл можно было
+// отправить одним вложением.
+var pageTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>{{.M.Name}} — отчёт об эксперименте</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; color: #222; max-width: 960px; margin: 24px auto; padding: 0 16px; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+h2 { font-size: 18px; margin-top: 32px; border-bottom: 1px solid #ccc; padding-bottom: 4px; }
+h3 { font-size: 15px; margin-top: 20px; }
+table { border-collapse: collapse; margin: 8px 0; }
+th, td { padding: 3px 10px; border-bottom: 1px solid #e4e4e4; text-align: left; vertical-align: top; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+th { background: #f4f4f4; }
+table.info th { background: none; font-weight: normal; color: #666; }
+code { font-size: 12px; word-break: break-all; }
+.muted { color: #888; }
+.warning { color: #b32; }
+svg { max-width: 100%; height: auto; }
+@media print { h2 { page-break-after: avoid; } figure { page-break-inside: avoid; } }
+figure { margin: 8px 0 16px; }
+figcaption { font-weight: bold; margin-bottom: 4px; }
+</style>
+</head>
+<body>
+<h1>{{.M.Name}}</h1>
+{{with .M.Description}}<p>{{.}}</p>{{end}}
+
+<table class="info">
+<tr><th>Начало</th><td>{{.M.Start.Local.Format "2006-01-02 15:04:05"}}</td></tr>
+{{with .M.End}}<tr><th>Окончание</th><td>{{.Local.Format "2006-01-02 15:04:05"}}{{with $.Duration}} ({{.}}){{end}}</td></tr>{{end}}
+<tr><th>Состояние</th><td>{{.Status}}</td></tr>
+{{with .M.Operator}}<tr><th>Оператор</th><td>{{.}}{{with $.M.Host}}@{{.}}{{end}}</td></tr>{{end}}
+<tr><th>Строк</th><td>{{.M.Lines}}, ошибок разбора: {{.M.ParseErrors}}</td></tr>
+{{with .M.QC}}<tr><th>Качество</th><td>{{.}}</td></tr>{{end}}
+</table>
+
+<h2>Качество данных</h2>
+{{if .Flags}}<ul>
+{{range .Flags}}<li{{if .Warning}} class="warning"{{end}}>{{with .Source}}{{.}}: {{end}}{{.Text}}</li>
+{{end}}</ul>
+{{else}}<p>Замечаний нет.</p>
+{{end}}
+
+{{range .Sources}}
+<h2>{{if .Name}}Источник {{.Name}}{{else}}Данные{{end}}</h2>
+<table class="info">
+<tr><th>Адрес</th><td>{{.Address}}</td></tr>
+<tr><th>Строк данных</th><td>{{.Stats.Rows}}, без данных: {{.Stats.Unparsed}}{{if .Stats.Excluded}}, исключено паузами: {{.Stats.Excluded}}{{end}}</td></tr>
+<tr><th>Частота</th><td>{{printf "%.3f" .Stats.SampleRate}} Гц, наибольший промежуток: {{.MaxGap}}</td></tr>
+</table>
+
+<h3>Файлы</h3>
+<table>
+<tr><th>Файл</th><th>Строк</th><th>SHA-256</th></tr>
+{{range .Files}}<tr><td>{{.Name}}</td><td class="num">{{.Lines}}</td><td><code>{{.SHA256}}</code></td></tr>
+{{end}}</table>
+
+<h3>Сводка</h3>
+{{$qc := .Stats.QC}}<table>
+<tr><th>Поле</th><th>Ед.</th><th>Число</th><th>Пропусков</th>{{if $qc}}<th>Сомнит.</th><th>Брак</th>{{end}}<th>Мин</th><th>Макс</th><th>Среднее</th><th>СКО</th><th>Первое</th><th>Последнее</th></tr>
+{{range .Fields}}<tr><td>{{.Name}}</td><td>{{.Unit}}</td><td class="num">{{.Count}}</td><td class="num">{{.Missing}}</td>{{if $qc}}<td class="num">{{.Suspect}}</td><td class="num">{{.Fail}}</td>{{end}}<td class="num">{{.Min}}</td><td class="num">{{.Max}}</td><td class="num">{{.Mean}}</td><td class="num">{{.Std}}</td><td class="num">{{.First}}</td><td class="num">{{.Last}}</td></tr>
+{{end}}</table>
+
+{{range .Plots}}<figure>
+<figcaption>{{.Title}}</figcaption>
+{{.SVG}}
+</figure>
+{{end}}
+{{end}}
+
+<h2>Журнал отметок и заметок</h2>
+{{if .Events}}<table>
+<tr><th>Время</th><th>Текст</th></tr>
+{{range .Events}}<tr><td>{{.Time}}</td><td>{{.Text}}</td></tr>
+{{end}}</table>
+{{else}}<p class="muted">Отметок нет.</p>
+{{end}}
+
+<h2>Паузы и перерывы связи</h2>
+{{if or .Pauses .Gaps}}<table>
+<tr><th>Начало</th><th>Длительность</th><th>Источник</th><th>Причина</th></tr>
+{{range .Pauses}}<tr><td>{{.Time}}</td><td>{{.Duration}}</td><td></td><td>{{.Text}}</td></tr>
+{{end}}{{range .Gaps}}<tr><td>{{.Time}}</td><td>{{.Duration}}</td><td>{{.Source}}</td><td>{{.Text}}</td></tr>
+{{end}}</table>
+{{else}}<p class="muted">Пауз и перерывов связи не было.</p>
+{{end}}
+
+<p class="muted">Отчёт построен {{.Generated}} по файлам записи.</p>
+</body>
+</html>
+`))
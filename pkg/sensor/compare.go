@@ -0,0 +1,68 @@
+package sensor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Comparison — сравнение поля с числом, например "Depth < 0.5".
+type Comparison struct {
+	Field string
+	Op    string
+	Value float64
+}
+
+// Операторы сравнения; двухсимвольные проверяются первыми.
+var compareOps = []string{"<=", ">=", "==", "!=", "<", ">"}
+
+// ParseComparison разбирает строку вида "<поле> <оператор> <число>".
+// Пробелы вокруг оператора необязательны.
+func ParseComparison(s string) (Comparison, error) {
+	s = strings.TrimSpace(s)
+	for _, op := range compareOps {
+		field, value, ok := strings.Cut(s, op)
+		if !ok {
+			continue
+		}
+		c := Comparison{Field: strings.TrimSpace(field), Op: op}
+		if c.Field == "" {
+			return c, fmt.Errorf("сравнение %q: не указано поле", s)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return c, fmt.Errorf("сравнение %q: %w", s, err)
+		}
+		c.Value = v
+		return c, nil
+	}
+	return Comparison{}, fmt.Errorf("сравнение %q: нет оператора (%s)", s, strings.Join(compareOps, " "))
+}
+
+// Eval проверяет сравнение на значениях строки. Второе значение ложно,
+// если в строке нет поля.
+func (c Comparison) Eval(s Sample) (match, ok bool) {
+	v, ok := s.Value(c.Field)
+	if !ok {
+		return false, false
+	}
+	switch c.Op {
+	case "<":
+		return v < c.Value, true
+	case "<=":
+		return v <= c.Value, true
+	case ">":
+		return v > c.Value, true
+	case ">=":
+		return v >= c.Value, true
+	case "==":
+		return v == c.Value, true
+	case "!=":
+		return v != c.Value, true
+	}
+	return false, false
+}
+
+func (c Comparison) String() string {
+	return fmt.Sprintf("%s %s %g", c.Field, c.Op, c.Value)
+}
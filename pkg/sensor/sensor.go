@@ -0,0 +1,170 @@
+// Пакет sensor разбирает строки, которые печатает прошивка
+// sketch_sep02a.ino, и описывает её поля.
+//
+// Строка данных прошивки:
+//
+//	P:1013.25, T1:20.10, Depth:0.00, Alt:0.50, T2:20.05
+//
+// Мост добавляет к ней свою метку времени: "20250829030648\t<строка>",
+// а если проверяет качество данных — и флаги через ещё одну табуляцию
+// (см. qc.Prefix).
+package sensor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+)
+
+// BridgeTimeLayout — формат метки времени, которую мост добавляет к строке.
+const BridgeTimeLayout = "20060102150405"
+
+// Field описывает числовое поле строки прошивки.
+type Field struct {
+	Name        string
+	Unit        string // в обозначениях UDUNITS
+	Description string
+	// StandardName — стандартное имя величины по соглашениям CF, пусто
+	// если подходящего нет.
+	StandardName string
+}
+
+// Fields — поля прошивки в порядке печати.
+var Fields = []Field{
+	{Name: "P", Unit: "mbar", Description: "Давление, MS5837", StandardName: "sea_water_pressure"},
+	{Name: "T1", Unit: "degC", Description: "Температура, MS5837", StandardName: "sea_water_temperature"},
+	{Name: "Depth", Unit: "m", Description: "Глубина по MS5837 (плотность 1029)", StandardName: "depth"},
+	{Name: "Alt", Unit: "m", Description: "Высота по MS5837"},
+	{Name: "T2", Unit: "degC", Description: "Температура, TSYS01", StandardName: "sea_water_temperature"},
+}
+
+// ErrNoData — строка не является строкой данных (баннер, сообщение об
+// ошибке инициализации, мусор в линии).
+var ErrNoData = errors.New("sensor: строка не содержит данных")
+
+// ErrBadData — строка похожа на строку данных (начинается с
+// "Имя:число"), но не разбирается: оборвана или искажена помехой.
+var ErrBadData = errors.New("sensor: ошибка в строке данных")
+
+// Sample — разобранная строка.
+type Sample struct {
+	// SourceTime — метка времени моста; нулевая, если её нет.
+	SourceTime time.Time
+	// Text — строка прошивки без метки моста.
+	Text string
+	// Values — значения полей по именам.
+	Values map[string]float64
+	// Flags — флаги качества полей от моста или оператора; nil, если
+	// качество не проверялось.
+	Flags map[string]qc.Flag
+}
+
+// Value возвращает значение поля и признак его наличия.
+func (s Sample) Value(name string) (float64, bool) {
+	v, ok := s.Values[name]
+	return v, ok
+}
+
+// SplitBridgeStamp отделяет метку времени моста от строки прошивки.
+func SplitBridgeStamp(line string) (time.Time, string) {
+	ts, rest, ok := strings.Cut(line, "\t")
+	if !ok {
+		return time.Time{}, line
+	}
+	t, err := time.ParseInLocation(BridgeTimeLayout, ts, time.Local)
+	if err != nil {
+		return time.Time{}, line
+	}
+	return t, rest
+}
+
+// SplitFlags отделяет флаги качества моста от строки прошивки. Флаги
+// nil, если их нет или они не разбираются.
+func SplitFlags(text string) (string, map[string]qc.Flag) {
+	text, s, ok := strings.Cut(text, "\t"+qc.Prefix)
+	if !ok {
+		return text, nil
+	}
+	flags, err := qc.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return text, nil
+	}
+	return text, flags
+}
+
+// ParseLine разбирает строку вида "[метка\t]Имя:значение, Имя:значение".
+// Если строка не является строкой данных, возвращается ErrNoData или
+// (для искажённых строк данных) ошибка с ErrBadData, а в Sample
+// заполнены только SourceTime и Text.
+func ParseLine(line string) (Sample, error) {
+	var s Sample
+	s.SourceTime, s.Text = SplitBridgeStamp(strings.TrimSpace(line))
+	s.Text, s.Flags = SplitFlags(s.Text)
+	s.Text = strings.TrimSpace(s.Text)
+
+	parts := strings.Split(s.Text, ",")
+	if !looksLikeData(parts[0]) {
+		return s, ErrNoData
+	}
+	values := make(map[string]float64)
+	names := make([]string, 0, len(parts))
+	for _, part := range parts {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || name == "" {
+			return s, fmt.Errorf("%w: %q", ErrBadData, part)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return s, fmt.Errorf("%w: поле %s: %v", ErrBadData, name, err)
+		}
+		values[name] = v
+		names = append(names, name)
+	}
+	if truncated(names) {
+		return s, fmt.Errorf("%w: строка оборвана после поля %s", ErrBadData, names[len(names)-1])
+	}
+	s.Values = values
+	return s, nil
+}
+
+// truncated сообщает, что поля строки — начало полей прошивки, но не
+// все. Прошивка всегда печатает все поля, так что строка оборвана, и
+// последнее значение может быть обрывком ("T1:2" вместо "T1:20.5").
+func truncated(names []string) bool {
+	if len(names) >= len(Fields) {
+		return false
+	}
+	for i, name := range names {
+		if name != Fields[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+// looksLikeData проверяет, что первое поле строки похоже на поле
+// данных: имя из латинских букв, цифр и "_" и число (или обрывок
+// числа) после двоеточия. Баннер, сообщения прошивки и строки
+// состояния моста ("alarm:depth=active") так не выглядят.
+func looksLikeData(part string) bool {
+	name, value, ok := strings.Cut(strings.TrimSpace(part), ":")
+	if !ok || name == "" {
+		return false
+	}
+	for i, c := range name {
+		letter := c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '_'
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	value = strings.TrimSpace(value)
+	if value == "" || strings.ContainsAny(value[:1], "+-.0123456789") {
+		return true
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
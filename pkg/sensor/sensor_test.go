@@ -0,0 +1,97 @@
+package sensor
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/physicist2018/goserialcomm/pkg/qc"
+)
+
+func TestParseLine(t *testing.T) {
+	stamp := time.Date(2025, 8, 29, 3, 6, 48, 0, time.Local)
+	tests := []struct {
+		name   string
+		line   string
+		text   string
+		source time.Time
+		values map[string]float64
+		flags  map[string]qc.Flag
+		err    error
+	}{
+		{
+			name:   "строка прошивки",
+			line:   "P:1013.25, T1:20.10, Depth:0.00, Alt:0.50, T2:20.05\r\n",
+			text:   "P:1013.25, T1:20.10, Depth:0.00, Alt:0.50, T2:20.05",
+			values: map[string]float64{"P": 1013.25, "T1": 20.1, "Depth": 0, "Alt": 0.5, "T2": 20.05},
+		},
+		{
+			name:   "метка моста",
+			line:   "20250829030648\tP:1013.25, T2:20.05",
+			text:   "P:1013.25, T2:20.05",
+			source: stamp,
+			values: map[string]float64{"P": 1013.25, "T2": 20.05},
+		},
+		{
+			name:   "метка и флаги моста",
+			line:   "20250829030648\tP:1013.25, T2:120\tqc:P=1,T2=4",
+			text:   "P:1013.25, T2:120",
+			source: stamp,
+			values: map[string]float64{"P": 1013.25, "T2": 120},
+			flags:  map[string]qc.Flag{"P": qc.Pass, "T2": qc.Fail},
+		},
+		{
+			name:   "поля другого прибора",
+			line:   "C:42.914, T:15",
+			text:   "C:42.914, T:15",
+			values: map[string]float64{"C": 42.914, "T": 15},
+		},
+		{name: "баннер", line: "Starting", text: "Starting", err: ErrNoData},
+		{
+			name: "ошибка инициализации",
+			line: "MS5837 Init failed, retry in 1 sec",
+			text: "MS5837 Init failed, retry in 1 sec",
+			err:  ErrNoData,
+		},
+		{
+			name:   "баннер с меткой моста",
+			line:   "20250829030648\tStarting",
+			text:   "Starting",
+			source: stamp,
+			err:    ErrNoData,
+		},
+		{name: "пустая строка", line: "\r\n", err: ErrNoData},
+		{
+			name: "строка состояния моста",
+			line: "alarm:depth=active\t20250829030648\tDepth = 25.3 > 25",
+			text: "alarm:depth=active\t20250829030648\tDepth = 25.3 > 25",
+			err:  ErrNoData,
+		},
+		{name: "мусор в значении", line: "P:10\x0013.25, T2:20.05", text: "P:10\x0013.25, T2:20.05", err: ErrBadData},
+		{name: "оборванная строка", line: "P:1013.25, T1:20.10, Dep", text: "P:1013.25, T1:20.10, Dep", err: ErrBadData},
+		{name: "обрыв на границе поля", line: "P:1013.25, T1:2", text: "P:1013.25, T1:2", err: ErrBadData},
+		{name: "только давление", line: "20250829030648\tP:1013.25", text: "P:1013.25", source: stamp, err: ErrBadData},
+		{name: "пустое значение", line: "P:, T2:20.05", text: "P:, T2:20.05", err: ErrBadData},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseLine(tt.line)
+			if !errors.Is(err, tt.err) || (tt.err == nil) != (err == nil) {
+				t.Fatalf("ошибка %v, ожидается %v", err, tt.err)
+			}
+			if s.Text != tt.text {
+				t.Errorf("Text = %q, ожидается %q", s.Text, tt.text)
+			}
+			if !s.SourceTime.Equal(tt.source) {
+				t.Errorf("SourceTime = %v, ожидается %v", s.SourceTime, tt.source)
+			}
+			if err == nil && !reflect.DeepEqual(s.Values, tt.values) {
+				t.Errorf("Values = %v, ожидается %v", s.Values, tt.values)
+			}
+			if !reflect.DeepEqual(s.Flags, tt.flags) {
+				t.Errorf("Flags = %v, ожидается %v", s.Flags, tt.flags)
+			}
+		})
+	}
+}
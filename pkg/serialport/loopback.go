@@ -0,0 +1,122 @@
+package serialport
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// pipe — однонаправленный буфер в памяти. В отличие от io.Pipe запись
+// не блокируется до чтения, как и у настоящего UART с буфером приёма.
+type pipe struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte
+	closed bool
+}
+
+func newPipe() *pipe {
+	p := &pipe{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *pipe) read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.buf) == 0 && !p.closed {
+		p.cond.Wait()
+	}
+	if len(p.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, p.buf)
+	p.buf = p.buf[n:]
+	return n, nil
+}
+
+func (p *pipe) write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	p.buf = append(p.buf, b...)
+	p.cond.Broadcast()
+	return len(b), nil
+}
+
+func (p *pipe) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.cond.Broadcast()
+}
+
+// loopbackEnd — один конец loopback-пары: читает из одного буфера,
+// пишет в другой.
+type loopbackEnd struct {
+	in, out *pipe
+	once    sync.Once
+}
+
+func (e *loopbackEnd) Read(b []byte) (int, error)  { return e.in.read(b) }
+func (e *loopbackEnd) Write(b []byte) (int, error) { return e.out.write(b) }
+
+// Close закрывает оба направления: противоположная сторона дочитает
+// оставшиеся данные и получит io.EOF, как при отключении устройства.
+func (e *loopbackEnd) Close() error {
+	e.once.Do(func() {
+		e.in.close()
+		e.out.close()
+	})
+	return nil
+}
+
+// NewLoopback создаёт пару соединённых портов в памяти: всё, что записано
+// в device, читается из host, и наоборот.
+func NewLoopback() (device, host Port) {
+	toHost, toDevice := newPipe(), newPipe()
+	return &loopbackEnd{in: toDevice, out: toHost},
+		&loopbackEnd{in: toHost, out: toDevice}
+}
+
+var (
+	loopbacksMux sync.Mutex
+	loopbacks    = make(map[string]func() Port)
+)
+
+// RegisterLoopback регистрирует именованный loopback-порт и возвращает
+// функцию, выдающую сторону устройства. Каждое открытие "loop://<name>"
+// через Open создаёт новую пару; функция device блокируется до
+// очередного открытия и возвращает сторону устройства этой пары.
+// Так эмулятор в том же процессе переживает переподключения моста.
+// Если сторону устройства прошлого открытия так никто и не забрал, она
+// закрывается: её хост получит io.EOF.
+func RegisterLoopback(name string) (device func() Port) {
+	ch := make(chan Port, 1)
+	loopbacksMux.Lock()
+	loopbacks[name] = func() Port {
+		d, h := NewLoopback()
+		for {
+			select {
+			case ch <- d:
+				return h
+			case old := <-ch:
+				old.Close()
+			}
+		}
+	}
+	loopbacksMux.Unlock()
+	return func() Port { return <-ch }
+}
+
+func openLoopback(name string) (Port, error) {
+	loopbacksMux.Lock()
+	open, ok := loopbacks[name]
+	loopbacksMux.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("serialport: loopback %q не зарегистрирован", name)
+	}
+	return open(), nil
+}
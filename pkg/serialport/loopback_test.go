@@ -0,0 +1,139 @@
+package serialport
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestLoopback(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, device, host Port)
+	}{
+		{"устройство → хост", func(t *testing.T, device, host Port) {
+			write(t, device, "P:1013.25\r\n")
+			if got := read(t, host, 11); got != "P:1013.25\r\n" {
+				t.Errorf("прочитано %q", got)
+			}
+		}},
+		{"хост → устройство", func(t *testing.T, device, host Port) {
+			write(t, host, "ping")
+			if got := read(t, device, 4); got != "ping" {
+				t.Errorf("прочитано %q", got)
+			}
+		}},
+		{"запись не ждёт чтения", func(t *testing.T, device, host Port) {
+			write(t, device, "a")
+			write(t, device, "b")
+			if got := read(t, host, 2); got != "ab" {
+				t.Errorf("прочитано %q", got)
+			}
+		}},
+		{"после закрытия дочитывается буфер, затем EOF", func(t *testing.T, device, host Port) {
+			write(t, device, "tail")
+			device.Close()
+			if got := read(t, host, 4); got != "tail" {
+				t.Errorf("прочитано %q", got)
+			}
+			if _, err := host.Read(make([]byte, 1)); err != io.EOF {
+				t.Errorf("ошибка %v, ожидается io.EOF", err)
+			}
+		}},
+		{"закрытие будит ожидающее чтение", func(t *testing.T, device, host Port) {
+			done := make(chan error, 1)
+			go func() {
+				_, err := host.Read(make([]byte, 1))
+				done <- err
+			}()
+			time.Sleep(10 * time.Millisecond)
+			host.Close()
+			select {
+			case err := <-done:
+				if err != io.EOF {
+					t.Errorf("ошибка %v, ожидается io.EOF", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("чтение не завершилось после Close")
+			}
+		}},
+		{"запись после закрытия", func(t *testing.T, device, host Port) {
+			host.Close()
+			if _, err := device.Write([]byte("x")); !errors.Is(err, io.ErrClosedPipe) {
+				t.Errorf("ошибка %v, ожидается io.ErrClosedPipe", err)
+			}
+			if _, err := host.Write([]byte("x")); !errors.Is(err, io.ErrClosedPipe) {
+				t.Errorf("ошибка %v, ожидается io.ErrClosedPipe", err)
+			}
+		}},
+		{"повторное закрытие", func(t *testing.T, device, host Port) {
+			if err := device.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := device.Close(); err != nil {
+				t.Errorf("повторный Close: %v", err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device, host := NewLoopback()
+			defer device.Close()
+			defer host.Close()
+			tt.run(t, device, host)
+		})
+	}
+}
+
+func TestRegisterLoopback(t *testing.T) {
+	device := RegisterLoopback("test-register")
+
+	host, err := Open(LoopbackScheme+"test-register", 9600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := device()
+	write(t, d, "hello")
+	if got := read(t, host, 5); got != "hello" {
+		t.Errorf("прочитано %q", got)
+	}
+
+	// Сторону устройства второго открытия никто не забрал: третье
+	// открытие закрывает её, и хост второго получает EOF.
+	stale, err := Open(LoopbackScheme+"test-register", 9600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := Open(LoopbackScheme+"test-register", 9600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stale.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("незабранная пара: ошибка %v, ожидается io.EOF", err)
+	}
+	write(t, device(), "again")
+	if got := read(t, fresh, 5); got != "again" {
+		t.Errorf("прочитано %q", got)
+	}
+
+	if _, err := Open(LoopbackScheme+"test-missing", 9600); err == nil {
+		t.Error("открыт незарегистрированный loopback")
+	}
+}
+
+func write(t *testing.T, p Port, s string) {
+	t.Helper()
+	if _, err := p.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, p Port, n int) string {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := io.ReadFull(p, b); err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,45 @@
+package serialport
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+)
+
+// PTY — пара псевдотерминалов. Эмулятор пишет в Master, а мост открывает
+// SlaveName как обычный COM-порт (например, -com /dev/pts/5).
+type PTY struct {
+	Master    *os.File
+	SlaveName string
+
+	// slave держим открытым, чтобы переподключение моста не приводило
+	// к ошибке EIO на стороне мастера.
+	slave *os.File
+}
+
+// OpenPTY создаёт пару pty и переводит подчинённую сторону в сырой режим,
+// чтобы дисциплина линии не превращала "\r\n" прошивки в "\n\n".
+// Доступно на Linux, macOS и BSD.
+func OpenPTY() (*PTY, error) {
+	master, slave, err := pty.Open()
+	if err != nil {
+		return nil, fmt.Errorf("serialport: pty: %w", err)
+	}
+	if _, err := term.MakeRaw(int(slave.Fd())); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, fmt.Errorf("serialport: pty raw mode: %w", err)
+	}
+	return &PTY{Master: master, SlaveName: slave.Name(), slave: slave}, nil
+}
+
+func (p *PTY) Read(b []byte) (int, error)  { return p.Master.Read(b) }
+func (p *PTY) Write(b []byte) (int, error) { return p.Master.Write(b) }
+
+// Close закрывает обе стороны пары.
+func (p *PTY) Close() error {
+	p.slave.Close()
+	return p.Master.Close()
+}
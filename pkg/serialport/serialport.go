@@ -0,0 +1,41 @@
+// Пакет serialport скрывает последовательный порт за интерфейсом Port,
+// чтобы мосты и эмулятор прошивки могли работать как с настоящим
+// COM-портом, так и с псевдотерминалом (pty) или памятью (loopback).
+package serialport
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"go.bug.st/serial"
+)
+
+// LoopbackScheme — префикс имени порта для внутрипроцессного loopback.
+const LoopbackScheme = "loop://"
+
+// Port — минимальный интерфейс порта, которым пользуются мосты.
+type Port interface {
+	io.ReadWriteCloser
+}
+
+// Open открывает порт по имени. Имена вида "loop://<имя>" открывают
+// хостовую сторону loopback-порта, зарегистрированного через
+// RegisterLoopback; все остальные имена (COM1, /dev/ttyUSB0, /dev/pts/3)
+// открываются как обычный последовательный порт.
+func Open(name string, baudRate int) (Port, error) {
+	if strings.HasPrefix(name, LoopbackScheme) {
+		return openLoopback(strings.TrimPrefix(name, LoopbackScheme))
+	}
+	return OpenHardware(name, baudRate)
+}
+
+// OpenHardware открывает настоящий последовательный порт (или подчинённую
+// сторону pty) через go.bug.st/serial.
+func OpenHardware(name string, baudRate int) (Port, error) {
+	port, err := serial.Open(name, &serial.Mode{BaudRate: baudRate})
+	if err != nil {
+		return nil, fmt.Errorf("serialport: %s: %w", name, err)
+	}
+	return port, nil
+}